@@ -1,13 +1,39 @@
 package main
 
 import (
+    "fmt"
     "log"
+    "os"
+    "asa/shell/internal/command/migrate"
+    "asa/shell/internal/database"
     "asa/shell/internal/shell"
 )
 
 func main() {
-    sh := shell.New()
+    if len(os.Args) > 1 && os.Args[1] == "migrate" {
+        runMigrate(os.Args[2:])
+        return
+    }
+    sh, err := shell.New()
+    if err != nil {
+        fmt.Fprintln(os.Stderr, "shell:", err)
+        os.Exit(1)
+    }
     if err := sh.Start(); err != nil {
         log.Fatalf("Shell error: %v", err)
     }
-}
\ No newline at end of file
+}
+
+// runMigrate handles "shell migrate status|up|down" without starting the
+// shell.
+func runMigrate(args []string) {
+    db, err := database.Connect()
+    if err == nil {
+        err = migrate.NewMigrateCommand(db).Execute(args, os.Stdout)
+    }
+    if err != nil {
+        fmt.Fprintln(os.Stderr, "shell migrate:", err)
+        fmt.Fprintln(os.Stderr, "usage: shell migrate status | up [n] | down [n]")
+        os.Exit(1)
+    }
+}
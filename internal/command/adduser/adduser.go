@@ -1,45 +1,63 @@
 package adduser
 
 import (
+	"asa/shell/internal/command"
 	user "asa/shell/internal/service"
 	"asa/shell/utils"
 	"io"
-
-	"gorm.io/gorm"
 )
 
 type AddUserCommand struct {
-	db   *gorm.DB
-	user *user.User
+	store        user.UserStore
+	user         *user.User
+	readPassword command.PasswordReader
 }
 
-func NewAddUserCommand(db *gorm.DB, user *user.User) *AddUserCommand {
+func NewAddUserCommand(store user.UserStore, user *user.User) *AddUserCommand {
 	return &AddUserCommand{
-		db:   db,
-		user: user,
+		store: store,
+		user:  user,
 	}
 }
 
+// SetPasswordReader sets how the password of adduser -p is read.
+func (c *AddUserCommand) SetPasswordReader(readPassword command.PasswordReader) {
+	c.readPassword = readPassword
+}
+
 func (c *AddUserCommand) Name() string {
 	return "adduser"
 }
 
 func (c *AddUserCommand) Execute(args []string, stdout io.Writer) error {
+	prompt := len(args) > 0 && args[0] == "-p"
+	if prompt {
+		args = args[1:]
+	}
 	if len(args) == 0 {
 		return utils.ErrUsernameRequired
 	}
-	if len(args) > 2 {
+	if len(args) > 2 || (prompt && len(args) == 2) {
 		return utils.ErrInvalidArgs
 	}
 	var pass string
 	if len(args) == 2 {
 		pass = args[1]
 	}
+	if prompt {
+		if c.readPassword == nil {
+			return user.ErrPassRequired
+		}
+		var err error
+		if pass, err = c.readPassword.Confirm(); err != nil {
+			return err
+		}
+	}
 
 	newUser := &user.User{Username: args[0], Password: pass}
-	err := user.RegisterUser(c.db, newUser)
+	err := user.RegisterUser(c.store, newUser)
 	if err != nil {
 		return err
 	}
-	return nil
+	return user.EnsureHome(c.store, newUser)
 }
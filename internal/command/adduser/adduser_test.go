@@ -6,37 +6,20 @@ import (
 	"bytes"
 	"errors"
 	"os"
+	"path/filepath"
 	"testing"
-	"gorm.io/driver/sqlite"
-	"gorm.io/gorm"
 )
 
-func setupTestDB(t *testing.T) *gorm.DB {
+func setupTestStore(t *testing.T) user.UserStore {
 	t.Helper()
-	db, err := gorm.Open(sqlite.Open("file::memory:?cache=shared"), &gorm.Config{})
-	if err != nil {
-		t.Fatalf("failed to connect to test database: %v", err)
-	}
-
-	err = db.AutoMigrate(&user.User{})
-	if err != nil {
-		t.Fatalf("failed to migrate database: %v", err)
-	}
-	return db
-}
-
-func teardownTestDB(t *testing.T, db *gorm.DB) {
-	t.Helper()
-	sqlDB, _ := db.DB()
-	sqlDB.Close()
-	os.Remove("file::memory:?cache=shared")
+	t.Setenv("SHELL_HOME_BASE", t.TempDir())
+	return user.NewMemoryStore()
 }
 
 func TestAddUserCommand_Name(t *testing.T) {
-	db := setupTestDB(t)
-	defer teardownTestDB(t, db)
+	store := setupTestStore(t)
 
-	cmd := NewAddUserCommand(db, nil)
+	cmd := NewAddUserCommand(store, nil)
 
 	if cmd.Name() != "adduser" {
 		t.Errorf("Name() should return 'adduser', but got '%s'", cmd.Name())
@@ -44,24 +27,22 @@ func TestAddUserCommand_Name(t *testing.T) {
 }
 
 func TestAddUserCommand_Execute(t *testing.T) {
-	db := setupTestDB(t)
-	defer teardownTestDB(t, db)
+	store := setupTestStore(t)
 
-	cmd := NewAddUserCommand(db, nil)
+	cmd := NewAddUserCommand(store, nil)
 
 	tests := []struct {
 		name        string
 		args        []string
 		expectedErr error
-		assertUser  func(t *testing.T, db *gorm.DB, username string, expectedError error)
+		assertUser  func(t *testing.T, store user.UserStore, username string, expectedError error)
 	}{
 		{
 			name:        "Valid username, no password",
 			args:        []string{"testuser"},
 			expectedErr: nil,
-			assertUser: func(t *testing.T, db *gorm.DB, username string, expectedError error) {
-				var u user.User
-				if err := db.Where("user_name = ?", username).First(&u).Error; err != nil {
+			assertUser: func(t *testing.T, store user.UserStore, username string, expectedError error) {
+				if u, err := store.FindUser(username); err != nil {
 					t.Errorf("Expected user '%s' to be created, but not found in DB: %v", username, err)
 				} else if u.Username != username {
 					t.Errorf("Retrieved user has incorrect username: got '%s', expected '%s'", u.Username, username)
@@ -72,9 +53,8 @@ func TestAddUserCommand_Execute(t *testing.T) {
 			name:        "Valid username and password",
 			args:        []string{"testuser2", "password123"},
 			expectedErr: nil,
-			assertUser: func(t *testing.T, db *gorm.DB, username string, expectedError error) {
-				var u user.User
-				if err := db.Where("user_name = ?", username).First(&u).Error; err != nil {
+			assertUser: func(t *testing.T, store user.UserStore, username string, expectedError error) {
+				if u, err := store.FindUser(username); err != nil {
 					t.Errorf("Expected user '%s' to be created, but not found in DB: %v", username, err)
 				} else if u.Username != username {
 					t.Errorf("Retrieved user has incorrect username: got '%s', expected '%s'", u.Username, username)
@@ -85,10 +65,9 @@ func TestAddUserCommand_Execute(t *testing.T) {
 			name:        "Missing username",
 			args:        []string{},
 			expectedErr: utils.ErrUsernameRequired,
-			assertUser: func(t *testing.T, db *gorm.DB, username string, expectedError error) {
-				var u user.User
-				err := db.Where("user_name = ?", username).First(&u).Error
-				if !errors.Is(err, gorm.ErrRecordNotFound) {
+			assertUser: func(t *testing.T, store user.UserStore, username string, expectedError error) {
+				_, err := store.FindUser(username)
+				if !errors.Is(err, user.ErrUserNotFound) {
 					t.Errorf("Expected no user to be created, but found user or unexpected error: %v", err)
 				}
 			},
@@ -97,10 +76,9 @@ func TestAddUserCommand_Execute(t *testing.T) {
 			name:        "Too many arguments",
 			args:        []string{"user", "pass", "extra"},
 			expectedErr: utils.ErrInvalidArgs,
-			assertUser: func(t *testing.T, db *gorm.DB, username string, expectedError error) {
-				var u user.User
-				err := db.Where("user_name = ?", username).First(&u).Error
-				if !errors.Is(err, gorm.ErrRecordNotFound) {
+			assertUser: func(t *testing.T, store user.UserStore, username string, expectedError error) {
+				_, err := store.FindUser(username)
+				if !errors.Is(err, user.ErrUserNotFound) {
 					t.Errorf("Expected no user to be created, but found user or unexpected error: %v", err)
 				}
 			},
@@ -109,9 +87,14 @@ func TestAddUserCommand_Execute(t *testing.T) {
 			name:        "Username already exists",
 			args:        []string{"existinguser"},
 			expectedErr: user.ErrDuplicateUser,
-			assertUser: func(t *testing.T, db *gorm.DB, username string, expectedError error) {
-				count := int64(0)
-				db.Model(&user.User{}).Where("user_name = ?", username).Count(&count)
+			assertUser: func(t *testing.T, store user.UserStore, username string, expectedError error) {
+				users, _ := store.ListUsers()
+				count := 0
+				for _, u := range users {
+					if u.Username == username {
+						count++
+					}
+				}
 				if count != 1 {
 					t.Errorf("Expected only 1 user with username '%s' after failed add, but found %d", username, count)
 				}
@@ -127,7 +110,7 @@ func TestAddUserCommand_Execute(t *testing.T) {
             // SETUP for "Username already exists" test case:
             if cmdTest.name == "Username already exists" {
                 existingUser := &user.User{Username: cmdTest.args[0]}
-                err := user.RegisterUser(db, existingUser)
+                err := user.RegisterUser(store, existingUser)
                 if err != nil {
                     t.Fatalf("Failed to setup existing user for test: %v", err)
                 }
@@ -144,7 +127,71 @@ func TestAddUserCommand_Execute(t *testing.T) {
 			if len(cmdTest.args) > 0 {
 				usernameToAssert = cmdTest.args[0]
 			}
-			cmdTest.assertUser(t, db, usernameToAssert, err)
+			cmdTest.assertUser(t, store, usernameToAssert, err)
 		})
 	}
-}
\ No newline at end of file
+}
+
+func TestAddUserCommand_PromptPassword(t *testing.T) {
+	store := setupTestStore(t)
+
+	tests := []struct {
+		name        string
+		username    string
+		answers     []string
+		expectedErr error
+	}{
+		{name: "Confirmed password", username: "prompted", answers: []string{"s3cret", "s3cret"}},
+		{name: "Mismatched confirmation", username: "mismatched", answers: []string{"s3cret", "secret"}, expectedErr: utils.ErrPasswordMismatch},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			answers := tt.answers
+			cmd := NewAddUserCommand(store, nil)
+			cmd.SetPasswordReader(func(prompt string) (string, error) {
+				answer := answers[0]
+				answers = answers[1:]
+				return answer, nil
+			})
+
+			err := cmd.Execute([]string{"-p", tt.username}, &bytes.Buffer{})
+			if !errors.Is(err, tt.expectedErr) {
+				t.Fatalf("Execute() error = %v, wantErr %v", err, tt.expectedErr)
+			}
+			u, err := store.FindUser(tt.username)
+			if tt.expectedErr != nil {
+				if !errors.Is(err, user.ErrUserNotFound) {
+					t.Errorf("Expected no user to be created, but got: %v", err)
+				}
+				return
+			}
+			if err != nil || !user.CheckPassword(u.Password, "s3cret") {
+				t.Errorf("Expected user '%s' with the prompted password, got %+v, %v", tt.username, u, err)
+			}
+		})
+	}
+}
+
+func TestAddUserCommand_CreatesHome(t *testing.T) {
+	store := setupTestStore(t)
+
+	if err := NewAddUserCommand(store, nil).Execute([]string{"homeowner"}, &bytes.Buffer{}); err != nil {
+		t.Fatalf("Execute() unexpected error: %v", err)
+	}
+	u, _ := store.FindUser("homeowner")
+	if want := filepath.Join(os.Getenv("SHELL_HOME_BASE"), "homeowner"); u.HomeDir != want {
+		t.Errorf("Expected home directory '%s', but got '%s'", want, u.HomeDir)
+	}
+	if info, err := os.Stat(u.HomeDir); err != nil || !info.IsDir() {
+		t.Errorf("Expected home directory to be created: %v", err)
+	}
+
+	err := NewAddUserCommand(store, nil).Execute([]string{"../escape"}, &bytes.Buffer{})
+	if !errors.Is(err, user.ErrInvalidHome) {
+		t.Errorf("Execute() error = %v, wantErr %v", err, user.ErrInvalidHome)
+	}
+	if _, err := store.FindUser("../escape"); !errors.Is(err, user.ErrUserNotFound) {
+		t.Errorf("Expected no user to be created, but got: %v", err)
+	}
+}
@@ -0,0 +1,48 @@
+package alias
+
+import (
+	"asa/shell/internal/settings"
+	"fmt"
+	"io"
+	"strings"
+)
+
+type AliasCommand struct {
+	session *settings.Session
+}
+
+func NewAliasCommand(session *settings.Session) *AliasCommand {
+	return &AliasCommand{
+		session: session,
+	}
+}
+
+func (c *AliasCommand) Name() string {
+	return "alias"
+}
+
+// Execute defines NAME=VALUE aliases and prints those given by bare name,
+// or every alias without arguments.
+func (c *AliasCommand) Execute(args []string, stdout io.Writer) error {
+	if len(args) == 0 {
+		for _, a := range c.session.Aliases() {
+			fmt.Fprintf(stdout, "alias %s=%q\n", a.Name, a.Value)
+		}
+		return nil
+	}
+	for _, arg := range args {
+		name, value, ok := strings.Cut(arg, "=")
+		if !ok {
+			expansion, found := c.session.Alias(name)
+			if !found {
+				return fmt.Errorf("%s: %w", name, settings.ErrNoAlias)
+			}
+			fmt.Fprintf(stdout, "alias %s=%q\n", name, expansion)
+			continue
+		}
+		if err := c.session.SetAlias(name, value); err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,39 @@
+package alias
+
+import (
+	"asa/shell/internal/settings"
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func TestAliasCommand_Execute(t *testing.T) {
+	session := settings.New()
+	cmd := NewAliasCommand(session)
+
+	tests := []struct {
+		name        string
+		args        []string
+		want        string
+		expectedErr error
+	}{
+		{name: "Define", args: []string{"ll=ls -l", "la=ls -a"}},
+		{name: "List", args: []string{}, want: "alias la=\"ls -a\"\nalias ll=\"ls -l\"\n"},
+		{name: "Show one", args: []string{"ll"}, want: "alias ll=\"ls -l\"\n"},
+		{name: "Unknown alias", args: []string{"missing"}, expectedErr: settings.ErrNoAlias},
+		{name: "Invalid name", args: []string{"a b=c"}, expectedErr: settings.ErrInvalidName},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			err := cmd.Execute(tt.args, &buf)
+			if !errors.Is(err, tt.expectedErr) {
+				t.Fatalf("Execute() error = %v, wantErr %v", err, tt.expectedErr)
+			}
+			if buf.String() != tt.want {
+				t.Errorf("Execute() output = %q, want %q", buf.String(), tt.want)
+			}
+		})
+	}
+}
@@ -15,6 +15,7 @@ var (
 
 type CDCommand struct {
 	rootDir string
+	home    string
 }
 
 func NewCDCommand(rootDir string) *CDCommand {
@@ -23,6 +24,23 @@ func NewCDCommand(rootDir string) *CDCommand {
 	}
 }
 
+// SetRoot changes the directory cd goes to without arguments.
+func (c *CDCommand) SetRoot(rootDir string) {
+	c.rootDir = rootDir
+}
+
+// SetHome changes the directory ~ stands for, $HOME when empty.
+func (c *CDCommand) SetHome(home string) {
+	c.home = home
+}
+
+func (c *CDCommand) homeDir() string {
+	if c.home != "" {
+		return c.home
+	}
+	return os.Getenv("HOME")
+}
+
 func (c *CDCommand) Name() string {
 	return "cd"
 }
@@ -36,12 +54,11 @@ func (c *CDCommand) Execute(args []string, stdout io.Writer) error {
 	case 1:
 		switch args[0] {
 		case "~":
-			dir = os.Getenv("HOME")
+			dir = c.homeDir()
 		default:
 			dir = args[0]
-			if len(dir) > 0 && dir[0] == '~' {
-				home := os.Getenv("HOME")
-				dir = filepath.Join(home, dir[1:])
+			if strings.HasPrefix(dir, "~/") {
+				dir = filepath.Join(c.homeDir(), dir[2:])
 				break
 			}
 			if dir == ".." {
@@ -119,3 +119,35 @@ func TestCDCommand(t *testing.T) {
 		})
 	}
 }
+
+func TestCDCommand_Home(t *testing.T) {
+	originalDir, _ := os.Getwd()
+	t.Cleanup(func() { os.Chdir(originalDir) })
+	root, home, userHome := t.TempDir(), t.TempDir(), t.TempDir()
+	t.Setenv("HOME", home)
+
+	cmd := NewCDCommand(root)
+	tests := []struct {
+		name    string
+		home    string
+		args    []string
+		wantDir string
+	}{
+		{name: "no arguments - go to root", args: []string{}, wantDir: root},
+		{name: "tilde without a home - go to $HOME", args: []string{"~"}, wantDir: home},
+		{name: "tilde with a home", home: userHome, args: []string{"~"}, wantDir: userHome},
+		{name: "path with tilde and a home", home: userHome, args: []string{"~/."}, wantDir: userHome},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cmd.SetHome(tt.home)
+			if err := cmd.Execute(tt.args, os.Stdout); err != nil {
+				t.Fatalf("CDCommand.Execute() unexpected error: %v", err)
+			}
+			got, _ := os.Getwd()
+			if want, _ := filepath.EvalSymlinks(tt.wantDir); got != want {
+				t.Errorf("Current directory = %v, want %v", got, want)
+			}
+		})
+	}
+}
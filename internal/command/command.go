@@ -1,6 +1,9 @@
 package command
 
 import (
+	user "asa/shell/internal/service"
+	"asa/shell/utils"
+	"errors"
 	"io"
 )
 
@@ -8,3 +11,46 @@ type Command interface {
 	Execute(args []string, stdout io.Writer) error
 	Name() string
 }
+
+// PasswordReader prompts for a password and reads it without echoing it.
+type PasswordReader func(prompt string) (string, error)
+
+// Confirm reads a new password twice and checks both entries match.
+func (r PasswordReader) Confirm() (string, error) {
+	password, err := r("New password: ")
+	if err != nil {
+		return "", err
+	}
+	confirm, err := r("Retype new password: ")
+	if err != nil {
+		return "", err
+	}
+	if password != confirm {
+		return "", utils.ErrPasswordMismatch
+	}
+	return password, nil
+}
+
+// Authenticate logs name in like user.Authenticate, prompting for the
+// password and the verification code when the account needs them and they
+// were not given. Without a reader nothing is prompted for.
+func (r PasswordReader) Authenticate(store user.UserStore, name, pass, sessionID string) (user.User, error) {
+	var code string
+	account, err := user.Authenticate(store, name, pass, code, sessionID)
+	if r == nil {
+		return account, err
+	}
+	if errors.Is(err, user.ErrPassRequired) {
+		if pass, err = r("Password: "); err != nil {
+			return account, err
+		}
+		account, err = user.Authenticate(store, name, pass, code, sessionID)
+	}
+	if errors.Is(err, user.ErrCodeRequired) {
+		if code, err = r("Verification code: "); err != nil {
+			return account, err
+		}
+		account, err = user.Authenticate(store, name, pass, code, sessionID)
+	}
+	return account, err
+}
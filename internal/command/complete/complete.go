@@ -0,0 +1,204 @@
+package complete
+
+import (
+	"asa/shell/internal/completion"
+	"asa/shell/internal/rcfile"
+	"asa/shell/utils"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+var (
+	ErrFunctionsUnsupported = errors.New("shell functions are not supported, use -C <command> instead")
+	ErrNoSpec               = errors.New("no completion specification")
+)
+
+// CompleteCommand registers the completions of commands from word lists
+// (-W) or programs (-C) and keeps them in the rc file. bash's -F, which
+// names a shell function, is out of scope since the shell has no functions;
+// -C covers the same needs with a program.
+type CompleteCommand struct {
+	registry *completion.Registry
+	rcPath   string
+}
+
+type options struct {
+	print    bool
+	remove   bool
+	words    []string
+	program  string
+	function string
+	names    []string
+}
+
+func NewCompleteCommand(registry *completion.Registry, rcPath string) *CompleteCommand {
+	return &CompleteCommand{
+		registry: registry,
+		rcPath:   rcPath,
+	}
+}
+
+func (c *CompleteCommand) Name() string {
+	return "complete"
+}
+
+func (c *CompleteCommand) Execute(args []string, stdout io.Writer) error {
+	opts, err := parseOptions(args)
+	if err != nil {
+		return err
+	}
+	switch {
+	case opts.print:
+		return c.print(opts.names, stdout)
+	case opts.function != "":
+		return ErrFunctionsUnsupported
+	case opts.remove:
+		if len(opts.names) == 0 {
+			return utils.ErrNotEnoughArgs
+		}
+		for _, name := range opts.names {
+			if !c.registry.Remove(name) {
+				return fmt.Errorf("%s: %w", name, ErrNoSpec)
+			}
+		}
+		return c.save(opts.names)
+	case opts.words != nil || opts.program != "":
+		if len(opts.names) == 0 {
+			return utils.ErrNotEnoughArgs
+		}
+		for _, name := range opts.names {
+			c.registry.Set(completion.Spec{Command: name, Words: opts.words, Program: opts.program})
+		}
+		return c.save(opts.names)
+	default:
+		return c.print(opts.names, stdout)
+	}
+}
+
+func (c *CompleteCommand) print(names []string, stdout io.Writer) error {
+	if len(names) == 0 {
+		for _, spec := range c.registry.Specs() {
+			fmt.Fprintln(stdout, spec)
+		}
+		return nil
+	}
+	for _, name := range names {
+		spec, ok := c.registry.Get(name)
+		if !ok {
+			return fmt.Errorf("%s: %w", name, ErrNoSpec)
+		}
+		fmt.Fprintln(stdout, spec)
+	}
+	return nil
+}
+
+// save rewrites the complete lines of the rc file that mention names so
+// they match the registry, leaving every other line where it was.
+func (c *CompleteCommand) save(names []string) error {
+	if c.rcPath == "" {
+		return nil
+	}
+	lines, err := rcfile.Read(c.rcPath)
+	if err != nil {
+		return err
+	}
+	affected := make(map[string]bool)
+	for _, name := range names {
+		affected[name] = true
+	}
+
+	updated := []string{}
+	insertAt := -1
+	for _, line := range lines {
+		opts, ok := parseLine(line)
+		if !ok || !mentions(opts.names, affected) {
+			updated = append(updated, line)
+			continue
+		}
+		if insertAt < 0 {
+			insertAt = len(updated)
+		}
+		for _, name := range opts.names {
+			if !affected[name] {
+				updated = append(updated, completion.Spec{Command: name, Words: opts.words, Program: opts.program}.String())
+			}
+		}
+	}
+
+	specLines := []string{}
+	for _, name := range names {
+		if spec, ok := c.registry.Get(name); ok {
+			specLines = append(specLines, spec.String())
+		}
+	}
+	if insertAt < 0 {
+		insertAt = len(updated)
+	}
+	updated = append(updated[:insertAt], append(specLines, updated[insertAt:]...)...)
+
+	if strings.Join(updated, "\n") == strings.Join(lines, "\n") {
+		return nil
+	}
+	return rcfile.Write(c.rcPath, updated)
+}
+
+func parseLine(line string) (options, bool) {
+	if !rcfile.IsCommand(line) {
+		return options{}, false
+	}
+	args, err := utils.ParseArgs(strings.TrimSpace(line))
+	if err != nil || len(args) == 0 || args[0] != "complete" {
+		return options{}, false
+	}
+	opts, err := parseOptions(args[1:])
+	if err != nil || (opts.words == nil && opts.program == "") {
+		return options{}, false
+	}
+	return opts, true
+}
+
+func parseOptions(args []string) (options, error) {
+	var opts options
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		switch arg {
+		case "-p":
+			opts.print = true
+		case "-r":
+			opts.remove = true
+		case "-W", "-C", "-F":
+			if i+1 >= len(args) {
+				return opts, utils.ErrNotEnoughArgs
+			}
+			i++
+			switch arg {
+			case "-W":
+				opts.words = strings.Fields(args[i])
+			case "-C":
+				opts.program = args[i]
+			case "-F":
+				opts.function = args[i]
+			}
+		default:
+			if strings.HasPrefix(arg, "-") {
+				return opts, fmt.Errorf("invalid option: %s", arg)
+			}
+			opts.names = append(opts.names, arg)
+		}
+	}
+	if opts.words != nil && opts.program != "" {
+		return opts, utils.ErrInvalidArgs
+	}
+	return opts, nil
+}
+
+func mentions(names []string, set map[string]bool) bool {
+	for _, name := range names {
+		if set[name] {
+			return true
+		}
+	}
+	return false
+}
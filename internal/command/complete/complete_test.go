@@ -0,0 +1,156 @@
+package complete
+
+import (
+	"asa/shell/internal/completion"
+	"asa/shell/internal/rcfile"
+	"asa/shell/utils"
+	"bytes"
+	"errors"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestCompleteCommand_Name(t *testing.T) {
+	cmd := NewCompleteCommand(completion.NewRegistry(), "")
+	if cmd.Name() != "complete" {
+		t.Errorf("Name() should return 'complete', got %v", cmd.Name())
+	}
+}
+
+func TestCompleteCommand_Execute(t *testing.T) {
+	tests := []struct {
+		name       string
+		rcLines    []string
+		args       []string
+		wantErr    error
+		wantOutput string
+		wantRC     []string
+	}{
+		{
+			name:    "register word list",
+			rcLines: []string{"# my rc"},
+			args:    []string{"-W", "start stop", "svc"},
+			wantRC:  []string{"# my rc", "complete -W 'start stop' svc"},
+		},
+		{
+			name:   "register program for several commands",
+			args:   []string{"-C", "svc-complete", "svc", "svcctl"},
+			wantRC: []string{"complete -C svc-complete svc", "complete -C svc-complete svcctl"},
+		},
+		{
+			name:    "replace existing spec in place",
+			rcLines: []string{"complete -W old svc", "echo after"},
+			args:    []string{"-W", "new", "svc"},
+			wantRC:  []string{"complete -W new svc", "echo after"},
+		},
+		{
+			name:    "reloading the rc keeps it unchanged",
+			rcLines: []string{"echo before", "complete -W 'a b' svc"},
+			args:    []string{"-W", "a b", "svc"},
+			wantRC:  []string{"echo before", "complete -W 'a b' svc"},
+		},
+		{
+			name:    "remove spec",
+			rcLines: []string{"complete -W a svc", "complete -W b other"},
+			args:    []string{"-r", "svc"},
+			wantErr: nil,
+			wantRC:  []string{"complete -W b other"},
+		},
+		{
+			name:    "remove unknown spec",
+			args:    []string{"-r", "nothing"},
+			wantErr: ErrNoSpec,
+		},
+		{
+			name:    "functions are not supported",
+			args:    []string{"-F", "_svc", "svc"},
+			wantErr: ErrFunctionsUnsupported,
+		},
+		{
+			name:    "function without a name",
+			args:    []string{"-F"},
+			wantErr: utils.ErrNotEnoughArgs,
+		},
+		{
+			name:    "functions in the rc file are ignored",
+			rcLines: []string{"complete -F _svc svc", "complete -W a other"},
+			args:    []string{"-p", "svc"},
+			wantErr: ErrNoSpec,
+		},
+		{
+			name:    "word list needs a command",
+			args:    []string{"-W", "a b"},
+			wantErr: utils.ErrNotEnoughArgs,
+		},
+		{
+			name:    "missing word list",
+			args:    []string{"-W"},
+			wantErr: utils.ErrNotEnoughArgs,
+		},
+		{
+			name:    "both word list and program",
+			args:    []string{"-W", "a", "-C", "b", "svc"},
+			wantErr: utils.ErrInvalidArgs,
+		},
+		{
+			name:       "print specs",
+			rcLines:    []string{"complete -W 'a b' svc"},
+			args:       []string{"-p"},
+			wantOutput: "complete -W 'a b' svc\n",
+		},
+		{
+			name:       "print named specs",
+			rcLines:    []string{"complete -W 'a b' svc", "complete -C other-complete other"},
+			args:       []string{"-p", "other"},
+			wantOutput: "complete -C other-complete other\n",
+		},
+		{
+			name:    "print unknown spec",
+			args:    []string{"-p", "svc"},
+			wantErr: ErrNoSpec,
+		},
+		{
+			name:       "print wins over registering",
+			rcLines:    []string{"complete -W a svc"},
+			args:       []string{"-p", "-W", "b", "svc"},
+			wantOutput: "complete -W a svc\n",
+			wantRC:     []string{"complete -W a svc"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rcPath := filepath.Join(t.TempDir(), ".shellrc")
+			if tt.rcLines != nil {
+				if err := rcfile.Write(rcPath, tt.rcLines); err != nil {
+					t.Fatal(err)
+				}
+			}
+			registry := completion.NewRegistry()
+			for _, line := range tt.rcLines {
+				if opts, ok := parseLine(line); ok {
+					for _, name := range opts.names {
+						registry.Set(completion.Spec{Command: name, Words: opts.words, Program: opts.program})
+					}
+				}
+			}
+			cmd := NewCompleteCommand(registry, rcPath)
+
+			var stdout bytes.Buffer
+			err := cmd.Execute(tt.args, &stdout)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Execute() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantOutput != "" && stdout.String() != tt.wantOutput {
+				t.Errorf("Execute() output = %q, want %q", stdout.String(), tt.wantOutput)
+			}
+			if tt.wantRC != nil {
+				got, _ := rcfile.Read(rcPath)
+				if !reflect.DeepEqual(got, tt.wantRC) {
+					t.Errorf("rc file = %q, want %q", got, tt.wantRC)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,69 @@
+package deluser
+
+import (
+	"asa/shell/internal/command"
+	user "asa/shell/internal/service"
+	"asa/shell/utils"
+	"fmt"
+	"io"
+)
+
+type DelUserCommand struct {
+	store        user.UserStore
+	user         *user.User
+	readPassword command.PasswordReader
+	sessionID    string
+}
+
+func NewDelUserCommand(store user.UserStore, user *user.User) *DelUserCommand {
+	return &DelUserCommand{
+		store: store,
+		user:  user,
+	}
+}
+
+// SetPasswordReader sets how a password left off the command line is read.
+func (c *DelUserCommand) SetPasswordReader(readPassword command.PasswordReader) {
+	c.readPassword = readPassword
+}
+
+// SetSessionID sets the session the authentication is audited under.
+func (c *DelUserCommand) SetSessionID(sessionID string) {
+	c.sessionID = sessionID
+}
+
+func (c *DelUserCommand) Name() string {
+	return "deluser"
+}
+
+// Execute removes an account after authenticating as it like login does,
+// verification code included, which admins need not do. Deleting the
+// logged-in account ends the session.
+func (c *DelUserCommand) Execute(args []string, stdout io.Writer) error {
+	if len(args) == 0 {
+		return utils.ErrUsernameRequired
+	}
+	if len(args) > 2 {
+		return utils.ErrInvalidArgs
+	}
+	var pass string
+	if len(args) == 2 {
+		pass = args[1]
+	}
+
+	name := args[0]
+	if !user.IsAdmin(c.user) {
+		if _, err := c.readPassword.Authenticate(c.store, name, pass, c.sessionID); err != nil {
+			return err
+		}
+	}
+
+	if err := user.DeleteUser(c.store, name); err != nil {
+		return err
+	}
+	if c.user.Username == name {
+		*c.user = user.User{}
+	}
+	_, err := fmt.Fprintf(stdout, "user %s deleted\n", name)
+	return err
+}
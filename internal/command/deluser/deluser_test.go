@@ -0,0 +1,110 @@
+package deluser
+
+import (
+	user "asa/shell/internal/service"
+	"asa/shell/internal/totp"
+	"asa/shell/utils"
+	"bytes"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestDelUserCommand_Execute(t *testing.T) {
+	tests := []struct {
+		name        string
+		args        []string
+		loggedIn    bool
+		admin       bool
+		answer      string
+		expectedErr error
+		wantDeleted bool
+	}{
+		{name: "Password on the command line", args: []string{"alice", "s3cret"}, wantDeleted: true},
+		{name: "Prompted password", args: []string{"alice"}, answer: "s3cret", wantDeleted: true},
+		{name: "Logged-in account", args: []string{"alice"}, loggedIn: true, answer: "s3cret", wantDeleted: true},
+		{name: "Wrong password", args: []string{"alice", "wrong"}, expectedErr: user.ErrWrongPassword},
+		{name: "Admin needs no password", args: []string{"alice"}, admin: true, wantDeleted: true},
+		{name: "Unknown user", args: []string{"bob", "s3cret"}, expectedErr: user.ErrUserNotFound},
+		{name: "Missing username", expectedErr: utils.ErrUsernameRequired},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := user.NewMemoryStore()
+			alice := &user.User{Username: "alice", Password: "s3cret"}
+			if err := user.RegisterUser(store, alice); err != nil {
+				t.Fatalf("Failed to setup existing user: %v", err)
+			}
+			if err := user.AddHistoryEntry(store, &user.HistoryEntry{UserID: alice.ID, Command: "ls"}); err != nil {
+				t.Fatalf("Failed to setup history: %v", err)
+			}
+			currentUser := &user.User{}
+			if tt.loggedIn {
+				*currentUser = *alice
+			}
+			if tt.admin {
+				*currentUser = user.User{Username: "root", Role: user.RoleAdmin}
+			}
+			cmd := NewDelUserCommand(store, currentUser)
+			cmd.SetPasswordReader(func(prompt string) (string, error) {
+				if tt.admin {
+					t.Errorf("Unexpected password prompt for an admin")
+				}
+				return tt.answer, nil
+			})
+
+			err := cmd.Execute(tt.args, &bytes.Buffer{})
+			if !errors.Is(err, tt.expectedErr) {
+				t.Fatalf("Execute() error = %v, wantErr %v", err, tt.expectedErr)
+			}
+			users, _ := store.ListUsers()
+			entries, _ := store.HistoryEntries(alice.ID)
+			if deleted := len(users) == 0 && len(entries) == 0; deleted != tt.wantDeleted {
+				t.Errorf("Expected deleted = %v, but %d users and %d entries remain", tt.wantDeleted, len(users), len(entries))
+			}
+			if tt.loggedIn && currentUser.Username != "" {
+				t.Errorf("Expected the session to be logged out, but got '%s'", currentUser.Username)
+			}
+		})
+	}
+}
+
+func TestDelUserCommand_VerificationCode(t *testing.T) {
+	store := user.NewMemoryStore()
+	alice := &user.User{Username: "alice", Password: "s3cret"}
+	if err := user.RegisterUser(store, alice); err != nil {
+		t.Fatalf("Failed to setup existing user: %v", err)
+	}
+	secret, _ := totp.GenerateSecret()
+	code, _ := totp.Code(secret, time.Now())
+	if err := user.EnableMFA(store, alice, secret, code); err != nil {
+		t.Fatalf("Failed to enable two-factor authentication: %v", err)
+	}
+
+	cmd := NewDelUserCommand(store, &user.User{})
+	if err := cmd.Execute([]string{"alice", "s3cret"}, &bytes.Buffer{}); !errors.Is(err, user.ErrCodeRequired) {
+		t.Fatalf("Execute() without a reader error = %v, wantErr %v", err, user.ErrCodeRequired)
+	}
+	cmd.SetPasswordReader(func(prompt string) (string, error) { return "000000", nil })
+	if err := cmd.Execute([]string{"alice", "s3cret"}, &bytes.Buffer{}); !errors.Is(err, user.ErrWrongCode) {
+		t.Fatalf("Execute() with a wrong code error = %v, wantErr %v", err, user.ErrWrongCode)
+	}
+	if _, err := store.FindUser("alice"); err != nil {
+		t.Fatalf("Expected alice to be kept without the code, got: %v", err)
+	}
+	if audits, _ := user.LoginHistory(store, "alice", 1); len(audits) != 1 || audits[0].Success {
+		t.Errorf("Expected the failed attempt to be audited, got %+v", audits)
+	}
+
+	cmd.SetPasswordReader(func(prompt string) (string, error) {
+		// A fresh step, since the enrollment code cannot be used again.
+		return totp.Code(secret, time.Now().Add(totp.Period))
+	})
+	if err := cmd.Execute([]string{"alice", "s3cret"}, &bytes.Buffer{}); err != nil {
+		t.Fatalf("Execute() unexpected error: %v", err)
+	}
+	if _, err := store.FindUser("alice"); !errors.Is(err, user.ErrUserNotFound) {
+		t.Errorf("Expected alice to be deleted, got: %v", err)
+	}
+}
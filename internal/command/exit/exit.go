@@ -2,27 +2,37 @@ package exit
 
 import (
 	user "asa/shell/internal/service"
+	"asa/shell/internal/session"
 	"asa/shell/utils"
 	"fmt"
 	"io"
 	"os"
 	"strconv"
-
-	"gorm.io/gorm"
 )
 
 type ExitCommand struct {
-	user *user.User
-	db   *gorm.DB
+	user     *user.User
+	store    user.UserStore
+	sessions *session.Stack
 }
 
-func NewExitCommand(db *gorm.DB, user *user.User) *ExitCommand {
+func NewExitCommand(store user.UserStore, user *user.User) *ExitCommand {
 	return &ExitCommand{
-		user: user,
-		db:   db,
+		user:  user,
+		store: store,
 	}
 }
 
+// SetSessions makes exit return to the sessions su suspended before
+// leaving the shell.
+func (c *ExitCommand) SetSessions(sessions *session.Stack) {
+	c.sessions = sessions
+}
+
+func (c *ExitCommand) nested() bool {
+	return c.sessions != nil && c.sessions.Depth() > 0
+}
+
 func (c *ExitCommand) Name() string {
 	return "exit"
 }
@@ -31,20 +41,26 @@ func (c *ExitCommand) Execute(args []string, stdout io.Writer) error {
 
 	switch len(args) {
 	case 0:
+		if c.nested() {
+			return c.sessions.Pop()
+		}
 		if c.user.Username != "" {
-			user.Update(c.db, c.user)
+			user.Update(c.store, c.user)
 		}
 		fmt.Fprintln(stdout, "exit status 0")
 		os.Exit(0)
 
 	case 1:
-		if c.user.Username != "" {
-			user.Update(c.db, c.user)
-		}
 		status, err := strconv.Atoi(args[0])
 		if err != nil {
 			return utils.ErrInvalidArgs
 		}
+		if c.nested() {
+			return c.sessions.Pop()
+		}
+		if c.user.Username != "" {
+			user.Update(c.store, c.user)
+		}
 		fmt.Fprintln(stdout, "exit status ", status)
 		os.Exit(0)
 	default:
@@ -5,18 +5,16 @@ import (
 	"errors"
 	"testing"
 
-	"gorm.io/gorm"
-
 	userSvc "asa/shell/internal/service"
 	"asa/shell/utils"
 )
 
-type MockDB struct {
-	*gorm.DB
+type MockStore struct {
+	userSvc.UserStore
 	UpdatedUser *userSvc.User
 }
 
-func (m *MockDB) Update(db *gorm.DB, user *userSvc.User) error {
+func (m *MockStore) UpdateUser(user *userSvc.User, fields ...string) error {
 	m.UpdatedUser = user
 	return nil
 }
@@ -81,8 +79,8 @@ func TestExitCommand_Execute_NoMockExit_InvalidArguments(t *testing.T) {
 
 	for _, tc := range testCases {
 		t.Run(tc.name, func(t *testing.T) {
-			mockDB := &MockDB{UpdatedUser: nil} // Mock DB if testing user update
-			cmd := NewExitCommand(mockDB.DB, tc.mockUser)
+			mockStore := &MockStore{UpdatedUser: nil} // Mock store if testing user update
+			cmd := NewExitCommand(mockStore, tc.mockUser)
 
 			var outBuf bytes.Buffer
 			err := cmd.Execute(tc.args, &outBuf)
@@ -97,11 +95,11 @@ func TestExitCommand_Execute_NoMockExit_InvalidArguments(t *testing.T) {
 			}
 
 			if tc.expectUserUpdate {
-				if mockDB.UpdatedUser == nil || mockDB.UpdatedUser.Username != tc.mockUser.Username {
+				if mockStore.UpdatedUser == nil || mockStore.UpdatedUser.Username != tc.mockUser.Username {
 					t.Errorf("Test case '%s': Expected user update but user was not updated, or incorrect user updated.", tc.name)
 				}
 			} else {
-				if mockDB.UpdatedUser != nil {
+				if mockStore.UpdatedUser != nil {
 					t.Errorf("Test case '%s': Did not expect user update, but user was updated.", tc.name)
 				}
 			}
@@ -113,8 +111,8 @@ func TestExitCommand_Execute_NoMockExit_InvalidArguments(t *testing.T) {
 
 func TestExitCommand_Name_NoMockExit(t *testing.T) {
 	mockUser := &userSvc.User{}
-	mockDB := &MockDB{}
-	cmd := NewExitCommand(mockDB.DB, mockUser)
+	mockStore := &MockStore{}
+	cmd := NewExitCommand(mockStore, mockUser)
 	if cmd.Name() != "exit" {
 		t.Errorf("Name() should return 'exit', but got '%s'", cmd.Name())
 	}
@@ -0,0 +1,58 @@
+package export
+
+import (
+	"asa/shell/internal/settings"
+	"asa/shell/utils"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+type ExportCommand struct {
+	session *settings.Session
+}
+
+func NewExportCommand(session *settings.Session) *ExportCommand {
+	return &ExportCommand{
+		session: session,
+	}
+}
+
+func (c *ExportCommand) Name() string {
+	return "export"
+}
+
+// Execute exports NAME=VALUE pairs, or the current value of a bare NAME.
+// With -n it removes the named variables instead; without arguments it
+// lists the variables exported in this session.
+func (c *ExportCommand) Execute(args []string, stdout io.Writer) error {
+	if len(args) == 0 {
+		for _, v := range c.session.Exported() {
+			fmt.Fprintf(stdout, "export %s=%q\n", v.Name, v.Value)
+		}
+		return nil
+	}
+	if args[0] == "-n" {
+		if len(args) == 1 {
+			return utils.ErrNotEnoughArgs
+		}
+		for _, name := range args[1:] {
+			if err := c.session.Unexport(name); err != nil {
+				return fmt.Errorf("%s: %w", name, err)
+			}
+		}
+		return nil
+	}
+
+	for _, arg := range args {
+		name, value, ok := strings.Cut(arg, "=")
+		if !ok {
+			value = os.Getenv(name)
+		}
+		if err := c.session.Export(name, value); err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,55 @@
+package export
+
+import (
+	"asa/shell/internal/settings"
+	"asa/shell/utils"
+	"bytes"
+	"errors"
+	"os"
+	"testing"
+)
+
+func TestExportCommand_Execute(t *testing.T) {
+	tests := []struct {
+		name        string
+		args        []string
+		want        string
+		wantEnv     map[string]string
+		expectedErr error
+	}{
+		{name: "Assignment", args: []string{"EXPORT_A=1", "EXPORT_B=two words"}, wantEnv: map[string]string{"EXPORT_A": "1", "EXPORT_B": "two words"}},
+		{name: "Bare name keeps value", args: []string{"EXPORT_KEPT"}, wantEnv: map[string]string{"EXPORT_KEPT": "kept"}},
+		{name: "Listing", args: []string{}, want: "export EXPORT_KEPT=\"kept\"\n"},
+		{name: "Remove", args: []string{"-n", "EXPORT_KEPT"}, wantEnv: map[string]string{"EXPORT_KEPT": ""}},
+		{name: "Remove without name", args: []string{"-n"}, expectedErr: utils.ErrNotEnoughArgs},
+		{name: "Invalid name", args: []string{"1X=y"}, expectedErr: settings.ErrInvalidName},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("EXPORT_KEPT", "kept")
+			session := settings.New()
+			if tt.name == "Listing" {
+				session.Export("EXPORT_KEPT", "kept")
+			}
+			for name := range tt.wantEnv {
+				t.Setenv(name, os.Getenv(name))
+			}
+
+			var buf bytes.Buffer
+			err := NewExportCommand(session).Execute(tt.args, &buf)
+			if !errors.Is(err, tt.expectedErr) {
+				t.Fatalf("Execute() error = %v, wantErr %v", err, tt.expectedErr)
+			}
+			if buf.String() != tt.want {
+				t.Errorf("Execute() output = %q, want %q", buf.String(), tt.want)
+			}
+			for name, value := range tt.wantEnv {
+				if got := os.Getenv(name); got != value {
+					t.Errorf("%s = %q, want %q", name, got, value)
+				}
+			}
+			session.Reset()
+		})
+	}
+}
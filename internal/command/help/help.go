@@ -28,13 +28,26 @@ func (c *HelpCommand) Execute(args []string, stdout io.Writer) error {
 		"cat":     {"see the content of the files", "cat <filename>"},
 		"pwd":     {"current directory path", "pwd"},
 		"type":    {"type of a command", "type <command>"},
-		"adduser": {"register user to shell", "adduser {username} {password | empty}"},
+		"adduser": {"register user to shell", "adduser [-p] {username} {password | empty}"},
 		"echo":    {"write text/variables to output", "echo <text>"},
-		"login":   {"login to shell as user", "login {username} {password | empty}"},
-		"logout":  {"logout the shell", "logout"},
-		"exit":    {"exit the shell", "exit [status code]"},
+		"login":   {"login to shell as user", "login [-p] {username} {password | empty}"},
+		"logout":  {"logout the shell, or return from su", "logout"},
+		"su":      {"switch user, keeping the current session", "su [-p] {username} {password | empty}"},
+		"passwd":  {"change a user's password", "passwd [username] [{current} {new}]"},
+		"deluser": {"delete a user and its history", "deluser {username} {password | empty}"},
+		"users":   {"list registered users", "users"},
+		"whoami":  {"print the logged-in user", "whoami"},
+		"lastlog": {"latest logins and login attempts", "lastlog [-n count] [username]"},
+		"mfa":     {"two-factor authentication", "mfa [status | enable | disable]"},
+		"perm":    {"manage roles and permissions", "perm [allow | deny | reset {role} {command} | role {username} {role}]"},
+		"export":  {"set environment variables", "export [-n] [NAME[=VALUE] ...]"},
+		"alias":   {"define or list aliases", "alias [name[=value] ...]"},
+		"unalias": {"remove aliases", "unalias {name ...}"},
+		"settings": {"show, set and save your settings", "settings [set {option} {value} | save | clear]"},
+		"exit":    {"exit the shell, or return from su", "exit [status code]"},
 		"color":   {"set on/off color mode", "color [on|off]"},
-		"history": {"history of executed commands", "history | history clean"},
+		"history": {"history of executed commands", "history [freq [N] | clean | stats [--json] [--top N] | export [--format bash|zsh-extended|json] | import [--format F] <file>]"},
+		"complete": {"register word list or program completions", "complete [-W words | -C command | -r | -p] <command>; no -F, the shell has no functions"},
 	}
 
 	fmt.Fprintln(stdout, "-------------------------------------------------------------------------------------------")
@@ -1,57 +1,332 @@
 package history
 
 import (
+	"asa/shell/internal/histfile"
+	"asa/shell/internal/histio"
+	"asa/shell/internal/histpolicy"
+	"asa/shell/internal/histstats"
 	user "asa/shell/internal/service"
 	"asa/shell/utils"
+	"fmt"
 	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
 
-	"gorm.io/gorm"
+const (
+	timeFormat = "2006-01-02 15:04:05"
+	defaultTop = 10
 )
 
 type HistoryCommand struct {
 	builtinHistory *map[string]int
+	builtinEntries *[]user.HistoryEntry
 	user           *user.User
-	db             *gorm.DB
+	store          user.UserStore
+	histFile       string
 }
 
-func NewHistoryCommand(builtinHistory *map[string]int, user *user.User, db *gorm.DB) *HistoryCommand {
+func NewHistoryCommand(builtinHistory *map[string]int, builtinEntries *[]user.HistoryEntry, user *user.User, store user.UserStore) *HistoryCommand {
 	return &HistoryCommand{
 		builtinHistory: builtinHistory,
+		builtinEntries: builtinEntries,
 		user:           user,
-		db:             db,
+		store:          store,
 	}
 }
 
+// SetHistFile sets the file backing the history of anonymous sessions.
+func (h *HistoryCommand) SetHistFile(path string) {
+	h.histFile = path
+}
+
 func (h *HistoryCommand) Name() string {
 	return "history"
 }
 
 func (h *HistoryCommand) Execute(args []string, stdout io.Writer) error {
+	if len(args) == 0 {
+		return h.list(stdout)
+	}
+	switch args[0] {
+	case "export":
+		return h.export(args[1:], stdout)
+	case "import":
+		return h.importFile(args[1:], stdout)
+	case "stats":
+		return h.stats(args[1:], stdout)
+	case "freq":
+		return h.frequency(args[1:], stdout)
+	}
+	if len(args) > 1 {
+		return utils.ErrInvalidArgs
+	}
+	switch args[0] {
+	case "clean":
+		return h.clean()
+	default:
+		return utils.ErrInvalidArgs
+	}
+}
+
+// list prints every execution, oldest first, numbered from 1.
+func (h *HistoryCommand) list(stdout io.Writer) error {
+	entries, err := h.entries()
+	if err != nil {
+		return err
+	}
+	if len(entries) == 0 {
+		return utils.ErrEmptyHistory
+	}
+	for i, entry := range entries {
+		fmt.Fprintf(stdout, "%5d  %s  %s\n", i+1, entry.StartedAt.Format(timeFormat), entry.Command)
+	}
+	return nil
+}
+
+// frequency prints the lines run most, all of them or the first N. The
+// counts of a logged-in user are saved first and ranked by the store, so
+// those of their other sessions are included.
+func (h *HistoryCommand) frequency(args []string, stdout io.Writer) error {
 	if len(args) > 1 {
 		return utils.ErrInvalidArgs
 	}
+	limit := 0
 	if len(args) == 1 {
-		if args[0] != "clean" {
+		n, err := strconv.Atoi(args[0])
+		if err != nil || n <= 0 {
 			return utils.ErrInvalidArgs
 		}
-		if h.user.Username != "" {
-			h.user.HistoryMap = map[string]int{}
-			err := user.Update(h.db, h.user)
+		limit = n
+	}
+	var counts []user.HistoryCount
+	if h.user.Username != "" {
+		if err := user.SyncHistory(h.store, h.user, false); err != nil {
+			return err
+		}
+		top, err := user.TopHistory(h.store, h.user.ID, limit)
+		if err != nil {
 			return err
 		}
-		*h.builtinHistory = map[string]int{}
-		return nil
+		counts = top
+	} else {
+		counts = utils.SortCounts(*h.builtinHistory)
+		if limit > 0 && len(counts) > limit {
+			counts = counts[:limit]
+		}
+	}
+	if len(counts) == 0 {
+		return utils.ErrEmptyHistory
 	}
+	utils.PrintHistoryCounts(counts, stdout)
+	return nil
+}
+
+func (h *HistoryCommand) clean() error {
 	if h.user.Username != "" {
-		if len(h.user.HistoryMap) == 0 {
-			return utils.ErrEmptyHistory
+		if err := user.ClearHistory(h.store, h.user); err != nil {
+			return err
 		}
-		utils.PrintSortedMap(h.user.HistoryMap, stdout)
-		return nil
+		return user.ClearHistoryEntries(h.store, h.user.ID)
+	}
+	*h.builtinHistory = map[string]int{}
+	if h.builtinEntries != nil {
+		*h.builtinEntries = nil
+	}
+	if h.histFile != "" {
+		return histfile.Clear(h.histFile)
+	}
+	return nil
+}
+
+// stats summarizes the chronological history as tables, or as JSON with
+// --json. --top limits each ranking, 10 entries by default.
+func (h *HistoryCommand) stats(args []string, stdout io.Writer) error {
+	asJSON := false
+	top := defaultTop
+	for i := 0; i < len(args); i++ {
+		switch args[i] {
+		case "--json":
+			asJSON = true
+		case "--top":
+			if i+1 == len(args) {
+				return utils.ErrNotEnoughArgs
+			}
+			i++
+			n, err := strconv.Atoi(args[i])
+			if err != nil || n < 0 {
+				return utils.ErrInvalidArgs
+			}
+			top = n
+		default:
+			return utils.ErrInvalidArgs
+		}
+	}
+
+	entries, err := h.entries()
+	if err != nil {
+		return err
 	}
-	if len(*h.builtinHistory) == 0 {
+	if len(entries) == 0 {
 		return utils.ErrEmptyHistory
 	}
-	utils.PrintSortedMap(*h.builtinHistory, stdout)
+	stats := histstats.Compute(entries, top)
+	if asJSON {
+		return stats.WriteJSON(stdout)
+	}
+	stats.WriteTable(stdout)
 	return nil
 }
+
+// export writes the whole history to stdout, in bash format by default.
+func (h *HistoryCommand) export(args []string, stdout io.Writer) error {
+	format, rest, err := parseFormat(args, histio.Bash)
+	if err != nil {
+		return err
+	}
+	if len(rest) > 0 {
+		return utils.ErrTooManyArgs
+	}
+	entries, err := h.entries()
+	if err != nil {
+		return err
+	}
+	return histio.Encode(stdout, format, histio.History{Counts: h.counts(), Entries: entries})
+}
+
+// importFile merges a history file into the current history. The counts
+// are added to the existing ones and the recording policy applies to the
+// imported lines as it does to typed ones.
+func (h *HistoryCommand) importFile(args []string, stdout io.Writer) error {
+	format, rest, err := parseFormat(args, "")
+	if err != nil {
+		return err
+	}
+	if len(rest) == 0 {
+		return utils.ErrNotEnoughArgs
+	}
+	if len(rest) > 1 {
+		return utils.ErrTooManyArgs
+	}
+
+	file, err := os.Open(rest[0])
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	imported, err := histio.Decode(file, format)
+	if err != nil {
+		return err
+	}
+	imported = applyPolicy(imported)
+
+	if h.user.Username != "" {
+		err = h.mergeUser(imported)
+	} else {
+		err = h.mergeBuiltin(imported)
+	}
+	if err != nil {
+		return err
+	}
+
+	total := 0
+	for _, count := range imported.Counts {
+		total += count
+	}
+	fmt.Fprintf(stdout, "imported %d commands\n", total)
+	return nil
+}
+
+// mergeUser adds imported to the stored history of the logged-in user and
+// picks up the counts saved by other sessions meanwhile.
+func (h *HistoryCommand) mergeUser(imported histio.History) error {
+	if h.user.HistoryMap == nil {
+		h.user.HistoryMap = map[string]int{}
+	}
+	for line, count := range imported.Counts {
+		h.user.HistoryMap[line] += count
+	}
+	if err := user.SyncHistory(h.store, h.user, true); err != nil {
+		return err
+	}
+
+	for i := range imported.Entries {
+		imported.Entries[i].UserID = h.user.ID
+	}
+	return user.AddHistoryEntries(h.store, imported.Entries)
+}
+
+func (h *HistoryCommand) mergeBuiltin(imported histio.History) error {
+	for line, count := range imported.Counts {
+		(*h.builtinHistory)[line] += count
+	}
+	if h.builtinEntries != nil {
+		entries := append(*h.builtinEntries, imported.Entries...)
+		sort.SliceStable(entries, func(i, j int) bool {
+			return entries[i].StartedAt.Before(entries[j].StartedAt)
+		})
+		*h.builtinEntries = entries
+	}
+	if h.histFile == "" {
+		return nil
+	}
+	_, fileSize := histfile.Limits()
+	return histfile.Append(h.histFile, imported, fileSize)
+}
+
+// applyPolicy redacts imported lines and drops the ignored ones.
+func applyPolicy(imported histio.History) histio.History {
+	policy, _ := histpolicy.FromEnv()
+	filtered := histio.History{Counts: map[string]int{}}
+	for line, count := range imported.Counts {
+		if line, ok := policy.Filter(line, ""); ok {
+			filtered.Counts[line] += count
+		}
+	}
+	for _, entry := range imported.Entries {
+		if line, ok := policy.Filter(entry.Command, ""); ok {
+			entry.Command = line
+			filtered.Entries = append(filtered.Entries, entry)
+		}
+	}
+	return filtered
+}
+
+// parseFormat extracts a --format option from args.
+func parseFormat(args []string, format string) (string, []string, error) {
+	rest := []string{}
+	for i := 0; i < len(args); i++ {
+		switch {
+		case args[i] == "--format":
+			if i+1 == len(args) {
+				return "", nil, utils.ErrNotEnoughArgs
+			}
+			i++
+			format = args[i]
+		case strings.HasPrefix(args[i], "--format="):
+			format = strings.TrimPrefix(args[i], "--format=")
+		default:
+			rest = append(rest, args[i])
+		}
+	}
+	return format, rest, nil
+}
+
+func (h *HistoryCommand) counts() map[string]int {
+	if h.user.Username != "" {
+		return h.user.HistoryMap
+	}
+	return *h.builtinHistory
+}
+
+func (h *HistoryCommand) entries() ([]user.HistoryEntry, error) {
+	if h.user.Username != "" {
+		return user.GetHistoryEntries(h.store, h.user.ID)
+	}
+	if h.builtinEntries == nil {
+		return nil, nil
+	}
+	return *h.builtinEntries, nil
+}
@@ -1,14 +1,14 @@
 package history
 
 import (
-	db "asa/shell/internal/database"
 	userSvc "asa/shell/internal/service"
 	"asa/shell/utils"
 	"bytes"
 	"errors"
+	"os"
+	"strings"
 	"testing"
-
-	"gorm.io/gorm"
+	"time"
 )
 
 func TestHistoryCommand_Execute(t *testing.T) {
@@ -17,7 +17,7 @@ func TestHistoryCommand_Execute(t *testing.T) {
 		args                  []string
 		builtinHistory        *map[string]int
 		user                  *userSvc.User
-		db                    *gorm.DB
+		store                 userSvc.UserStore
 		wantErr               error
 		builtinHistoryCleaned bool
 	}{
@@ -49,7 +49,7 @@ func TestHistoryCommand_Execute(t *testing.T) {
 				Username:   "testuser",
 				HistoryMap: map[string]int{"cmd1": 1, "cmd2": 2},
 			},
-			db:      db.GetDB(),
+			store:   userSvc.NewMemoryStore(),
 			wantErr: nil,
 		},
 		{
@@ -59,7 +59,7 @@ func TestHistoryCommand_Execute(t *testing.T) {
 				Username:   "testuser",
 				HistoryMap: map[string]int{"cmd1": 1, "cmd2": 2},
 			},
-			db:      db.GetDB(),
+			store:   userSvc.NewMemoryStore(),
 			wantErr: nil,
 		},
 		{
@@ -73,7 +73,7 @@ func TestHistoryCommand_Execute(t *testing.T) {
 		},
 		{
 			name: "Non-empty user history",
-			args: []string{},
+			args: []string{"freq"},
 			user: &userSvc.User{
 				Username:   "testuser",
 				HistoryMap: map[string]int{"cmd1": 1, "cmd2": 2, "cmd3": 3},
@@ -82,13 +82,13 @@ func TestHistoryCommand_Execute(t *testing.T) {
 		},
 		{
 			name:           "Empty builtin history",
-			args:           []string{},
+			args:           []string{"freq"},
 			builtinHistory: &map[string]int{},
 			wantErr:        utils.ErrEmptyHistory,
 		},
 		{
 			name:           "Non-empty builtin history",
-			args:           []string{},
+			args:           []string{"freq"},
 			builtinHistory: &map[string]int{"cmd1": 1, "cmd2": 2, "cmd3": 3},
 			wantErr:        nil,
 		},
@@ -107,7 +107,7 @@ func TestHistoryCommand_Execute(t *testing.T) {
 			h := &HistoryCommand{
 				builtinHistory: &builtinHistoryCopy,
 				user:           tt.user,
-				db:             tt.db,
+				store:          tt.store,
 			}
 			var stdout bytes.Buffer
 			builtinHistoryInitial := make(map[string]int)
@@ -117,16 +117,16 @@ func TestHistoryCommand_Execute(t *testing.T) {
 				}
 			}
 
-			hDb := db.GetDB()
-			h.db = hDb
+			hStore := userSvc.NewMemoryStore()
+			h.store = hStore
 			var testUser userSvc.User
-			testUser, err := userSvc.GetUser(hDb, "testuser", "")
+			testUser, err := userSvc.GetUser(hStore, "testuser", "")
 			if err == nil {
 				testUser.HistoryMap = make(map[string]int)
 			} else {
 				testUser = userSvc.User{Username: "testuser"}
-				userSvc.RegisterUser(hDb, &testUser)
-				testUser, _ = userSvc.GetUser(hDb, "testuser", "")
+				userSvc.RegisterUser(hStore, &testUser)
+				testUser, _ = userSvc.GetUser(hStore, "testuser", "")
 			}
 			if tt.user == nil || tt.user.Username == "" {
 				testUser.Username = ""
@@ -152,8 +152,338 @@ func TestHistoryCommand_Execute(t *testing.T) {
 }
 
 func TestHistoryCommand_Name(t *testing.T) {
-	h := NewHistoryCommand(nil, nil, nil)
+	h := NewHistoryCommand(nil, nil, nil, nil)
 	if h.Name() != "history" {
 		t.Errorf("Name() should return 'history', got %v", h.Name())
 	}
 }
+
+func TestHistoryCommand_List(t *testing.T) {
+	startedAt := time.Date(2025, 3, 1, 9, 30, 0, 0, time.Local)
+	entries := []userSvc.HistoryEntry{
+		{Command: "ls -l", StartedAt: startedAt},
+		{Command: "cd ..", StartedAt: startedAt.Add(time.Minute), ExitStatus: 1},
+		{Command: "ls -l", StartedAt: startedAt.Add(2 * time.Minute)},
+	}
+	wantOutput := "    1  2025-03-01 09:30:00  ls -l\n" +
+		"    2  2025-03-01 09:31:00  cd ..\n" +
+		"    3  2025-03-01 09:32:00  ls -l\n"
+
+	t.Run("Anonymous session", func(t *testing.T) {
+		builtinEntries := append([]userSvc.HistoryEntry{}, entries...)
+		h := NewHistoryCommand(&map[string]int{}, &builtinEntries, &userSvc.User{}, nil)
+
+		var stdout bytes.Buffer
+		if err := h.Execute([]string{}, &stdout); err != nil {
+			t.Fatalf("Execute() unexpected error: %v", err)
+		}
+		if stdout.String() != wantOutput {
+			t.Errorf("Execute() output = %q, want %q", stdout.String(), wantOutput)
+		}
+	})
+
+	t.Run("Logged in user", func(t *testing.T) {
+		testStore := userSvc.NewMemoryStore()
+
+		u := &userSvc.User{Username: "history_list"}
+		if err := userSvc.RegisterUser(testStore, u); err != nil {
+			t.Fatalf("Failed to setup user: %v", err)
+		}
+		other := &userSvc.User{Username: "history_other"}
+		if err := userSvc.RegisterUser(testStore, other); err != nil {
+			t.Fatalf("Failed to setup user: %v", err)
+		}
+		for i := len(entries) - 1; i >= 0; i-- {
+			entry := entries[i]
+			entry.UserID = u.ID
+			if err := userSvc.AddHistoryEntry(testStore, &entry); err != nil {
+				t.Fatalf("Failed to setup history: %v", err)
+			}
+		}
+		if err := userSvc.AddHistoryEntry(testStore, &userSvc.HistoryEntry{UserID: other.ID, Command: "whoami", StartedAt: startedAt}); err != nil {
+			t.Fatalf("Failed to setup history: %v", err)
+		}
+
+		h := NewHistoryCommand(&map[string]int{}, nil, u, testStore)
+		var stdout bytes.Buffer
+		if err := h.Execute([]string{}, &stdout); err != nil {
+			t.Fatalf("Execute() unexpected error: %v", err)
+		}
+		if stdout.String() != wantOutput {
+			t.Errorf("Execute() output = %q, want %q", stdout.String(), wantOutput)
+		}
+
+		u.HistoryMap = map[string]int{"ls -l": 2, "cd ..": 1}
+		if err := h.Execute([]string{"clean"}, &stdout); err != nil {
+			t.Fatalf("Execute(clean) unexpected error: %v", err)
+		}
+		if err := h.Execute([]string{}, &stdout); !errors.Is(err, utils.ErrEmptyHistory) {
+			t.Errorf("Execute() after clean error = %v, want %v", err, utils.ErrEmptyHistory)
+		}
+		left, _ := userSvc.GetHistoryEntries(testStore, other.ID)
+		if len(left) != 1 {
+			t.Errorf("clean removed other users' history, %d entries left, want 1", len(left))
+		}
+	})
+
+	t.Run("Empty anonymous history", func(t *testing.T) {
+		h := NewHistoryCommand(&map[string]int{}, &[]userSvc.HistoryEntry{}, &userSvc.User{}, nil)
+		if err := h.Execute([]string{}, &bytes.Buffer{}); !errors.Is(err, utils.ErrEmptyHistory) {
+			t.Errorf("Execute() error = %v, want %v", err, utils.ErrEmptyHistory)
+		}
+	})
+
+	t.Run("Frequency view", func(t *testing.T) {
+		h := NewHistoryCommand(&map[string]int{"ls -l": 2}, nil, &userSvc.User{}, nil)
+		var stdout bytes.Buffer
+		if err := h.Execute([]string{"freq"}, &stdout); err != nil {
+			t.Fatalf("Execute(freq) unexpected error: %v", err)
+		}
+		if !strings.Contains(stdout.String(), "| ls -l              | 2     |") {
+			t.Errorf("Execute(freq) output = %q, want the frequency table", stdout.String())
+		}
+	})
+}
+
+func TestHistoryCommand_Frequency(t *testing.T) {
+	testStore := userSvc.NewMemoryStore()
+	u := &userSvc.User{Username: "history_freq"}
+	if err := userSvc.RegisterUser(testStore, u); err != nil {
+		t.Fatalf("Failed to setup user: %v", err)
+	}
+	// Counts saved by another session and counts this session has not
+	// saved yet.
+	if err := testStore.AddHistory(u.ID, map[string]int{"pwd": 5, "cd ..": 1}); err != nil {
+		t.Fatalf("Failed to setup history: %v", err)
+	}
+	u.HistoryMap = map[string]int{"ls -l": 2, "cd ..": 1}
+	h := NewHistoryCommand(&map[string]int{}, nil, u, testStore)
+
+	tests := []struct {
+		name       string
+		args       []string
+		wantOutput []string
+		wantErr    error
+	}{
+		{
+			name:       "All lines",
+			args:       []string{"freq"},
+			wantOutput: []string{"pwd", "cd ..", "ls -l"},
+		},
+		{
+			name:       "Most run lines",
+			args:       []string{"freq", "2"},
+			wantOutput: []string{"pwd", "cd .."},
+		},
+		{name: "Invalid limit", args: []string{"freq", "0"}, wantErr: utils.ErrInvalidArgs},
+		{name: "Too many arguments", args: []string{"freq", "1", "2"}, wantErr: utils.ErrInvalidArgs},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var stdout bytes.Buffer
+			err := h.Execute(tt.args, &stdout)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Execute(%v) error = %v, wantErr %v", tt.args, err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			var got []string
+			for _, line := range strings.Split(stdout.String(), "\n") {
+				if fields := strings.Split(line, "|"); len(fields) == 4 && !strings.Contains(line, "Command") {
+					got = append(got, strings.TrimSpace(fields[1]))
+				}
+			}
+			if strings.Join(got, ",") != strings.Join(tt.wantOutput, ",") {
+				t.Errorf("Execute(%v) lines = %q, want %q", tt.args, got, tt.wantOutput)
+			}
+		})
+	}
+}
+
+func TestHistoryCommand_ExportImport(t *testing.T) {
+	for _, name := range []string{"HISTCONTROL", "HISTIGNORE", "HISTREDACT"} {
+		original, set := os.LookupEnv(name)
+		defer func(name string) {
+			if set {
+				os.Setenv(name, original)
+			} else {
+				os.Unsetenv(name)
+			}
+		}(name)
+		os.Unsetenv(name)
+	}
+
+	startedAt := time.Unix(1700000000, 0)
+	counts := map[string]int{"ls -l": 2, "pwd": 1}
+	entries := []userSvc.HistoryEntry{
+		{Command: "ls -l", StartedAt: startedAt},
+	}
+	h := NewHistoryCommand(&counts, &entries, &userSvc.User{}, nil)
+
+	t.Run("Export bash", func(t *testing.T) {
+		var stdout bytes.Buffer
+		if err := h.Execute([]string{"export"}, &stdout); err != nil {
+			t.Fatalf("Execute(export) unexpected error: %v", err)
+		}
+		want := "ls -l\npwd\n#1700000000\nls -l\n"
+		if stdout.String() != want {
+			t.Errorf("Execute(export) output = %q, want %q", stdout.String(), want)
+		}
+	})
+
+	t.Run("Export unknown format", func(t *testing.T) {
+		if err := h.Execute([]string{"export", "--format", "fish"}, &bytes.Buffer{}); err == nil {
+			t.Error("Execute(export --format fish) expected an error")
+		}
+	})
+
+	t.Run("Import merges counts", func(t *testing.T) {
+		path := t.TempDir() + "/zsh_history"
+		content := ": 1600000000:0;ls -l\n: 1800000000:1;login bob s3cret\n"
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to write history file: %v", err)
+		}
+
+		var stdout bytes.Buffer
+		if err := h.Execute([]string{"import", "--format=zsh-extended", path}, &stdout); err != nil {
+			t.Fatalf("Execute(import) unexpected error: %v", err)
+		}
+		if stdout.String() != "imported 2 commands\n" {
+			t.Errorf("Execute(import) output = %q", stdout.String())
+		}
+
+		want := map[string]int{"ls -l": 3, "pwd": 1, "login bob ***": 1}
+		if len(counts) != len(want) || counts["ls -l"] != 3 || counts["login bob ***"] != 1 {
+			t.Errorf("counts after import = %v, want %v", counts, want)
+		}
+		commands := []string{}
+		for _, entry := range entries {
+			commands = append(commands, entry.Command)
+		}
+		if strings.Join(commands, ",") != "ls -l,ls -l,login bob ***" {
+			t.Errorf("entries after import = %q, want them merged in time order", commands)
+		}
+	})
+
+	t.Run("Import missing file", func(t *testing.T) {
+		if err := h.Execute([]string{"import", t.TempDir() + "/missing"}, &bytes.Buffer{}); !errors.Is(err, os.ErrNotExist) {
+			t.Errorf("Execute(import) error = %v, want %v", err, os.ErrNotExist)
+		}
+	})
+
+	t.Run("Import without file", func(t *testing.T) {
+		if err := h.Execute([]string{"import"}, &bytes.Buffer{}); !errors.Is(err, utils.ErrNotEnoughArgs) {
+			t.Errorf("Execute(import) error = %v, want %v", err, utils.ErrNotEnoughArgs)
+		}
+	})
+}
+
+func TestHistoryCommand_ImportUser(t *testing.T) {
+	testStore := userSvc.NewMemoryStore()
+
+	u := &userSvc.User{Username: "history_import"}
+	if err := userSvc.RegisterUser(testStore, u); err != nil {
+		t.Fatalf("Failed to setup user: %v", err)
+	}
+	stored := *u
+	stored.HistoryMap = map[string]int{"make": 4}
+	if err := userSvc.Update(testStore, &stored); err != nil {
+		t.Fatalf("Failed to setup history: %v", err)
+	}
+	u.HistoryMap = map[string]int{"pwd": 1}
+
+	path := t.TempDir() + "/bash_history"
+	if err := os.WriteFile(path, []byte("make\n#1700000000\ngit status\n"), 0644); err != nil {
+		t.Fatalf("Failed to write history file: %v", err)
+	}
+
+	h := NewHistoryCommand(&map[string]int{}, nil, u, testStore)
+	if err := h.Execute([]string{"import", path}, &bytes.Buffer{}); err != nil {
+		t.Fatalf("Execute(import) unexpected error: %v", err)
+	}
+
+	want := map[string]int{"make": 5, "pwd": 1, "git status": 1}
+	got, err := userSvc.GetUser(testStore, u.Username, "")
+	if err != nil {
+		t.Fatalf("GetUser() unexpected error: %v", err)
+	}
+	for line, count := range want {
+		if got.HistoryMap[line] != count || u.HistoryMap[line] != count {
+			t.Errorf("count of %q = %d stored, %d in session, want %d", line, got.HistoryMap[line], u.HistoryMap[line], count)
+		}
+	}
+	entries, _ := userSvc.GetHistoryEntries(testStore, u.ID)
+	if len(entries) != 1 || entries[0].Command != "git status" {
+		t.Errorf("entries after import = %+v, want the timed line", entries)
+	}
+}
+
+func TestHistoryCommand_HistFile(t *testing.T) {
+	path := t.TempDir() + "/history"
+	if err := os.WriteFile(path, []byte(": 1700000000:0;ls\n"), 0600); err != nil {
+		t.Fatalf("Failed to write history file: %v", err)
+	}
+	importPath := t.TempDir() + "/bash_history"
+	if err := os.WriteFile(importPath, []byte("#1600000000\nmake\n"), 0644); err != nil {
+		t.Fatalf("Failed to write history file: %v", err)
+	}
+
+	counts := map[string]int{"ls": 1}
+	entries := []userSvc.HistoryEntry{{Command: "ls", StartedAt: time.Unix(1700000000, 0)}}
+	h := NewHistoryCommand(&counts, &entries, &userSvc.User{}, nil)
+	h.SetHistFile(path)
+
+	if err := h.Execute([]string{"import", importPath}, &bytes.Buffer{}); err != nil {
+		t.Fatalf("Execute(import) unexpected error: %v", err)
+	}
+	content, _ := os.ReadFile(path)
+	if string(content) != ": 1700000000:0;ls\n: 1600000000:0;make\n" {
+		t.Errorf("history file after import = %q, want the imported entry appended", content)
+	}
+
+	if err := h.Execute([]string{"clean"}, &bytes.Buffer{}); err != nil {
+		t.Fatalf("Execute(clean) unexpected error: %v", err)
+	}
+	if content, _ := os.ReadFile(path); len(content) != 0 {
+		t.Errorf("history file after clean = %q, want it empty", content)
+	}
+}
+
+func TestHistoryCommand_Stats(t *testing.T) {
+	entries := []userSvc.HistoryEntry{
+		{Command: "make", StartedAt: time.Now(), Cwd: "/src", ExitStatus: 2},
+		{Command: "make", StartedAt: time.Now(), Cwd: "/src"},
+	}
+	h := NewHistoryCommand(&map[string]int{"make": 2}, &entries, &userSvc.User{}, nil)
+
+	tests := []struct {
+		name    string
+		args    []string
+		want    string
+		wantErr error
+	}{
+		{name: "Table", args: []string{"stats"}, want: "| make               | 2     | 1      |  50.0% |"},
+		{name: "JSON", args: []string{"stats", "--json", "--top", "1"}, want: `"failure_rate": 0.5`},
+		{name: "Bad top", args: []string{"stats", "--top", "x"}, wantErr: utils.ErrInvalidArgs},
+		{name: "Missing top", args: []string{"stats", "--top"}, wantErr: utils.ErrNotEnoughArgs},
+		{name: "Unknown option", args: []string{"stats", "--csv"}, wantErr: utils.ErrInvalidArgs},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var stdout bytes.Buffer
+			err := h.Execute(tt.args, &stdout)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Execute() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !strings.Contains(stdout.String(), tt.want) {
+				t.Errorf("Execute() output = %s\nwant it to contain %q", stdout.String(), tt.want)
+			}
+		})
+	}
+
+	empty := NewHistoryCommand(&map[string]int{}, &[]userSvc.HistoryEntry{}, &userSvc.User{}, nil)
+	if err := empty.Execute([]string{"stats"}, &bytes.Buffer{}); !errors.Is(err, utils.ErrEmptyHistory) {
+		t.Errorf("Execute(stats) on empty history error = %v, want %v", err, utils.ErrEmptyHistory)
+	}
+}
@@ -0,0 +1,88 @@
+package lastlog
+
+import (
+	user "asa/shell/internal/service"
+	"asa/shell/utils"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+const (
+	timeFormat   = "2006-01-02 15:04:05"
+	defaultLimit = 10
+)
+
+type LastlogCommand struct {
+	store user.UserStore
+	user  *user.User
+}
+
+func NewLastlogCommand(store user.UserStore, user *user.User) *LastlogCommand {
+	return &LastlogCommand{
+		store: store,
+		user:  user,
+	}
+}
+
+func (c *LastlogCommand) Name() string {
+	return "lastlog"
+}
+
+// Execute prints the latest login of every account or, given a username,
+// its last login attempts. Only admins may look at other accounts'
+// attempts.
+func (c *LastlogCommand) Execute(args []string, stdout io.Writer) error {
+	limit := defaultLimit
+	if len(args) >= 2 && args[0] == "-n" {
+		n, err := strconv.Atoi(args[1])
+		if err != nil || n <= 0 {
+			return utils.ErrUnvalidArg
+		}
+		limit = n
+		args = args[2:]
+	}
+	switch len(args) {
+	case 0:
+		return c.summary(stdout)
+	case 1:
+		if args[0] != c.user.Username && !user.IsAdmin(c.user) {
+			return user.ErrPermissionDenied
+		}
+		return c.attempts(args[0], limit, stdout)
+	default:
+		return utils.ErrInvalidArgs
+	}
+}
+
+func (c *LastlogCommand) summary(stdout io.Writer) error {
+	logins, err := user.LastLogins(c.store)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(stdout, "%-16s %-19s  %-16s  %s\n", "Username", "Latest", "Session", "Failed since")
+	for _, login := range logins {
+		if login.Last.ID == 0 {
+			fmt.Fprintf(stdout, "%-16s %-37s  %d\n", login.Username, "**Never logged in**", login.Failures)
+			continue
+		}
+		fmt.Fprintf(stdout, "%-16s %-19s  %-16s  %d\n",
+			login.Username, login.Last.At.Local().Format(timeFormat), login.Last.SessionID, login.Failures)
+	}
+	return nil
+}
+
+func (c *LastlogCommand) attempts(username string, limit int, stdout io.Writer) error {
+	audits, err := user.LoginHistory(c.store, username, limit)
+	if err != nil {
+		return err
+	}
+	for _, audit := range audits {
+		result := "success"
+		if !audit.Success {
+			result = "failure: " + audit.Reason
+		}
+		fmt.Fprintf(stdout, "%s  %-16s  %s\n", audit.At.Local().Format(timeFormat), audit.SessionID, result)
+	}
+	return nil
+}
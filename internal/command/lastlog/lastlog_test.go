@@ -0,0 +1,56 @@
+package lastlog
+
+import (
+	user "asa/shell/internal/service"
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestLastlogCommand_Execute(t *testing.T) {
+	store := user.NewMemoryStore()
+	for _, name := range []string{"alice", "bob"} {
+		if err := user.RegisterUser(store, &user.User{Username: name, Password: "s3cret"}); err != nil {
+			t.Fatalf("Failed to setup user: %v", err)
+		}
+	}
+	user.Authenticate(store, "alice", "wrong", "", "session1")
+	alice, err := user.Authenticate(store, "alice", "s3cret", "", "session1")
+	if err != nil {
+		t.Fatalf("Failed to log in: %v", err)
+	}
+	user.Authenticate(store, "bob", "wrong", "", "session2")
+
+	tests := []struct {
+		name        string
+		user        *user.User
+		args        []string
+		want        []string
+		wantLines   int
+		expectedErr error
+	}{
+		{name: "Summary", user: &alice, want: []string{"alice", "session1", "bob", "Never logged in"}, wantLines: 3},
+		{name: "Own attempts", user: &alice, args: []string{"-n", "1", "alice"}, want: []string{"session1", "success"}, wantLines: 1},
+		{name: "Other attempts", user: &alice, args: []string{"bob"}, expectedErr: user.ErrPermissionDenied},
+		{name: "Admin sees other attempts", user: &user.User{Username: "root", Role: user.RoleAdmin}, args: []string{"bob"}, want: []string{"failure: wrong password"}, wantLines: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			err := NewLastlogCommand(store, tt.user).Execute(tt.args, &buf)
+			if !errors.Is(err, tt.expectedErr) {
+				t.Fatalf("Execute() error = %v, wantErr %v", err, tt.expectedErr)
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(buf.String(), want) {
+					t.Errorf("Execute() output = %q, want it to contain %q", buf.String(), want)
+				}
+			}
+			if lines := strings.Count(buf.String(), "\n"); lines != tt.wantLines {
+				t.Errorf("Execute() printed %d lines, want %d", lines, tt.wantLines)
+			}
+		})
+	}
+}
@@ -1,25 +1,38 @@
 package login
 
 import (
+	"asa/shell/internal/command"
 	userService "asa/shell/internal/service"
 	"asa/shell/utils"
 	"io"
-
-	"gorm.io/gorm"
+	"os"
 )
 
 type LoginCommand struct {
-	db   *gorm.DB
-	user *userService.User
+	store        userService.UserStore
+	user         *userService.User
+	readPassword command.PasswordReader
+	sessionID    string
 }
 
-func NewLoginCommand(db *gorm.DB, user *userService.User) *LoginCommand {
+func NewLoginCommand(store userService.UserStore, user *userService.User) *LoginCommand {
 	return &LoginCommand{
-		db:   db,
-		user: user,
+		store: store,
+		user:  user,
 	}
 }
 
+// SetPasswordReader sets how passwords left off the command line are read.
+// Without one such logins fail as before.
+func (c *LoginCommand) SetPasswordReader(readPassword command.PasswordReader) {
+	c.readPassword = readPassword
+}
+
+// SetSessionID sets the session the login attempts are audited under.
+func (c *LoginCommand) SetSessionID(sessionID string) {
+	c.sessionID = sessionID
+}
+
 func (c *LoginCommand) Name() string {
 	return "login"
 }
@@ -28,31 +41,48 @@ func (c *LoginCommand) Execute(args []string, stdout io.Writer) error {
 	// if (*c.user).Username != "" {
 	// 	return ErrLoggedin
 	// }
+	prompt := len(args) > 0 && args[0] == "-p"
+	if prompt {
+		args = args[1:]
+	}
 	if len(args) == 0 {
 		return utils.ErrUsernameRequired
 	}
-	if len(args) > 2 {
+	if len(args) > 2 || (prompt && len(args) == 2) {
 		return utils.ErrInvalidArgs
 	}
 	var pass string
 	if len(args) == 2 {
 		pass = args[1]
 	}
-	user, err := userService.GetUser(c.db, args[0], pass)
+	if prompt {
+		if c.readPassword == nil {
+			return userService.ErrPassRequired
+		}
+		var err error
+		if pass, err = c.readPassword("Password: "); err != nil {
+			return err
+		}
+	}
+	user, err := c.readPassword.Authenticate(c.store, args[0], pass, c.sessionID)
 	if err != nil {
 		return err
 	}
+	if err := userService.EnsureHome(c.store, &user); err != nil {
+		return err
+	}
+	// The previous user is saved first so that a failure leaves the shell
+	// as it was.
 	if c.user.Username != "" {
-		err := userService.Update(c.db, c.user)
+		err := userService.Update(c.store, c.user)
 		if err != nil {
 			return err
 		}
 	}
-	(*c.user).ID = user.ID
-	(*c.user).Username = user.Username
-	(*c.user).Password = user.Password
-	(*c.user).History = user.History
-	(*c.user).HistoryMap = user.HistoryMap
+	if err := os.Chdir(user.HomeDir); err != nil {
+		return err
+	}
+	*c.user = user
 
 	return nil
 }
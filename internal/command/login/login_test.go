@@ -2,43 +2,30 @@ package login
 
 import (
 	user "asa/shell/internal/service"
+	"asa/shell/internal/totp"
 	"asa/shell/utils"
 	"bytes"
 	"errors"
 	"os"
+	"path/filepath"
 	"testing"
-
-	"gorm.io/driver/sqlite"
-	"gorm.io/gorm"
+	"time"
 )
 
-func setupTestDB(t *testing.T) *gorm.DB {
-	t.Helper()
-	db, err := gorm.Open(sqlite.Open("file::memory:?cache=shared"), &gorm.Config{})
-	if err != nil {
-		t.Fatalf("failed to connect to test database: %v", err)
-	}
-
-	err = db.AutoMigrate(&user.User{})
-	if err != nil {
-		t.Fatalf("failed to migrate database: %v", err)
-	}
-	return db
-}
-
-func teardownTestDB(t *testing.T, db *gorm.DB) {
+func setupTestStore(t *testing.T) user.UserStore {
 	t.Helper()
-	sqlDB, _ := db.DB()
-	sqlDB.Close()
-	os.Remove("file::memory:?cache=shared")
+	t.Setenv("SHELL_HOME_BASE", t.TempDir())
+	// Logging in moves into the home directory.
+	wd, _ := os.Getwd()
+	t.Cleanup(func() { os.Chdir(wd) })
+	return user.NewMemoryStore()
 }
 
 func TestLoginCommand_Name(t *testing.T) {
-	db := setupTestDB(t)
-	defer teardownTestDB(t, db)
+	store := setupTestStore(t)
 
 	currentUser := &user.User{}
-	cmd := NewLoginCommand(db, currentUser)
+	cmd := NewLoginCommand(store, currentUser)
 
 	if cmd.Name() != "login" {
 		t.Errorf("Name() should return 'login', but got '%s'", cmd.Name())
@@ -49,17 +36,17 @@ func TestLoginCommand_Execute(t *testing.T) {
 	tests := []struct {
 		name        string
 		args        []string
-		setupDB     func(db *gorm.DB)
+		setupStore     func(store user.UserStore)
 		expectedErr error
 		assertUser  func(t *testing.T, currentUser *user.User, expectedError error)
 	}{
 		{
 			name: "Valid username and password",
 			args: []string{"testuser", "password123"},
-			setupDB: func(db *gorm.DB) {
+			setupStore: func(store user.UserStore) {
 				hashedPassword := "password123"
 				existingUser := &user.User{Username: "testuser", Password: hashedPassword}
-				err := user.RegisterUser(db, existingUser)
+				err := user.RegisterUser(store, existingUser)
 				if err != nil {
 					t.Fatalf("Failed to setup existing user: %v", err)
 				}
@@ -69,8 +56,8 @@ func TestLoginCommand_Execute(t *testing.T) {
 				if currentUser.Username != "testuser" {
 					t.Errorf("Expected current user username to be 'testuser', but got '%s'", currentUser.Username)
 				}
-				if currentUser.Password != "password123" {
-					t.Errorf("Expected current user password to be 'password123', but got '%s'", currentUser.Password)
+				if !user.CheckPassword(currentUser.Password, "password123") {
+					t.Errorf("Expected current user password to match 'password123', but got '%s'", currentUser.Password)
 				}
 				if currentUser.ID == 0 {
 					t.Errorf("Expected current user ID to be set (not 0)")
@@ -83,9 +70,9 @@ func TestLoginCommand_Execute(t *testing.T) {
 		{
 			name: "Valid username, no password provided (empty password in DB)",
 			args: []string{"testuser"},
-			setupDB: func(db *gorm.DB) {
+			setupStore: func(store user.UserStore) {
 				existingUser := &user.User{Username: "testuser", Password: ""}
-				err := user.RegisterUser(db, existingUser)
+				err := user.RegisterUser(store, existingUser)
 				if err != nil {
 					t.Fatalf("Failed to setup existing user: %v", err)
 				}
@@ -109,7 +96,7 @@ func TestLoginCommand_Execute(t *testing.T) {
 		{
 			name: "Invalid username",
 			args: []string{"nonexistentuser", "password"},
-			setupDB: func(db *gorm.DB) {
+			setupStore: func(store user.UserStore) {
 			},
 			expectedErr: errors.New("user not found"), 
 			assertUser: func(t *testing.T, currentUser *user.User, expectedError error) {
@@ -127,10 +114,10 @@ func TestLoginCommand_Execute(t *testing.T) {
 		{
 			name: "Wrong password",
 			args: []string{"testuser", "wrongpassword"},
-			setupDB: func(db *gorm.DB) {
+			setupStore: func(store user.UserStore) {
 				hashedPassword := "password123"
 				existingUser := &user.User{Username: "testuser", Password: hashedPassword}
-				err := user.RegisterUser(db, existingUser)
+				err := user.RegisterUser(store, existingUser)
 				if err != nil {
 					t.Fatalf("Failed to setup existing user: %v", err)
 				}
@@ -151,7 +138,7 @@ func TestLoginCommand_Execute(t *testing.T) {
 		{
 			name:        "Missing username",
 			args:        []string{},
-			setupDB:     func(db *gorm.DB) {},
+			setupStore:     func(store user.UserStore) {},
 			expectedErr: utils.ErrUsernameRequired,
 			assertUser: func(t *testing.T, currentUser *user.User, expectedError error) {
 				if currentUser.Username != "" {
@@ -165,7 +152,7 @@ func TestLoginCommand_Execute(t *testing.T) {
 		{
 			name:        "Too many arguments",
 			args:        []string{"user", "pass", "extra"},
-			setupDB:     func(db *gorm.DB) {},
+			setupStore:     func(store user.UserStore) {},
 			expectedErr: utils.ErrInvalidArgs,
 			assertUser: func(t *testing.T, currentUser *user.User, expectedError error) {
 				if currentUser.Username != "" {
@@ -180,15 +167,14 @@ func TestLoginCommand_Execute(t *testing.T) {
 
 	for _, cmdTest := range tests {
 		t.Run(cmdTest.name, func(t *testing.T) {
-			db := setupTestDB(t)
-			defer teardownTestDB(t, db)
+			store := setupTestStore(t)
 
 			currentUser := &user.User{} 
-			cmdWithDB := NewLoginCommand(db, currentUser)
-			cmdTest.setupDB(db)
+			cmdWithStore := NewLoginCommand(store, currentUser)
+			cmdTest.setupStore(store)
 
 			var buf bytes.Buffer
-			err := cmdWithDB.Execute(cmdTest.args, &buf)
+			err := cmdWithStore.Execute(cmdTest.args, &buf)
 
 			if err != nil && cmdTest.expectedErr != nil {
 				if err.Error() != cmdTest.expectedErr.Error() {
@@ -200,3 +186,148 @@ func TestLoginCommand_Execute(t *testing.T) {
 		})
 	}
 }
+
+func TestLoginCommand_PromptPassword(t *testing.T) {
+	store := setupTestStore(t)
+
+	if err := user.RegisterUser(store, &user.User{Username: "prompted", Password: "s3cret"}); err != nil {
+		t.Fatalf("Failed to setup existing user: %v", err)
+	}
+
+	tests := []struct {
+		name        string
+		args        []string
+		answer      string
+		wantPrompts int
+		expectedErr error
+	}{
+		{name: "Omitted password is prompted", args: []string{"prompted"}, answer: "s3cret", wantPrompts: 1},
+		{name: "Prompt flag", args: []string{"-p", "prompted"}, answer: "s3cret", wantPrompts: 1},
+		{name: "Wrong prompted password", args: []string{"prompted"}, answer: "wrong", wantPrompts: 1, expectedErr: user.ErrWrongPassword},
+		{name: "Password given on the command line", args: []string{"prompted", "s3cret"}},
+		{name: "Prompt flag with password", args: []string{"-p", "prompted", "s3cret"}, expectedErr: utils.ErrInvalidArgs},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			currentUser := &user.User{}
+			cmd := NewLoginCommand(store, currentUser)
+			prompts := 0
+			cmd.SetPasswordReader(func(prompt string) (string, error) {
+				prompts++
+				return tt.answer, nil
+			})
+
+			err := cmd.Execute(tt.args, &bytes.Buffer{})
+			if !errors.Is(err, tt.expectedErr) {
+				t.Fatalf("Execute() error = %v, wantErr %v", err, tt.expectedErr)
+			}
+			if prompts != tt.wantPrompts {
+				t.Errorf("Execute() prompted %d times, want %d", prompts, tt.wantPrompts)
+			}
+			if tt.expectedErr == nil && currentUser.Username != "prompted" {
+				t.Errorf("Expected current user to be 'prompted', but got '%s'", currentUser.Username)
+			}
+		})
+	}
+}
+
+func TestLoginCommand_VerificationCode(t *testing.T) {
+	store := setupTestStore(t)
+
+	account := &user.User{Username: "mfa", Password: "s3cret"}
+	if err := user.RegisterUser(store, account); err != nil {
+		t.Fatalf("Failed to setup existing user: %v", err)
+	}
+	secret, _ := totp.GenerateSecret()
+	code, _ := totp.Code(secret, time.Now())
+	if err := user.EnableMFA(store, account, secret, code); err != nil {
+		t.Fatalf("Failed to enable two-factor authentication: %v", err)
+	}
+
+	currentUser := &user.User{}
+	cmd := NewLoginCommand(store, currentUser)
+	if err := cmd.Execute([]string{"mfa", "s3cret"}, &bytes.Buffer{}); !errors.Is(err, user.ErrCodeRequired) {
+		t.Fatalf("Execute() without a reader error = %v, wantErr %v", err, user.ErrCodeRequired)
+	}
+
+	prompts := []string{}
+	cmd.SetPasswordReader(func(prompt string) (string, error) {
+		prompts = append(prompts, prompt)
+		if prompt == "Password: " {
+			return "s3cret", nil
+		}
+		// A fresh step, since the enrollment code cannot be used again.
+		return totp.Code(secret, time.Now().Add(totp.Period))
+	})
+	if err := cmd.Execute([]string{"mfa"}, &bytes.Buffer{}); err != nil {
+		t.Fatalf("Execute() unexpected error: %v", err)
+	}
+	if len(prompts) != 2 || currentUser.Username != "mfa" {
+		t.Errorf("Execute() prompted %q and logged in '%s', want both prompts and the user", prompts, currentUser.Username)
+	}
+}
+
+func TestLoginCommand_MovesHome(t *testing.T) {
+	store := setupTestStore(t)
+
+	// Accounts created before homes existed get one on their next login.
+	if err := user.RegisterUser(store, &user.User{Username: "legacy"}); err != nil {
+		t.Fatalf("Failed to setup existing user: %v", err)
+	}
+	currentUser := &user.User{}
+	if err := NewLoginCommand(store, currentUser).Execute([]string{"legacy"}, &bytes.Buffer{}); err != nil {
+		t.Fatalf("Execute() unexpected error: %v", err)
+	}
+
+	want := filepath.Join(os.Getenv("SHELL_HOME_BASE"), "legacy")
+	if currentUser.HomeDir != want {
+		t.Errorf("Expected home directory '%s', but got '%s'", want, currentUser.HomeDir)
+	}
+	wd, _ := os.Getwd()
+	if resolved, _ := filepath.EvalSymlinks(want); wd != resolved {
+		t.Errorf("Expected working directory '%s', but got '%s'", resolved, wd)
+	}
+}
+
+// failingStore fails to save history, which Update does before anything
+// else.
+type failingStore struct {
+	user.UserStore
+	err error
+}
+
+func (s failingStore) Transaction(fn func(store user.UserStore) error) error {
+	return s.UserStore.Transaction(func(tx user.UserStore) error {
+		return fn(failingStore{tx, s.err})
+	})
+}
+
+func (s failingStore) AddHistory(userID int64, delta map[string]int) error {
+	return s.err
+}
+
+func TestLoginCommand_SaveError(t *testing.T) {
+	saveErr := errors.New("failed to update history in database")
+	store := failingStore{setupTestStore(t), saveErr}
+	for _, name := range []string{"previous", "next"} {
+		if err := user.RegisterUser(store, &user.User{Username: name}); err != nil {
+			t.Fatalf("Failed to setup existing user: %v", err)
+		}
+	}
+	previous, _ := user.GetUser(store, "previous", "")
+	previous.HistoryMap["ls"]++
+	currentUser := &previous
+	wd, _ := os.Getwd()
+
+	err := NewLoginCommand(store, currentUser).Execute([]string{"next"}, &bytes.Buffer{})
+	if !errors.Is(err, saveErr) {
+		t.Fatalf("Execute() error = %v, want %v", err, saveErr)
+	}
+	if currentUser.Username != "previous" {
+		t.Errorf("Expected 'previous' to stay logged in, but got '%s'", currentUser.Username)
+	}
+	if after, _ := os.Getwd(); after != wd {
+		t.Errorf("Expected working directory '%s' to be kept, but got '%s'", wd, after)
+	}
+}
@@ -2,24 +2,30 @@ package logout
 
 import (
 	user "asa/shell/internal/service"
+	"asa/shell/internal/session"
 	"asa/shell/utils"
 	"io"
-
-	"gorm.io/gorm"
 )
 
 type LogoutCommand struct {
-	db   *gorm.DB
-	user *user.User
+	store    user.UserStore
+	user     *user.User
+	sessions *session.Stack
 }
 
-func NewLogoutCommand(db *gorm.DB, user *user.User) *LogoutCommand {
+func NewLogoutCommand(store user.UserStore, user *user.User) *LogoutCommand {
 	return &LogoutCommand{
-		db:   db,
-		user: user,
+		store: store,
+		user:  user,
 	}
 }
 
+// SetSessions makes logout return to the sessions su suspended instead of
+// leaving an anonymous session.
+func (c *LogoutCommand) SetSessions(sessions *session.Stack) {
+	c.sessions = sessions
+}
+
 func (c *LogoutCommand) Name() string {
 	return "logout"
 }
@@ -28,8 +34,11 @@ func (c *LogoutCommand) Execute(args []string, stdout io.Writer) error {
 	if len(args) > 0 {
 		return utils.ErrInvalidArgs
 	}
+	if c.sessions != nil && c.sessions.Depth() > 0 {
+		return c.sessions.Pop()
+	}
 
-	err := user.Update(c.db, c.user)
+	err := user.Update(c.store, c.user)
 	if err != nil {
 		return err
 	}
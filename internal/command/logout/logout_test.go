@@ -1,13 +1,11 @@
 package logout
 
 import (
-	db "asa/shell/internal/database"
 	user "asa/shell/internal/service"
 	"asa/shell/utils"
 	"bytes"
 	"errors"
 	"reflect"
-	"strings"
 	"testing"
 )
 
@@ -19,9 +17,9 @@ type MockUser struct {
 }
 
 func TestNewLogoutCommand(t *testing.T) {
-	db := db.GetDB()
+	store := user.NewMemoryStore()
 	cmd := NewLogoutCommand(
-		db,
+		store,
 		&user.User{Username: "test"},
 	)
 
@@ -40,9 +38,9 @@ func TestNewLogoutCommand(t *testing.T) {
 }
 
 func TestLogoutCommand_Name(t *testing.T) {
-	db := db.GetDB()
+	store := user.NewMemoryStore()
 	cmd := NewLogoutCommand(
-		db,
+		store,
 		&user.User{},
 	)
 
@@ -54,9 +52,9 @@ func TestLogoutCommand_Name(t *testing.T) {
 }
 
 func TestLogoutCommand_Execute_InvalidArgs(t *testing.T) {
-	db := db.GetDB()
+	store := user.NewMemoryStore()
 	cmd := NewLogoutCommand(
-		db,
+		store,
 		&user.User{},
 	)
 
@@ -69,10 +67,20 @@ func TestLogoutCommand_Execute_InvalidArgs(t *testing.T) {
 	}
 }
 
+// failingStore fails every transaction with err.
+type failingStore struct {
+	user.UserStore
+	err error
+}
+
+func (s failingStore) Transaction(fn func(store user.UserStore) error) error {
+	return s.err
+}
+
 func TestLogoutCommand_Execute_UpdateError(t *testing.T) {
-	db := db.GetDB()
+	updateErr := errors.New("failed to update user in database")
 	cmd := NewLogoutCommand(
-		db,
+		failingStore{user.NewMemoryStore(), updateErr},
 		&user.User{Username: "testuser"},
 	)
 
@@ -81,26 +89,26 @@ func TestLogoutCommand_Execute_UpdateError(t *testing.T) {
 	err := cmd.Execute(args, &stdout)
 
 	if err == nil {
-		t.Errorf("Execute should return error when user.Update fails, but got nil")
+		t.Fatalf("Execute should return error when user.Update fails, but got nil")
 	}
-	if !strings.Contains(err.Error(), "failed to update user in database: ERROR: duplicate key value violates unique constraint") {
-		t.Errorf("Execute should return the error from user.Update, got: %v, want: %v", err, errors.New("failed to update user in database: ERROR: duplicate key value violates unique constraint"))
+	if !errors.Is(err, updateErr) {
+		t.Errorf("Execute should return the error from user.Update, got: %v, want: %v", err, updateErr)
 	}
 }
 
 func TestLogoutCommand_Execute_Success(t *testing.T) {
-	db := db.GetDB()
+	store := user.NewMemoryStore()
 	var testUser user.User
-	testUser, err := user.GetUser(db, "testuser", "")
+	testUser, err := user.GetUser(store, "testuser", "")
 	if err == nil {
 		testUser.HistoryMap = make(map[string]int)
 	} else {
 		testUser = user.User{Username: "testuser"}
-		user.RegisterUser(db, &testUser)
-		testUser, _ = user.GetUser(db, "testuser", "")
+		user.RegisterUser(store, &testUser)
+		testUser, _ = user.GetUser(store, "testuser", "")
 	}
 	cmd := NewLogoutCommand(
-		db,
+		store,
 		&testUser,
 	)
 
@@ -125,18 +133,18 @@ func TestLogoutCommand_Execute_Success(t *testing.T) {
 }
 
 func TestLogoutCommand_Execute_Success_Stdout(t *testing.T) {
-	db := db.GetDB()
+	store := user.NewMemoryStore()
 	var testUser user.User
-	testUser, err := user.GetUser(db, "testuser", "")
+	testUser, err := user.GetUser(store, "testuser", "")
 	if err == nil {
 		testUser.HistoryMap = make(map[string]int)
 	} else {
 		testUser = user.User{Username: "testuser"}
-		user.RegisterUser(db, &testUser)
-		testUser, _ = user.GetUser(db, "testuser", "")
+		user.RegisterUser(store, &testUser)
+		testUser, _ = user.GetUser(store, "testuser", "")
 	}
 	cmd := NewLogoutCommand(
-		db,
+		store,
 		&testUser,
 	)
 
@@ -156,17 +164,18 @@ func TestLogoutCommand_Execute_Success_Stdout(t *testing.T) {
 }
 
 func TestLogoutCommand_Execute_Success_Update(t *testing.T) {
-	db := db.GetDB()
+	store := user.NewMemoryStore()
 	var testUser user.User
-	testUser, err := user.GetUser(db, "testuser", "")
+	testUser, err := user.GetUser(store, "testuser", "")
 	if err == nil {
 		testUser.HistoryMap = make(map[string]int)
 	} else {
 		testUser = user.User{Username: "testuser"}
-		user.RegisterUser(db, &testUser)
+		user.RegisterUser(store, &testUser)
+		testUser, _ = user.GetUser(store, "testuser", "")
 	}
 	cmd := NewLogoutCommand(
-		db,
+		store,
 		&testUser,
 	)
 	args := []string{}
@@ -179,7 +188,7 @@ func TestLogoutCommand_Execute_Success_Update(t *testing.T) {
 	}
 
 	expectedHistoryMap := map[string]int{"testcommand": 1}
-	testUserAfterLogout, err := user.GetUser(db, "testuser", "")
+	testUserAfterLogout, err := user.GetUser(store, "testuser", "")
 	if err != nil {
 		t.Errorf("user not exist")
 	}
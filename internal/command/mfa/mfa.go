@@ -0,0 +1,106 @@
+package mfa
+
+import (
+	"asa/shell/internal/command"
+	user "asa/shell/internal/service"
+	"asa/shell/internal/totp"
+	"asa/shell/utils"
+	"fmt"
+	"io"
+)
+
+// issuer names the shell in authenticator apps.
+const issuer = "shell"
+
+type MFACommand struct {
+	store        user.UserStore
+	user         *user.User
+	readPassword command.PasswordReader
+}
+
+func NewMFACommand(store user.UserStore, user *user.User) *MFACommand {
+	return &MFACommand{
+		store: store,
+		user:  user,
+	}
+}
+
+// SetPasswordReader sets how verification codes are read.
+func (c *MFACommand) SetPasswordReader(readPassword command.PasswordReader) {
+	c.readPassword = readPassword
+}
+
+func (c *MFACommand) Name() string {
+	return "mfa"
+}
+
+// Execute shows, enables or disables the one-time password second factor
+// of the logged-in user.
+func (c *MFACommand) Execute(args []string, stdout io.Writer) error {
+	if c.user.Username == "" {
+		return utils.ErrNotLoggedIn
+	}
+	if len(args) > 1 {
+		return utils.ErrInvalidArgs
+	}
+	subcommand := "status"
+	if len(args) == 1 {
+		subcommand = args[0]
+	}
+
+	switch subcommand {
+	case "status":
+		status := "disabled"
+		if user.MFAEnabled(c.user) {
+			status = "enabled"
+		}
+		_, err := fmt.Fprintf(stdout, "two-factor authentication is %s\n", status)
+		return err
+	case "enable":
+		return c.enable(stdout)
+	case "disable":
+		code, err := c.readCode()
+		if err != nil {
+			return err
+		}
+		if err := user.DisableMFA(c.store, c.user, code); err != nil {
+			return err
+		}
+		_, err = fmt.Fprintln(stdout, "two-factor authentication disabled")
+		return err
+	default:
+		return utils.ErrUnvalidArg
+	}
+}
+
+// enable shows a new secret and turns it on once the user typed a code
+// from an authenticator set up with it.
+func (c *MFACommand) enable(stdout io.Writer) error {
+	if user.MFAEnabled(c.user) {
+		return user.ErrMFAEnabled
+	}
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(stdout, "Secret: %s\n", secret)
+	fmt.Fprintf(stdout, "URI:    %s\n", totp.URI(issuer, c.user.Username, secret))
+	fmt.Fprintln(stdout, "Add it to your authenticator app, then enter the code it shows.")
+
+	code, err := c.readCode()
+	if err != nil {
+		return err
+	}
+	if err := user.EnableMFA(c.store, c.user, secret, code); err != nil {
+		return err
+	}
+	_, err = fmt.Fprintln(stdout, "two-factor authentication enabled")
+	return err
+}
+
+func (c *MFACommand) readCode() (string, error) {
+	if c.readPassword == nil {
+		return "", user.ErrCodeRequired
+	}
+	return c.readPassword("Verification code: ")
+}
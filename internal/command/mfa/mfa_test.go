@@ -0,0 +1,55 @@
+package mfa
+
+import (
+	user "asa/shell/internal/service"
+	"asa/shell/internal/totp"
+	"asa/shell/utils"
+	"bytes"
+	"errors"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMFACommand_Execute(t *testing.T) {
+	store := user.NewMemoryStore()
+	currentUser := &user.User{Username: "alice"}
+	if err := user.RegisterUser(store, currentUser); err != nil {
+		t.Fatalf("Failed to setup user: %v", err)
+	}
+
+	var out bytes.Buffer
+	cmd := NewMFACommand(store, currentUser)
+	// The reader answers with the code of the secret the command printed.
+	cmd.SetPasswordReader(func(prompt string) (string, error) {
+		secret := regexp.MustCompile(`Secret: (\w+)`).FindStringSubmatch(out.String())
+		if secret == nil {
+			return "000000", nil
+		}
+		return totp.Code(secret[1], time.Now())
+	})
+
+	if err := cmd.Execute([]string{"enable"}, &out); err != nil {
+		t.Fatalf("Execute(enable) unexpected error: %v", err)
+	}
+	if !strings.Contains(out.String(), "otpauth://totp/shell:alice?") {
+		t.Errorf("Execute(enable) output = %q, want the otpauth URI", out.String())
+	}
+	stored, _ := user.FindUser(store, "alice")
+	if !user.MFAEnabled(&stored) || !user.MFAEnabled(currentUser) {
+		t.Fatalf("Expected two-factor authentication to be enabled")
+	}
+
+	out.Reset()
+	if err := cmd.Execute(nil, &out); err != nil || out.String() != "two-factor authentication is enabled\n" {
+		t.Errorf("Execute(status) = %q, %v", out.String(), err)
+	}
+	out.Reset()
+	if err := cmd.Execute([]string{"disable"}, &out); !errors.Is(err, user.ErrWrongCode) {
+		t.Errorf("Execute(disable) with a wrong code error = %v, want %v", err, user.ErrWrongCode)
+	}
+	if err := NewMFACommand(store, &user.User{}).Execute(nil, &out); !errors.Is(err, utils.ErrNotLoggedIn) {
+		t.Errorf("Execute() anonymously error = %v, want %v", err, utils.ErrNotLoggedIn)
+	}
+}
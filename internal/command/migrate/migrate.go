@@ -0,0 +1,92 @@
+package migrate
+
+import (
+	"asa/shell/internal/database"
+	"asa/shell/utils"
+	"fmt"
+	"io"
+	"strconv"
+
+	"gorm.io/gorm"
+)
+
+const timeFormat = "2006-01-02 15:04:05"
+
+type MigrateCommand struct {
+	db *gorm.DB
+}
+
+func NewMigrateCommand(db *gorm.DB) *MigrateCommand {
+	return &MigrateCommand{
+		db: db,
+	}
+}
+
+func (c *MigrateCommand) Name() string {
+	return "migrate"
+}
+
+// Execute runs "status", "up [n]" applying the next n pending migrations,
+// all by default, or "down [n]" reverting the last n, one by default.
+func (c *MigrateCommand) Execute(args []string, stdout io.Writer) error {
+	if len(args) == 0 {
+		return utils.ErrNotEnoughArgs
+	}
+	if len(args) > 2 || (args[0] == "status" && len(args) > 1) {
+		return utils.ErrInvalidArgs
+	}
+	steps := 0
+	if args[0] == "down" {
+		steps = 1
+	}
+	if len(args) == 2 {
+		n, err := strconv.Atoi(args[1])
+		if err != nil || n <= 0 {
+			return utils.ErrUnvalidArg
+		}
+		steps = n
+	}
+
+	switch args[0] {
+	case "status":
+		return c.status(stdout)
+	case "up":
+		done, err := database.MigrateUp(c.db, steps)
+		report(stdout, "applied", done)
+		if err == nil && len(done) == 0 {
+			fmt.Fprintln(stdout, "database is up to date")
+		}
+		return err
+	case "down":
+		done, err := database.MigrateDown(c.db, steps)
+		report(stdout, "reverted", done)
+		if err == nil && len(done) == 0 {
+			fmt.Fprintln(stdout, "no migration to revert")
+		}
+		return err
+	default:
+		return utils.ErrInvalidArgs
+	}
+}
+
+func (c *MigrateCommand) status(stdout io.Writer) error {
+	statuses, err := database.Status(c.db)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(stdout, "%-8s %-24s %s\n", "Version", "Name", "Applied")
+	for _, status := range statuses {
+		applied := "pending"
+		if !status.AppliedAt.IsZero() {
+			applied = status.AppliedAt.Local().Format(timeFormat)
+		}
+		fmt.Fprintf(stdout, "%-8d %-24s %s\n", status.Version, status.Name, applied)
+	}
+	return nil
+}
+
+func report(stdout io.Writer, verb string, migrations []database.Migration) {
+	for _, migration := range migrations {
+		fmt.Fprintf(stdout, "%s %04d_%s\n", verb, migration.Version, migration.Name)
+	}
+}
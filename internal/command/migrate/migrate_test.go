@@ -0,0 +1,59 @@
+package migrate
+
+import (
+	"asa/shell/utils"
+	"bytes"
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func TestMigrateCommand_Execute(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "test.db")), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("failed to connect to test database: %v", err)
+	}
+	t.Cleanup(func() {
+		sqlDB, _ := db.DB()
+		sqlDB.Close()
+	})
+	cmd := NewMigrateCommand(db)
+	if cmd.Name() != "migrate" {
+		t.Errorf("Name() should return 'migrate', but got '%s'", cmd.Name())
+	}
+
+	tests := []struct {
+		args    []string
+		want    string
+		wantErr error
+	}{
+		{args: []string{"status"}, want: "initial_schema           pending"},
+		{args: []string{"up"}, want: "applied 0001_initial_schema"},
+		{args: []string{"up"}, want: "database is up to date"},
+		{args: []string{"down"}, want: "reverted 0002_history_counts"},
+		{args: []string{"down", "2"}, want: "reverted 0001_initial_schema"},
+		{args: []string{"down", "2"}, want: "no migration to revert"},
+		{args: []string{"up", "1"}, want: "applied 0001_initial_schema"},
+		{args: nil, wantErr: utils.ErrNotEnoughArgs},
+		{args: []string{"sideways"}, wantErr: utils.ErrInvalidArgs},
+		{args: []string{"status", "1"}, wantErr: utils.ErrInvalidArgs},
+		{args: []string{"down", "0"}, wantErr: utils.ErrUnvalidArg},
+	}
+
+	for _, tt := range tests {
+		t.Run(strings.Join(tt.args, " "), func(t *testing.T) {
+			var buf bytes.Buffer
+			err := cmd.Execute(tt.args, &buf)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Execute(%v) error = %v, wantErr %v", tt.args, err, tt.wantErr)
+			}
+			if !strings.Contains(buf.String(), tt.want) {
+				t.Errorf("Execute(%v) output = %q, want it to contain %q", tt.args, buf.String(), tt.want)
+			}
+		})
+	}
+}
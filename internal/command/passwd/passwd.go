@@ -0,0 +1,101 @@
+package passwd
+
+import (
+	"asa/shell/internal/command"
+	user "asa/shell/internal/service"
+	"asa/shell/utils"
+	"errors"
+	"fmt"
+	"io"
+)
+
+type PasswdCommand struct {
+	store        user.UserStore
+	user         *user.User
+	readPassword command.PasswordReader
+	sessionID    string
+}
+
+func NewPasswdCommand(store user.UserStore, user *user.User) *PasswdCommand {
+	return &PasswdCommand{
+		store: store,
+		user:  user,
+	}
+}
+
+// SetPasswordReader sets how passwords left off the command line are read.
+func (c *PasswdCommand) SetPasswordReader(readPassword command.PasswordReader) {
+	c.readPassword = readPassword
+}
+
+// SetSessionID sets the session the authentication is audited under.
+func (c *PasswdCommand) SetSessionID(sessionID string) {
+	c.sessionID = sessionID
+}
+
+func (c *PasswdCommand) Name() string {
+	return "passwd"
+}
+
+// Execute changes a password once the account is authenticated like login
+// does, verification code included. Without arguments it changes the
+// password of the logged-in user; the passwords are prompted for unless
+// both are given. Admins reset the passwords of other accounts without
+// knowing them.
+func (c *PasswdCommand) Execute(args []string, stdout io.Writer) error {
+	if len(args) == 2 || len(args) > 3 {
+		return utils.ErrInvalidArgs
+	}
+	name := c.user.Username
+	if len(args) > 0 {
+		name = args[0]
+	}
+	if name == "" {
+		return utils.ErrUsernameRequired
+	}
+	prompt := len(args) != 3
+	if prompt && c.readPassword == nil {
+		return user.ErrPassRequired
+	}
+
+	reset := user.IsAdmin(c.user) && name != c.user.Username
+
+	var current, next string
+	if !prompt {
+		current, next = args[1], args[2]
+	}
+	var account user.User
+	var err error
+	if reset {
+		account, err = user.FindUser(c.store, name)
+	} else {
+		if prompt {
+			_, err = user.GetUser(c.store, name, "")
+			if errors.Is(err, user.ErrPassRequired) {
+				current, err = c.readPassword("Current password: ")
+			}
+			if err != nil {
+				return err
+			}
+		}
+		account, err = c.readPassword.Authenticate(c.store, name, current, c.sessionID)
+	}
+	if err != nil {
+		return err
+	}
+	if prompt {
+		if next, err = c.readPassword.Confirm(); err != nil {
+			return err
+		}
+	}
+
+	if err := user.SetPassword(c.store, &account, next); err != nil {
+		return err
+	}
+	// Keep the session's copy of the account current.
+	if c.user.ID == account.ID {
+		c.user.Password = account.Password
+	}
+	_, err = fmt.Fprintf(stdout, "password updated for %s\n", name)
+	return err
+}
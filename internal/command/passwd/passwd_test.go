@@ -0,0 +1,148 @@
+package passwd
+
+import (
+	"asa/shell/internal/command/logout"
+	user "asa/shell/internal/service"
+	"asa/shell/internal/totp"
+	"asa/shell/utils"
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPasswdCommand_Execute(t *testing.T) {
+	tests := []struct {
+		name        string
+		args        []string
+		loggedIn    bool
+		admin       bool
+		answers     []string
+		expectedErr error
+		wantPass    string
+	}{
+		{name: "Passwords on the command line", args: []string{"alice", "old", "new"}, wantPass: "new"},
+		{name: "Wrong current password", args: []string{"alice", "bad", "new"}, expectedErr: user.ErrWrongPassword, wantPass: "old"},
+		{name: "Prompted passwords", args: []string{"alice"}, answers: []string{"old", "new", "new"}, wantPass: "new"},
+		{name: "Prompted for the logged-in user", loggedIn: true, answers: []string{"old", "new", "new"}, wantPass: "new"},
+		{name: "Mismatched confirmation", args: []string{"alice"}, answers: []string{"old", "new", "neu"}, expectedErr: utils.ErrPasswordMismatch, wantPass: "old"},
+		{name: "Not logged in", expectedErr: utils.ErrUsernameRequired, wantPass: "old"},
+		{name: "Admin reset", args: []string{"alice"}, admin: true, answers: []string{"new", "new"}, wantPass: "new"},
+		{name: "Missing new password", args: []string{"alice", "old"}, expectedErr: utils.ErrInvalidArgs, wantPass: "old"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := user.NewMemoryStore()
+			if err := user.RegisterUser(store, &user.User{Username: "alice", Password: "old"}); err != nil {
+				t.Fatalf("Failed to setup existing user: %v", err)
+			}
+			currentUser := &user.User{}
+			if tt.loggedIn {
+				u, err := user.GetUser(store, "alice", "old")
+				if err != nil {
+					t.Fatalf("Failed to log in: %v", err)
+				}
+				*currentUser = u
+			}
+			if tt.admin {
+				*currentUser = user.User{Username: "root", Role: user.RoleAdmin}
+			}
+			answers := tt.answers
+			cmd := NewPasswdCommand(store, currentUser)
+			cmd.SetPasswordReader(func(prompt string) (string, error) {
+				answer := answers[0]
+				answers = answers[1:]
+				return answer, nil
+			})
+
+			err := cmd.Execute(tt.args, &bytes.Buffer{})
+			if !errors.Is(err, tt.expectedErr) {
+				t.Fatalf("Execute() error = %v, wantErr %v", err, tt.expectedErr)
+			}
+			if _, err := user.GetUser(store, "alice", tt.wantPass); err != nil {
+				t.Errorf("Expected password '%s' to be stored, but got: %v", tt.wantPass, err)
+			}
+			if tt.loggedIn && !user.CheckPassword(currentUser.Password, tt.wantPass) {
+				t.Errorf("Expected the session to hold the new password")
+			}
+		})
+	}
+}
+
+func TestPasswdCommand_OtherSessionLogout(t *testing.T) {
+	store := user.NewMemoryStore()
+	if err := user.RegisterUser(store, &user.User{Username: "alice", Password: "old"}); err != nil {
+		t.Fatalf("Failed to setup existing user: %v", err)
+	}
+	first, err := user.GetUser(store, "alice", "old")
+	if err != nil {
+		t.Fatalf("Failed to log in: %v", err)
+	}
+	second := first
+
+	// The second session changes the password, then the first one, which
+	// still holds the old hash, logs out.
+	if err := NewPasswdCommand(store, &second).Execute([]string{"alice", "old", "new"}, &bytes.Buffer{}); err != nil {
+		t.Fatalf("Execute() unexpected error: %v", err)
+	}
+	if err := logout.NewLogoutCommand(store, &first).Execute(nil, &bytes.Buffer{}); err != nil {
+		t.Fatalf("logout unexpected error: %v", err)
+	}
+
+	if _, err := user.GetUser(store, "alice", "new"); err != nil {
+		t.Errorf("GetUser() with the new password after the other session's logout: %v", err)
+	}
+	if _, err := user.GetUser(store, "alice", "old"); !errors.Is(err, user.ErrWrongPassword) {
+		t.Errorf("GetUser() with the old password error = %v, want %v", err, user.ErrWrongPassword)
+	}
+}
+
+func TestPasswdCommand_VerificationCode(t *testing.T) {
+	store := user.NewMemoryStore()
+	alice := &user.User{Username: "alice", Password: "old"}
+	if err := user.RegisterUser(store, alice); err != nil {
+		t.Fatalf("Failed to setup existing user: %v", err)
+	}
+	secret, _ := totp.GenerateSecret()
+	code, _ := totp.Code(secret, time.Now())
+	if err := user.EnableMFA(store, alice, secret, code); err != nil {
+		t.Fatalf("Failed to enable two-factor authentication: %v", err)
+	}
+
+	cmd := NewPasswdCommand(store, &user.User{})
+	if err := cmd.Execute([]string{"alice", "old", "new"}, &bytes.Buffer{}); !errors.Is(err, user.ErrCodeRequired) {
+		t.Fatalf("Execute() without a reader error = %v, wantErr %v", err, user.ErrCodeRequired)
+	}
+	cmd.SetPasswordReader(func(prompt string) (string, error) { return "000000", nil })
+	if err := cmd.Execute([]string{"alice", "old", "new"}, &bytes.Buffer{}); !errors.Is(err, user.ErrWrongCode) {
+		t.Fatalf("Execute() with a wrong code error = %v, wantErr %v", err, user.ErrWrongCode)
+	}
+	if audits, _ := user.LoginHistory(store, "alice", 1); len(audits) != 1 || audits[0].Success {
+		t.Errorf("Expected the failed attempt to be audited, got %+v", audits)
+	}
+
+	prompts := []string{}
+	cmd.SetPasswordReader(func(prompt string) (string, error) {
+		prompts = append(prompts, prompt)
+		switch prompt {
+		case "Current password: ":
+			return "old", nil
+		case "Verification code: ":
+			// A fresh step, since the enrollment code cannot be used again.
+			return totp.Code(secret, time.Now().Add(totp.Period))
+		}
+		return "new", nil
+	})
+	if err := cmd.Execute([]string{"alice"}, &bytes.Buffer{}); err != nil {
+		t.Fatalf("Execute() unexpected error: %v", err)
+	}
+	want := []string{"Current password: ", "Verification code: ", "New password: ", "Retype new password: "}
+	if strings.Join(prompts, "|") != strings.Join(want, "|") {
+		t.Errorf("Execute() prompted %q, want %q", prompts, want)
+	}
+	if _, err := user.Authenticate(store, "alice", "new", "", ""); !errors.Is(err, user.ErrCodeRequired) {
+		t.Errorf("Expected the new password to be stored, got: %v", err)
+	}
+}
@@ -0,0 +1,74 @@
+package perm
+
+import (
+	user "asa/shell/internal/service"
+	"asa/shell/utils"
+	"fmt"
+	"io"
+)
+
+type PermCommand struct {
+	store user.UserStore
+	user  *user.User
+}
+
+func NewPermCommand(store user.UserStore, user *user.User) *PermCommand {
+	return &PermCommand{
+		store: store,
+		user:  user,
+	}
+}
+
+func (c *PermCommand) Name() string {
+	return "perm"
+}
+
+// Execute lists or edits the permission rules and assigns roles; only
+// admins may use it.
+func (c *PermCommand) Execute(args []string, stdout io.Writer) error {
+	if !user.IsAdmin(c.user) {
+		return user.ErrPermissionDenied
+	}
+	if len(args) == 0 {
+		return c.list(stdout)
+	}
+	if len(args) != 3 {
+		return utils.ErrInvalidArgs
+	}
+
+	switch args[0] {
+	case "allow":
+		return user.SetPermission(c.store, args[1], args[2], true)
+	case "deny":
+		return user.SetPermission(c.store, args[1], args[2], false)
+	case "reset":
+		return user.ResetPermission(c.store, args[1], args[2])
+	case "role":
+		if err := user.SetRole(c.store, args[1], args[2]); err != nil {
+			return err
+		}
+		if c.user.Username == args[1] {
+			c.user.Role = args[2]
+		}
+		return nil
+	default:
+		return utils.ErrUnvalidArg
+	}
+}
+
+func (c *PermCommand) list(stdout io.Writer) error {
+	rules, err := user.ListPermissions(c.store)
+	if err != nil {
+		return err
+	}
+	for _, rule := range rules {
+		verdict := "deny"
+		if rule.Allowed {
+			verdict = "allow"
+		}
+		if _, err := fmt.Fprintf(stdout, "%-6s %-20s %s\n", rule.Role, rule.Command, verdict); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,58 @@
+package perm
+
+import (
+	user "asa/shell/internal/service"
+	"asa/shell/utils"
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func TestPermCommand_Execute(t *testing.T) {
+	store := user.NewMemoryStore()
+	admin := &user.User{Username: "root", Role: user.RoleAdmin}
+	if err := user.RegisterUser(store, admin); err != nil {
+		t.Fatalf("Failed to setup admin: %v", err)
+	}
+	if err := user.RegisterUser(store, &user.User{Username: "alice"}); err != nil {
+		t.Fatalf("Failed to setup user: %v", err)
+	}
+
+	tests := []struct {
+		name        string
+		user        *user.User
+		args        []string
+		want        string
+		expectedErr error
+	}{
+		{name: "Users may not edit permissions", user: &user.User{Username: "alice"}, args: []string{"allow", "guest", "adduser"}, expectedErr: user.ErrPermissionDenied},
+		{name: "Deny programs to users", user: admin, args: []string{"deny", "user", user.External}},
+		{name: "Allow one program", user: admin, args: []string{"allow", "user", "git"}},
+		{name: "Unknown role", user: admin, args: []string{"deny", "staff", "ls"}, expectedErr: user.ErrUnknownRole},
+		{name: "Unknown subcommand", user: admin, args: []string{"grant", "user", "ls"}, expectedErr: utils.ErrUnvalidArg},
+		{name: "Demote a user", user: admin, args: []string{"role", "alice", "guest"}},
+		{
+			name: "List rules",
+			user: admin,
+			want: "guest  adduser              deny\nuser   @external            deny\nuser   git                  allow\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			err := NewPermCommand(store, tt.user).Execute(tt.args, &buf)
+			if !errors.Is(err, tt.expectedErr) {
+				t.Fatalf("Execute() error = %v, wantErr %v", err, tt.expectedErr)
+			}
+			if buf.String() != tt.want {
+				t.Errorf("Execute() output = %q, want %q", buf.String(), tt.want)
+			}
+		})
+	}
+
+	alice, _ := user.FindUser(store, "alice")
+	if alice.Role != user.RoleGuest {
+		t.Errorf("Expected alice to be a guest, but got role '%s'", alice.Role)
+	}
+}
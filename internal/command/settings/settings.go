@@ -0,0 +1,68 @@
+package settings
+
+import (
+	user "asa/shell/internal/service"
+	"asa/shell/internal/settings"
+	"asa/shell/utils"
+	"fmt"
+	"io"
+)
+
+type SettingsCommand struct {
+	store   user.UserStore
+	user    *user.User
+	session *settings.Session
+}
+
+func NewSettingsCommand(store user.UserStore, user *user.User, session *settings.Session) *SettingsCommand {
+	return &SettingsCommand{
+		store:   store,
+		user:    user,
+		session: session,
+	}
+}
+
+func (c *SettingsCommand) Name() string {
+	return "settings"
+}
+
+// Execute shows the settings of the session, sets an option, or saves the
+// settings so they are applied on the user's next login.
+func (c *SettingsCommand) Execute(args []string, stdout io.Writer) error {
+	if len(args) == 0 {
+		for _, setting := range c.session.Settings() {
+			fmt.Fprintf(stdout, "%-6s %-16s %s\n", setting.Kind, setting.Name, setting.Value)
+		}
+		return nil
+	}
+
+	switch args[0] {
+	case "set":
+		if len(args) != 3 {
+			return utils.ErrInvalidArgs
+		}
+		return c.session.SetOption(args[1], args[2])
+	case "save":
+		if len(args) != 1 {
+			return utils.ErrInvalidArgs
+		}
+		if c.user.Username == "" {
+			return utils.ErrNotLoggedIn
+		}
+		if err := user.SaveSettings(c.store, c.user, c.session.Settings()); err != nil {
+			return err
+		}
+		_, err := fmt.Fprintln(stdout, "settings saved")
+		return err
+	case "clear":
+		if len(args) != 1 {
+			return utils.ErrInvalidArgs
+		}
+		if c.user.Username == "" {
+			return utils.ErrNotLoggedIn
+		}
+		return user.SaveSettings(c.store, c.user, nil)
+	default:
+		return utils.ErrUnvalidArg
+	}
+}
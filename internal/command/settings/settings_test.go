@@ -0,0 +1,61 @@
+package settings
+
+import (
+	user "asa/shell/internal/service"
+	"asa/shell/internal/settings"
+	"asa/shell/utils"
+	"bytes"
+	"errors"
+	"os"
+	"testing"
+)
+
+func TestSettingsCommand_Execute(t *testing.T) {
+	store := user.NewMemoryStore()
+	t.Setenv("SHELLCOLOR", "")
+	os.Unsetenv("SHELLCOLOR")
+
+	alice := user.User{Username: "alice"}
+	if err := user.RegisterUser(store, &alice); err != nil {
+		t.Fatalf("failed to register user: %v", err)
+	}
+
+	tests := []struct {
+		name        string
+		user        *user.User
+		args        []string
+		want        string
+		wantStored  int
+		expectedErr error
+	}{
+		{name: "Set option", user: &alice, args: []string{"set", "prompt", "> "}},
+		{name: "List", user: &alice, args: []string{}, want: "option color            off\noption prompt           > \n"},
+		{name: "Save", user: &alice, args: []string{"save"}, want: "settings saved\n", wantStored: 2},
+		{name: "Clear", user: &alice, args: []string{"clear"}},
+		{name: "Save anonymously", user: &user.User{}, args: []string{"save"}, expectedErr: utils.ErrNotLoggedIn},
+		{name: "Unknown option", user: &alice, args: []string{"set", "bell", "on"}, expectedErr: settings.ErrUnknownOption},
+		{name: "Unknown subcommand", user: &alice, args: []string{"load"}, expectedErr: utils.ErrUnvalidArg},
+	}
+
+	session := settings.New()
+	defer session.Reset()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			err := NewSettingsCommand(store, tt.user, session).Execute(tt.args, &buf)
+			if !errors.Is(err, tt.expectedErr) {
+				t.Fatalf("Execute() error = %v, wantErr %v", err, tt.expectedErr)
+			}
+			if buf.String() != tt.want {
+				t.Errorf("Execute() output = %q, want %q", buf.String(), tt.want)
+			}
+			stored, err := user.LoadSettings(store, &alice)
+			if err != nil {
+				t.Fatalf("LoadSettings() unexpected error: %v", err)
+			}
+			if len(stored) != tt.wantStored {
+				t.Errorf("LoadSettings() = %+v, want %d settings", stored, tt.wantStored)
+			}
+		})
+	}
+}
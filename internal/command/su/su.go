@@ -0,0 +1,37 @@
+package su
+
+import (
+	"asa/shell/internal/command/login"
+	"asa/shell/internal/session"
+	"io"
+)
+
+type SuCommand struct {
+	login    *login.LoginCommand
+	sessions *session.Stack
+}
+
+func NewSuCommand(login *login.LoginCommand, sessions *session.Stack) *SuCommand {
+	return &SuCommand{
+		login:    login,
+		sessions: sessions,
+	}
+}
+
+func (c *SuCommand) Name() string {
+	return "su"
+}
+
+// Execute logs in like login does, but suspends the current session
+// instead of replacing it; exit and logout return to it.
+func (c *SuCommand) Execute(args []string, stdout io.Writer) error {
+	frame, err := c.sessions.Save()
+	if err != nil {
+		return err
+	}
+	if err := c.login.Execute(args, stdout); err != nil {
+		return err
+	}
+	c.sessions.Push(frame)
+	return nil
+}
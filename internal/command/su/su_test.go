@@ -0,0 +1,129 @@
+package su
+
+import (
+	"asa/shell/internal/command/login"
+	user "asa/shell/internal/service"
+	"asa/shell/internal/session"
+	"asa/shell/internal/settings"
+	"bytes"
+	"errors"
+	"os"
+	"testing"
+)
+
+func setupTestStore(t *testing.T) user.UserStore {
+	t.Helper()
+	t.Setenv("SHELL_HOME_BASE", t.TempDir())
+	wd, _ := os.Getwd()
+	t.Cleanup(func() { os.Chdir(wd) })
+	store := user.NewMemoryStore()
+	for _, name := range []string{"alice", "bob"} {
+		if err := user.RegisterUser(store, &user.User{Username: name, Password: name + "pw"}); err != nil {
+			t.Fatalf("failed to register %s: %v", name, err)
+		}
+	}
+	return store
+}
+
+func TestSuCommand_Execute(t *testing.T) {
+	store := setupTestStore(t)
+	t.Setenv("SU_TEST_VAR", "outer")
+
+	current := &user.User{}
+	shellSettings := settings.New()
+	sessions := session.NewStack(store, current, shellSettings)
+	cmd := NewSuCommand(login.NewLoginCommand(store, current), sessions)
+
+	start, _ := os.Getwd()
+	if err := cmd.Execute([]string{"alice", "alicepw"}, &bytes.Buffer{}); err != nil {
+		t.Fatalf("Execute() unexpected error: %v", err)
+	}
+	aliceDir, _ := os.Getwd()
+	shellSettings.Export("SU_TEST_VAR", "alice")
+	shellSettings.SetAlias("ll", "ls -l")
+	current.HistoryMap["pwd"]++
+
+	if err := cmd.Execute([]string{"bob", "wrong"}, &bytes.Buffer{}); !errors.Is(err, user.ErrWrongPassword) {
+		t.Fatalf("Execute() error = %v, want %v", err, user.ErrWrongPassword)
+	}
+	if sessions.Depth() != 1 || current.Username != "alice" {
+		t.Fatalf("failed su left depth %d and user %q, want 1 and alice", sessions.Depth(), current.Username)
+	}
+
+	if err := cmd.Execute([]string{"bob", "bobpw"}, &bytes.Buffer{}); err != nil {
+		t.Fatalf("Execute() unexpected error: %v", err)
+	}
+	shellSettings.Reset()
+	shellSettings.Export("SU_TEST_VAR", "bob")
+	if sessions.Depth() != 2 || current.Username != "bob" {
+		t.Fatalf("su left depth %d and user %q, want 2 and bob", sessions.Depth(), current.Username)
+	}
+
+	// Another session of alice records history and a failed login while
+	// hers is suspended.
+	other, _ := user.LoadUser(store, "alice")
+	other.HistoryMap["make"]++
+	if err := user.Update(store, &other); err != nil {
+		t.Fatalf("Update() unexpected error: %v", err)
+	}
+	if _, err := user.Authenticate(store, "alice", "wrong", "", "other"); !errors.Is(err, user.ErrWrongPassword) {
+		t.Fatalf("Authenticate() error = %v, want %v", err, user.ErrWrongPassword)
+	}
+
+	if err := sessions.Pop(); err != nil {
+		t.Fatalf("Pop() unexpected error: %v", err)
+	}
+	if current.HistoryMap["make"] != 1 || current.HistoryMap["pwd"] != 1 || current.FailedLogins != 1 {
+		t.Errorf("Pop() returned to %+v, want alice as stored now", current)
+	}
+	dir, _ := os.Getwd()
+	if current.Username != "alice" || dir != aliceDir || os.Getenv("SU_TEST_VAR") != "alice" {
+		t.Errorf("Pop() returned to %q in %q with SU_TEST_VAR=%q, want alice in %q with alice", current.Username, dir, os.Getenv("SU_TEST_VAR"), aliceDir)
+	}
+	if _, ok := shellSettings.Alias("ll"); !ok {
+		t.Error("Pop() should restore the aliases of the suspended session")
+	}
+
+	if err := sessions.Pop(); err != nil {
+		t.Fatalf("Pop() unexpected error: %v", err)
+	}
+	dir, _ = os.Getwd()
+	if current.Username != "" || dir != start || os.Getenv("SU_TEST_VAR") != "outer" {
+		t.Errorf("Pop() returned to %q in %q with SU_TEST_VAR=%q, want the anonymous session in %q with outer", current.Username, dir, os.Getenv("SU_TEST_VAR"), start)
+	}
+	if err := sessions.Pop(); !errors.Is(err, session.ErrNoSession) {
+		t.Errorf("Pop() error = %v, want %v", err, session.ErrNoSession)
+	}
+
+	stored, err := user.GetUser(store, "alice", "alicepw")
+	if err != nil {
+		t.Fatalf("GetUser() unexpected error: %v", err)
+	}
+	if stored.HistoryMap["pwd"] != 1 {
+		t.Errorf("alice's history = %v, want pwd counted once", stored.HistoryMap)
+	}
+}
+
+func TestSuCommand_DeletedUser(t *testing.T) {
+	store := setupTestStore(t)
+	current := &user.User{}
+	sessions := session.NewStack(store, current, settings.New())
+	cmd := NewSuCommand(login.NewLoginCommand(store, current), sessions)
+
+	if err := cmd.Execute([]string{"alice", "alicepw"}, &bytes.Buffer{}); err != nil {
+		t.Fatalf("Execute() unexpected error: %v", err)
+	}
+	if err := cmd.Execute([]string{"bob", "bobpw"}, &bytes.Buffer{}); err != nil {
+		t.Fatalf("Execute() unexpected error: %v", err)
+	}
+	if err := user.DeleteUser(store, "alice"); err != nil {
+		t.Fatalf("DeleteUser() unexpected error: %v", err)
+	}
+
+	if err := sessions.Pop(); err != nil {
+		t.Fatalf("Pop() unexpected error: %v", err)
+	}
+	if current.Username != "" || sessions.Depth() != 1 {
+		t.Errorf("Pop() returned to %q at depth %d, want an anonymous session at depth 1", current.Username, sessions.Depth())
+	}
+}
@@ -0,0 +1,34 @@
+package unalias
+
+import (
+	"asa/shell/internal/settings"
+	"asa/shell/utils"
+	"fmt"
+	"io"
+)
+
+type UnaliasCommand struct {
+	session *settings.Session
+}
+
+func NewUnaliasCommand(session *settings.Session) *UnaliasCommand {
+	return &UnaliasCommand{
+		session: session,
+	}
+}
+
+func (c *UnaliasCommand) Name() string {
+	return "unalias"
+}
+
+func (c *UnaliasCommand) Execute(args []string, stdout io.Writer) error {
+	if len(args) == 0 {
+		return utils.ErrNotEnoughArgs
+	}
+	for _, name := range args {
+		if err := c.session.RemoveAlias(name); err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,37 @@
+package unalias
+
+import (
+	"asa/shell/internal/settings"
+	"asa/shell/utils"
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func TestUnaliasCommand_Execute(t *testing.T) {
+	session := settings.New()
+	session.SetAlias("ll", "ls -l")
+	cmd := NewUnaliasCommand(session)
+
+	tests := []struct {
+		name        string
+		args        []string
+		expectedErr error
+	}{
+		{name: "Remove", args: []string{"ll"}},
+		{name: "Already removed", args: []string{"ll"}, expectedErr: settings.ErrNoAlias},
+		{name: "Missing name", args: []string{}, expectedErr: utils.ErrNotEnoughArgs},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := cmd.Execute(tt.args, &bytes.Buffer{})
+			if !errors.Is(err, tt.expectedErr) {
+				t.Fatalf("Execute() error = %v, wantErr %v", err, tt.expectedErr)
+			}
+		})
+	}
+	if _, ok := session.Alias("ll"); ok {
+		t.Error("alias ll should be removed")
+	}
+}
@@ -0,0 +1,38 @@
+package users
+
+import (
+	user "asa/shell/internal/service"
+	"asa/shell/utils"
+	"fmt"
+	"io"
+)
+
+type UsersCommand struct {
+	store user.UserStore
+}
+
+func NewUsersCommand(store user.UserStore) *UsersCommand {
+	return &UsersCommand{
+		store: store,
+	}
+}
+
+func (c *UsersCommand) Name() string {
+	return "users"
+}
+
+func (c *UsersCommand) Execute(args []string, stdout io.Writer) error {
+	if len(args) > 0 {
+		return utils.ErrInvalidArgs
+	}
+	accounts, err := user.ListUsers(c.store)
+	if err != nil {
+		return err
+	}
+	for _, account := range accounts {
+		if _, err := fmt.Fprintln(stdout, account.Username); err != nil {
+			return err
+		}
+	}
+	return nil
+}
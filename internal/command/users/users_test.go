@@ -0,0 +1,34 @@
+package users
+
+import (
+	user "asa/shell/internal/service"
+	"asa/shell/utils"
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func TestUsersCommand_Execute(t *testing.T) {
+	store := user.NewMemoryStore()
+	for _, name := range []string{"carol", "alice", "bob"} {
+		if err := user.RegisterUser(store, &user.User{Username: name}); err != nil {
+			t.Fatalf("Failed to setup user: %v", err)
+		}
+	}
+
+	cmd := NewUsersCommand(store)
+	if cmd.Name() != "users" {
+		t.Errorf("Name() should return 'users', but got '%s'", cmd.Name())
+	}
+
+	var buf bytes.Buffer
+	if err := cmd.Execute(nil, &buf); err != nil {
+		t.Fatalf("Execute() unexpected error: %v", err)
+	}
+	if buf.String() != "alice\nbob\ncarol\n" {
+		t.Errorf("Execute() output = %q, want the users in order", buf.String())
+	}
+	if err := cmd.Execute([]string{"extra"}, &buf); !errors.Is(err, utils.ErrInvalidArgs) {
+		t.Errorf("Execute() error = %v, wantErr %v", err, utils.ErrInvalidArgs)
+	}
+}
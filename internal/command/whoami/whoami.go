@@ -0,0 +1,33 @@
+package whoami
+
+import (
+	user "asa/shell/internal/service"
+	"asa/shell/utils"
+	"fmt"
+	"io"
+)
+
+type WhoamiCommand struct {
+	user *user.User
+}
+
+func NewWhoamiCommand(user *user.User) *WhoamiCommand {
+	return &WhoamiCommand{
+		user: user,
+	}
+}
+
+func (c *WhoamiCommand) Name() string {
+	return "whoami"
+}
+
+func (c *WhoamiCommand) Execute(args []string, stdout io.Writer) error {
+	if len(args) > 0 {
+		return utils.ErrInvalidArgs
+	}
+	if c.user.Username == "" {
+		return utils.ErrNotLoggedIn
+	}
+	_, err := fmt.Fprintln(stdout, c.user.Username)
+	return err
+}
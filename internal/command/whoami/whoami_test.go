@@ -0,0 +1,36 @@
+package whoami
+
+import (
+	user "asa/shell/internal/service"
+	"asa/shell/utils"
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func TestWhoamiCommand_Execute(t *testing.T) {
+	tests := []struct {
+		name        string
+		user        user.User
+		args        []string
+		want        string
+		expectedErr error
+	}{
+		{name: "Logged in", user: user.User{Username: "alice"}, want: "alice\n"},
+		{name: "Anonymous session", expectedErr: utils.ErrNotLoggedIn},
+		{name: "Too many arguments", user: user.User{Username: "alice"}, args: []string{"bob"}, expectedErr: utils.ErrInvalidArgs},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			err := NewWhoamiCommand(&tt.user).Execute(tt.args, &buf)
+			if !errors.Is(err, tt.expectedErr) {
+				t.Fatalf("Execute() error = %v, wantErr %v", err, tt.expectedErr)
+			}
+			if buf.String() != tt.want {
+				t.Errorf("Execute() output = %q, want %q", buf.String(), tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,223 @@
+package completion
+
+import (
+	"asa/shell/utils"
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Spec is a programmable completion registered with the complete builtin.
+// Words is a static word list (-W); Program is a command whose output lines
+// are the candidates (-C).
+type Spec struct {
+	Command string
+	Words   []string
+	Program string
+}
+
+// String renders the spec as the complete invocation that recreates it.
+func (s Spec) String() string {
+	if s.Program != "" {
+		return fmt.Sprintf("complete -C %s %s", Quote(s.Program), s.Command)
+	}
+	return fmt.Sprintf("complete -W %s %s", Quote(strings.Join(s.Words, " ")), s.Command)
+}
+
+type Registry struct {
+	specs map[string]Spec
+}
+
+func NewRegistry() *Registry {
+	return &Registry{specs: make(map[string]Spec)}
+}
+
+func (r *Registry) Set(spec Spec) {
+	r.specs[spec.Command] = spec
+}
+
+func (r *Registry) Get(command string) (Spec, bool) {
+	spec, ok := r.specs[command]
+	return spec, ok
+}
+
+func (r *Registry) Remove(command string) bool {
+	if _, ok := r.specs[command]; !ok {
+		return false
+	}
+	delete(r.specs, command)
+	return true
+}
+
+func (r *Registry) Specs() []Spec {
+	specs := make([]Spec, 0, len(r.specs))
+	for _, spec := range r.specs {
+		specs = append(specs, spec)
+	}
+	sort.Slice(specs, func(i, j int) bool {
+		return specs[i].Command < specs[j].Command
+	})
+	return specs
+}
+
+type Completer struct {
+	registry *Registry
+	builtins func() []string
+}
+
+func NewCompleter(registry *Registry, builtins func() []string) *Completer {
+	return &Completer{
+		registry: registry,
+		builtins: builtins,
+	}
+}
+
+// Complete completes the first word as a builtin or PATH executable, later
+// words from the command's registered spec, falling back to file names.
+func (c *Completer) Complete(line string, pos int) ([]string, int) {
+	head := line[:pos]
+	start := wordStart(head)
+	word := head[start:]
+	fields := strings.Fields(head[:start])
+
+	if len(fields) == 0 {
+		if strings.Contains(word, "/") {
+			return completeFiles(word), start
+		}
+		return c.completeCommands(word), start
+	}
+	if spec, ok := c.registry.Get(fields[0]); ok {
+		if spec.Program != "" {
+			return runProgram(spec.Program, fields, word, line, pos), start
+		}
+		return filterPrefix(spec.Words, word), start
+	}
+	return completeFiles(word), start
+}
+
+func (c *Completer) completeCommands(prefix string) []string {
+	names := []string{}
+	if c.builtins != nil {
+		names = append(names, c.builtins()...)
+	}
+	for _, dir := range strings.Split(os.Getenv("PATH"), ":") {
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			continue
+		}
+		for _, entry := range entries {
+			if !strings.HasPrefix(entry.Name(), prefix) || entry.IsDir() {
+				continue
+			}
+			info, err := entry.Info()
+			if err != nil || info.Mode()&0111 == 0 {
+				continue
+			}
+			names = append(names, entry.Name())
+		}
+	}
+	return filterPrefix(names, prefix)
+}
+
+func completeFiles(word string) []string {
+	dir, base := filepath.Split(unescape(word))
+	readDir := dir
+	if readDir == "" {
+		readDir = "."
+	}
+	entries, err := os.ReadDir(readDir)
+	if err != nil {
+		return nil
+	}
+	names := []string{}
+	for _, entry := range entries {
+		name := entry.Name()
+		if !strings.HasPrefix(name, base) {
+			continue
+		}
+		if strings.HasPrefix(name, ".") && !strings.HasPrefix(base, ".") {
+			continue
+		}
+		candidate := escape(dir + name)
+		if entry.IsDir() {
+			candidate += "/"
+		}
+		names = append(names, candidate)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// runProgram follows bash's complete -C protocol: the program gets the
+// command name, the word being completed and the previous word as
+// arguments, and COMP_LINE/COMP_POINT in its environment.
+func runProgram(program string, fields []string, word string, line string, pos int) []string {
+	argv, err := utils.ParseArgs(program)
+	if err != nil || len(argv) == 0 {
+		return nil
+	}
+	prev := fields[len(fields)-1]
+	argv = append(argv, fields[0], word, prev)
+
+	cmd := exec.Command(argv[0], argv[1:]...)
+	cmd.Env = append(os.Environ(),
+		"COMP_LINE="+line,
+		fmt.Sprintf("COMP_POINT=%d", pos),
+	)
+	var stdout bytes.Buffer
+	cmd.Stdout = &stdout
+	if err := cmd.Run(); err != nil {
+		return nil
+	}
+	candidates := []string{}
+	for _, candidate := range strings.Split(stdout.String(), "\n") {
+		if candidate = strings.TrimSpace(candidate); candidate != "" {
+			candidates = append(candidates, candidate)
+		}
+	}
+	return candidates
+}
+
+func filterPrefix(words []string, prefix string) []string {
+	seen := make(map[string]bool)
+	matches := []string{}
+	for _, w := range words {
+		if strings.HasPrefix(w, prefix) && !seen[w] {
+			seen[w] = true
+			matches = append(matches, w)
+		}
+	}
+	sort.Strings(matches)
+	return matches
+}
+
+// wordStart returns the offset of the last word in head, treating
+// backslash-escaped spaces as part of the word.
+func wordStart(head string) int {
+	for i := len(head) - 1; i >= 0; i-- {
+		if head[i] == ' ' && (i == 0 || head[i-1] != '\\') {
+			return i + 1
+		}
+	}
+	return 0
+}
+
+func escape(name string) string {
+	return strings.ReplaceAll(name, " ", "\\ ")
+}
+
+func unescape(word string) string {
+	return strings.ReplaceAll(word, "\\ ", " ")
+}
+
+// Quote single-quotes s so that utils.ParseArgs reads it back as one word.
+func Quote(s string) string {
+	if s != "" && !strings.ContainsAny(s, " \t'\"\\$`") {
+		return s
+	}
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
@@ -0,0 +1,167 @@
+package completion
+
+import (
+	"asa/shell/utils"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestCompleter_Complete(t *testing.T) {
+	tmpDir := t.TempDir()
+	for _, dir := range []string{"src", "my dir"} {
+		if err := os.Mkdir(filepath.Join(tmpDir, dir), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, file := range []string{"main.go", "makefile", ".hidden"} {
+		if err := os.WriteFile(filepath.Join(tmpDir, file), nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(tmpDir, "mytool"), []byte("#!/bin/sh\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	completer := filepath.Join(tmpDir, "completer.sh")
+	script := "#!/bin/sh\necho \"$1-$2-$3\"\necho \"$COMP_LINE\"\n"
+	if err := os.WriteFile(completer, []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	originalDir, _ := os.Getwd()
+	defer os.Chdir(originalDir)
+	os.Chdir(tmpDir)
+	originalPath := os.Getenv("PATH")
+	defer os.Setenv("PATH", originalPath)
+	os.Setenv("PATH", tmpDir)
+
+	registry := NewRegistry()
+	registry.Set(Spec{Command: "deploy", Words: []string{"staging", "production", "status"}})
+	registry.Set(Spec{Command: "tool", Program: completer})
+	c := NewCompleter(registry, func() []string { return []string{"cd", "cat", "complete"} })
+
+	tests := []struct {
+		name      string
+		line      string
+		want      []string
+		wantStart int
+	}{
+		{
+			name:      "builtins and PATH executables",
+			line:      "c",
+			want:      []string{"cat", "cd", "complete", "completer.sh"},
+			wantStart: 0,
+		},
+		{
+			name:      "PATH executable",
+			line:      "myt",
+			want:      []string{"mytool"},
+			wantStart: 0,
+		},
+		{
+			name:      "word list spec",
+			line:      "deploy st",
+			want:      []string{"staging", "status"},
+			wantStart: 7,
+		},
+		{
+			name:      "program spec",
+			line:      "tool build x",
+			want:      []string{"tool-x-build", "tool build x"},
+			wantStart: 11,
+		},
+		{
+			name:      "file names without spec",
+			line:      "cat ma",
+			want:      []string{"main.go", "makefile"},
+			wantStart: 4,
+		},
+		{
+			name:      "directories get a slash",
+			line:      "cd s",
+			want:      []string{"src/"},
+			wantStart: 3,
+		},
+		{
+			name:      "spaces are escaped",
+			line:      "cd my",
+			want:      []string{"my\\ dir/", "mytool"},
+			wantStart: 3,
+		},
+		{
+			name:      "escaped space stays in the word",
+			line:      "cd my\\ d",
+			want:      []string{"my\\ dir/"},
+			wantStart: 3,
+		},
+		{
+			name:      "hidden files need a dot",
+			line:      "cat .h",
+			want:      []string{".hidden"},
+			wantStart: 4,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, start := c.Complete(tt.line, len(tt.line))
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Complete(%q) = %q, want %q", tt.line, got, tt.want)
+			}
+			if start != tt.wantStart {
+				t.Errorf("Complete(%q) start = %d, want %d", tt.line, start, tt.wantStart)
+			}
+		})
+	}
+}
+
+func TestSpec_String(t *testing.T) {
+	tests := []struct {
+		name string
+		spec Spec
+		want []string
+	}{
+		{
+			name: "word list",
+			spec: Spec{Command: "deploy", Words: []string{"staging", "production"}},
+			want: []string{"complete", "-W", "staging production", "deploy"},
+		},
+		{
+			name: "program with quotes",
+			spec: Spec{Command: "tool", Program: "tool-complete --it's"},
+			want: []string{"complete", "-C", "tool-complete --it's", "tool"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := utils.ParseArgs(tt.spec.String())
+			if err != nil {
+				t.Fatalf("ParseArgs(%q) error = %v", tt.spec.String(), err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("String() parsed = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRegistry(t *testing.T) {
+	r := NewRegistry()
+	r.Set(Spec{Command: "b", Words: []string{"x"}})
+	r.Set(Spec{Command: "a", Words: []string{"y"}})
+
+	if specs := r.Specs(); len(specs) != 2 || specs[0].Command != "a" {
+		t.Errorf("Specs() = %v, want sorted by command", specs)
+	}
+	if !r.Remove("a") {
+		t.Errorf("Remove(a) = false, want true")
+	}
+	if r.Remove("a") {
+		t.Errorf("Remove(a) twice = true, want false")
+	}
+	if _, ok := r.Get("b"); !ok {
+		t.Errorf("Get(b) not found")
+	}
+}
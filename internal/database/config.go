@@ -0,0 +1,197 @@
+package database
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// configEnv names the database config file, ~/.shell/database.conf by
+// default.
+const configEnv = "SHELL_DB_CONFIG"
+
+// Drivers the shell can store its accounts with. File keeps them in a JSON
+// file instead of a database.
+const (
+	Postgres = "postgres"
+	SQLite   = "sqlite"
+	File     = "file"
+)
+
+// systemConfig is read before the config file of the user, so that an
+// administrator can choose the database for every user of the machine.
+var systemConfig = "/etc/shell/database.conf"
+
+var (
+	ErrInvalidConfig = errors.New("invalid database config")
+	ErrUnknownDriver = errors.New("unknown database driver")
+	ErrNoDatabase    = errors.New("driver uses no database")
+)
+
+// Config holds the connection settings. They come from the defaults, then
+// the system-wide and user config files, then the SHELL_DB_* environment
+// variables.
+type Config struct {
+	Driver string
+	// Path is the SQLite database file, ~/.shell/shell.db by default, or
+	// the accounts file, ~/.shell/accounts.json by default.
+	Path string
+
+	Host     string
+	Port     string
+	Name     string
+	User     string
+	Password string
+	SSLMode  string
+}
+
+// fields maps the keys of the config file to the environment variables
+// overriding them.
+var fields = []struct {
+	key string
+	env string
+	get func(*Config) *string
+}{
+	{"driver", "SHELL_DB_DRIVER", func(c *Config) *string { return &c.Driver }},
+	{"path", "SHELL_DB_PATH", func(c *Config) *string { return &c.Path }},
+	{"host", "SHELL_DB_HOST", func(c *Config) *string { return &c.Host }},
+	{"port", "SHELL_DB_PORT", func(c *Config) *string { return &c.Port }},
+	{"name", "SHELL_DB_NAME", func(c *Config) *string { return &c.Name }},
+	{"user", "SHELL_DB_USER", func(c *Config) *string { return &c.User }},
+	{"password", "SHELL_DB_PASSWORD", func(c *Config) *string { return &c.Password }},
+	{"sslmode", "SHELL_DB_SSLMODE", func(c *Config) *string { return &c.SSLMode }},
+}
+
+func defaultConfig() Config {
+	return Config{
+		Driver:   Postgres,
+		Host:     "localhost",
+		Port:     "5432",
+		Name:     "postgres",
+		User:     "postgres",
+		Password: "postgres",
+		SSLMode:  "disable",
+	}
+}
+
+// ConfigPath returns the config file location: $SHELL_DB_CONFIG when set,
+// ~/.shell/database.conf otherwise.
+func ConfigPath() (string, error) {
+	if path := os.Getenv(configEnv); path != "" {
+		return path, nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".shell", "database.conf"), nil
+}
+
+// LoadConfig reads the connection settings. Missing config files are not
+// an error.
+func LoadConfig() (Config, error) {
+	config := defaultConfig()
+	if err := config.readFile(systemConfig); err != nil {
+		return config, err
+	}
+	path, err := ConfigPath()
+	if err == nil {
+		err = config.readFile(path)
+	}
+	if err != nil {
+		return config, err
+	}
+	for _, field := range fields {
+		if value, ok := os.LookupEnv(field.env); ok {
+			*field.get(&config) = value
+		}
+	}
+	defaultPath := map[string]string{SQLite: "shell.db", File: "accounts.json"}
+	if config.Driver != Postgres && defaultPath[config.Driver] == "" {
+		return config, fmt.Errorf("%w %q", ErrUnknownDriver, config.Driver)
+	}
+	if name := defaultPath[config.Driver]; name != "" && config.Path == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return config, err
+		}
+		config.Path = filepath.Join(home, ".shell", name)
+	}
+	return config, nil
+}
+
+// readFile applies the "key = value" lines of the file at path. Blank
+// lines and lines starting with # are skipped.
+func (c *Config) readFile(path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		return err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		key, value, ok := strings.Cut(line, "=")
+		if !ok {
+			return fmt.Errorf("%s:%d: %w: expected key = value", path, n, ErrInvalidConfig)
+		}
+		if !c.set(strings.TrimSpace(key), strings.TrimSpace(value)) {
+			return fmt.Errorf("%s:%d: %w: unknown key %q", path, n, ErrInvalidConfig, strings.TrimSpace(key))
+		}
+	}
+	return scanner.Err()
+}
+
+func (c *Config) set(key, value string) bool {
+	for _, field := range fields {
+		if field.key == key {
+			*field.get(c) = value
+			return true
+		}
+	}
+	return false
+}
+
+// String describes the database the config points to.
+func (c Config) String() string {
+	switch c.Driver {
+	case SQLite:
+		return fmt.Sprintf("SQLite database %s", c.Path)
+	case File:
+		return fmt.Sprintf("accounts file %s", c.Path)
+	}
+	return fmt.Sprintf("database %q on %s:%s as %q", c.Name, c.Host, c.Port, c.User)
+}
+
+// dsnEscaper escapes the values of a connection string in libpq style.
+var dsnEscaper = strings.NewReplacer(`\`, `\\`, `'`, `\'`)
+
+// DSN returns the Postgres connection string of the config. Values are
+// quoted, so they may hold blanks, = and quotes.
+func (c Config) DSN() string {
+	var b strings.Builder
+	for _, pair := range [][2]string{
+		{"host", c.Host},
+		{"port", c.Port},
+		{"dbname", c.Name},
+		{"user", c.User},
+		{"password", c.Password},
+		{"sslmode", c.SSLMode},
+	} {
+		if b.Len() > 0 {
+			b.WriteByte(' ')
+		}
+		fmt.Fprintf(&b, "%s='%s'", pair[0], dsnEscaper.Replace(pair[1]))
+	}
+	return b.String()
+}
@@ -0,0 +1,124 @@
+package database
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+func TestLoadConfig(t *testing.T) {
+	tests := []struct {
+		name    string
+		system  string
+		file    string
+		env     map[string]string
+		want    Config
+		wantErr error
+	}{
+		{
+			name: "Defaults without a file",
+			want: defaultConfig(),
+		},
+		{
+			name: "File overrides defaults",
+			file: "# laptop\nhost = db.local\n\nport=6543\npassword = p=w\n",
+			want: Config{Driver: Postgres, Host: "db.local", Port: "6543", Name: "postgres", User: "postgres", Password: "p=w", SSLMode: "disable"},
+		},
+		{
+			name: "Environment overrides file",
+			file: "host = db.local\nuser = shell\n",
+			env:  map[string]string{"SHELL_DB_HOST": "10.0.0.1", "SHELL_DB_NAME": "shell", "SHELL_DB_SSLMODE": "require"},
+			want: Config{Driver: Postgres, Host: "10.0.0.1", Port: "5432", Name: "shell", User: "shell", Password: "postgres", SSLMode: "require"},
+		},
+		{
+			name:   "User file overrides system file",
+			system: "driver = sqlite\npath = /var/lib/shell/shell.db\nhost = db.system\n",
+			file:   "host = db.local\n",
+			want:   Config{Driver: SQLite, Path: "/var/lib/shell/shell.db", Host: "db.local", Port: "5432", Name: "postgres", User: "postgres", Password: "postgres", SSLMode: "disable"},
+		},
+		{
+			name: "SQLite in the home by default",
+			env:  map[string]string{"SHELL_DB_DRIVER": "sqlite", "HOME": "/home/alice"},
+			want: Config{Driver: SQLite, Path: "/home/alice/.shell/shell.db", Host: "localhost", Port: "5432", Name: "postgres", User: "postgres", Password: "postgres", SSLMode: "disable"},
+		},
+		{
+			name: "Accounts file in the home by default",
+			env:  map[string]string{"SHELL_DB_DRIVER": "file", "HOME": "/home/alice"},
+			want: Config{Driver: File, Path: "/home/alice/.shell/accounts.json", Host: "localhost", Port: "5432", Name: "postgres", User: "postgres", Password: "postgres", SSLMode: "disable"},
+		},
+		{
+			name:    "Unknown driver",
+			env:     map[string]string{"SHELL_DB_DRIVER": "mysql"},
+			wantErr: ErrUnknownDriver,
+		},
+		{
+			name:    "Unknown key",
+			file:    "hostname = db.local\n",
+			wantErr: ErrInvalidConfig,
+		},
+		{
+			name:    "Missing value separator",
+			file:    "host db.local\n",
+			wantErr: ErrInvalidConfig,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			path := filepath.Join(dir, "database.conf")
+			original := systemConfig
+			systemConfig = filepath.Join(dir, "system.conf")
+			t.Cleanup(func() { systemConfig = original })
+			for file, content := range map[string]string{systemConfig: tt.system, path: tt.file} {
+				if content == "" {
+					continue
+				}
+				if err := os.WriteFile(file, []byte(content), 0600); err != nil {
+					t.Fatalf("failed to write config: %v", err)
+				}
+			}
+			t.Setenv(configEnv, path)
+			for _, field := range fields {
+				t.Setenv(field.env, "")
+				os.Unsetenv(field.env)
+			}
+			for name, value := range tt.env {
+				t.Setenv(name, value)
+			}
+
+			got, err := LoadConfig()
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("LoadConfig() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr == nil && got != tt.want {
+				t.Errorf("LoadConfig() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestConfig_DSN(t *testing.T) {
+	want := "host='localhost' port='5432' dbname='postgres' user='postgres' password='postgres' sslmode='disable'"
+	if got := defaultConfig().DSN(); got != want {
+		t.Errorf("DSN() = %q, want %q", got, want)
+	}
+
+	config := defaultConfig()
+	config.Name = "my db"
+	config.Password = `p=a ss'w\rd`
+	want = `host='localhost' port='5432' dbname='my db' user='postgres' password='p=a ss\'w\\rd' sslmode='disable'`
+	if got := config.DSN(); got != want {
+		t.Errorf("DSN() = %q, want %q", got, want)
+	}
+	parsed, err := pgconn.ParseConfig(config.DSN())
+	if err != nil {
+		t.Fatalf("ParseConfig(DSN()) unexpected error: %v", err)
+	}
+	if parsed.Database != config.Name || parsed.Password != config.Password {
+		t.Errorf("ParseConfig(DSN()) = %q, %q, want %q, %q", parsed.Database, parsed.Password, config.Name, config.Password)
+	}
+}
@@ -2,36 +2,65 @@ package database
 
 import (
 	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
 
 	"gorm.io/driver/postgres"
+	"gorm.io/driver/sqlite"
 	"gorm.io/gorm"
 	"gorm.io/gorm/logger"
 )
 
-const (
-	dbHost = "localhost"
-	dbPort = "5432"
-	dbName = "postgres"
-	dbUser = "postgres"
-	dbPass = "postgres"
+var (
+	db      *gorm.DB
+	dbErr   error
+	connect sync.Once
 )
 
-var db *gorm.DB
-
-func init() {
-	dsn := fmt.Sprintf("host=%s port=%s dbname=%s user=%s password=%s sslmode=disable",
-		dbHost, dbPort, dbName, dbUser, dbPass)
+// Connect opens the database on first use and returns the same connection,
+// or the same error, afterwards.
+func Connect() (*gorm.DB, error) {
+	connect.Do(func() {
+		config, err := LoadConfig()
+		if err != nil {
+			dbErr = err
+			return
+		}
+		session, err := open(config)
+		if err != nil {
+			dbErr = fmt.Errorf("unable to connect to %s, check the SHELL_DB_* variables or %s: %w",
+				config, configPathOrEnv(), err)
+			return
+		}
+		db = session
+	})
+	return db, dbErr
+}
 
-	sesssion, err := gorm.Open(postgres.Open(dsn), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
-	if err != nil {
-		err = fmt.Errorf("unable to connect to database: %w", err)
-		panic(err)
+func open(config Config) (*gorm.DB, error) {
+	if config.Driver == File {
+		return nil, fmt.Errorf("%w: %s", ErrNoDatabase, config)
 	}
-
-	db = sesssion
-
+	dialector := postgres.Open(config.DSN())
+	if config.Driver == SQLite {
+		if err := os.MkdirAll(filepath.Dir(config.Path), 0700); err != nil {
+			return nil, err
+		}
+		dialector = sqlite.Open(config.Path)
+	}
+	return gorm.Open(dialector, &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
 }
 
+// GetDB returns the connection opened by Connect, nil if it failed.
 func GetDB() *gorm.DB {
+	db, _ := Connect()
 	return db
 }
+
+func configPathOrEnv() string {
+	if path, err := ConfigPath(); err == nil {
+		return path
+	}
+	return "$" + configEnv
+}
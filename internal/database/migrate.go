@@ -0,0 +1,261 @@
+package database
+
+import (
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// migrationFiles holds the schema of every driver as numbered steps,
+// migrations/<driver>/<version>_<name>.up.sql and its .down.sql undoing it.
+//
+//go:embed migrations
+var migrationFiles embed.FS
+
+var (
+	ErrInvalidMigration = errors.New("invalid migration")
+	ErrUnknownVersion   = errors.New("database schema is newer than this shell")
+)
+
+// Migration is one versioned step of the schema.
+type Migration struct {
+	Version int
+	Name    string
+	up      string
+	down    string
+}
+
+// MigrationStatus tells whether a migration was applied, and when.
+type MigrationStatus struct {
+	Migration
+	// AppliedAt is zero while the migration is pending.
+	AppliedAt time.Time
+}
+
+// schemaMigration is a row of the schema_migrations table, one per applied
+// migration.
+type schemaMigration struct {
+	Version   int `gorm:"primaryKey;autoIncrement:false"`
+	Name      string
+	AppliedAt time.Time
+}
+
+func (schemaMigration) TableName() string {
+	return "schema_migrations"
+}
+
+// Migrations returns the migrations of driver ordered by version.
+func Migrations(driver string) ([]Migration, error) {
+	dir := path.Join("migrations", driver)
+	files, err := fs.ReadDir(migrationFiles, dir)
+	if err != nil {
+		return nil, fmt.Errorf("%w %q", ErrUnknownDriver, driver)
+	}
+	byVersion := map[int]*Migration{}
+	for _, file := range files {
+		base, direction, ok := strings.Cut(strings.TrimSuffix(file.Name(), ".sql"), ".")
+		number, name, found := strings.Cut(base, "_")
+		version, err := strconv.Atoi(number)
+		if !ok || !found || err != nil || (direction != "up" && direction != "down") {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidMigration, file.Name())
+		}
+		content, err := migrationFiles.ReadFile(path.Join(dir, file.Name()))
+		if err != nil {
+			return nil, err
+		}
+		migration := byVersion[version]
+		if migration == nil {
+			migration = &Migration{Version: version, Name: name}
+			byVersion[version] = migration
+		}
+		if direction == "up" {
+			migration.up = string(content)
+		} else {
+			migration.down = string(content)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, migration := range byVersion {
+		if migration.up == "" || migration.down == "" {
+			return nil, fmt.Errorf("%w: %d_%s needs both an up and a down step", ErrInvalidMigration, migration.Version, migration.Name)
+		}
+		migrations = append(migrations, *migration)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	return migrations, nil
+}
+
+// Status returns every migration of the database with when it was applied.
+func Status(db *gorm.DB) ([]MigrationStatus, error) {
+	migrations, applied, err := load(db)
+	if err != nil {
+		return nil, err
+	}
+	statuses := make([]MigrationStatus, len(migrations))
+	for i, migration := range migrations {
+		statuses[i] = MigrationStatus{Migration: migration, AppliedAt: applied[migration.Version].AppliedAt}
+	}
+	return statuses, nil
+}
+
+// MigrateUp applies the next steps pending migrations, all of them when
+// steps is not positive, and returns those it applied.
+func MigrateUp(db *gorm.DB, steps int) ([]Migration, error) {
+	migrations, applied, err := load(db)
+	if err != nil {
+		return nil, err
+	}
+	var done []Migration
+	for _, migration := range migrations {
+		if _, ok := applied[migration.Version]; ok {
+			continue
+		}
+		if steps > 0 && len(done) == steps {
+			break
+		}
+		err := db.Transaction(func(tx *gorm.DB) error {
+			if err := adopt(tx, migration.up); err != nil {
+				return err
+			}
+			if err := exec(tx, migration.up); err != nil {
+				return err
+			}
+			return tx.Create(&schemaMigration{Version: migration.Version, Name: migration.Name, AppliedAt: time.Now()}).Error
+		})
+		if err != nil {
+			return done, fmt.Errorf("migration %d_%s failed: %w", migration.Version, migration.Name, err)
+		}
+		done = append(done, migration)
+	}
+	return done, nil
+}
+
+// MigrateDown reverts the last steps applied migrations and returns them,
+// latest first.
+func MigrateDown(db *gorm.DB, steps int) ([]Migration, error) {
+	migrations, applied, err := load(db)
+	if err != nil {
+		return nil, err
+	}
+	var done []Migration
+	for i := len(migrations) - 1; i >= 0 && len(done) < steps; i-- {
+		migration := migrations[i]
+		if _, ok := applied[migration.Version]; !ok {
+			continue
+		}
+		err := db.Transaction(func(tx *gorm.DB) error {
+			if err := exec(tx, migration.down); err != nil {
+				return err
+			}
+			return tx.Delete(&schemaMigration{Version: migration.Version}).Error
+		})
+		if err != nil {
+			return done, fmt.Errorf("reverting migration %d_%s failed: %w", migration.Version, migration.Name, err)
+		}
+		done = append(done, migration)
+	}
+	return done, nil
+}
+
+// load returns the migrations of the driver of db and those applied,
+// creating the schema_migrations table when needed. A database migrated by
+// a newer shell is refused rather than changed.
+func load(db *gorm.DB) ([]Migration, map[int]schemaMigration, error) {
+	migrations, err := Migrations(db.Dialector.Name())
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := db.AutoMigrate(&schemaMigration{}); err != nil {
+		return nil, nil, fmt.Errorf("failed to create schema_migrations: %w", err)
+	}
+	var rows []schemaMigration
+	if err := db.Find(&rows).Error; err != nil {
+		return nil, nil, fmt.Errorf("failed to read schema_migrations: %w", err)
+	}
+	known := map[int]bool{}
+	for _, migration := range migrations {
+		known[migration.Version] = true
+	}
+	applied := map[int]schemaMigration{}
+	for _, row := range rows {
+		if !known[row.Version] {
+			return nil, nil, fmt.Errorf("%w: unknown migration %d_%s", ErrUnknownVersion, row.Version, row.Name)
+		}
+		applied[row.Version] = row
+	}
+	return migrations, applied, nil
+}
+
+// exec runs the statements of script one at a time.
+func exec(tx *gorm.DB, script string) error {
+	for _, statement := range statements(script) {
+		if err := tx.Exec(statement).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// statements splits script into its statements. Statements end with a
+// semicolon at the end of a line; lines starting with -- are comments.
+func statements(script string) []string {
+	var all []string
+	var statement strings.Builder
+	for _, line := range strings.Split(script, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "--") {
+			continue
+		}
+		statement.WriteString(line)
+		statement.WriteString("\n")
+		if strings.HasSuffix(trimmed, ";") {
+			all = append(all, statement.String())
+			statement.Reset()
+		}
+	}
+	if strings.TrimSpace(statement.String()) != "" {
+		all = append(all, statement.String())
+	}
+	return all
+}
+
+var createTableIfNotExists = regexp.MustCompile(`(?is)^\s*CREATE TABLE IF NOT EXISTS (\w+) \((.*)\)\s*;?\s*$`)
+
+// adopt adds the columns they lack to the tables that script creates only
+// when missing, so that a table created by an older shell ends up as if the
+// script had created it. Each column is defined on a line of its own.
+func adopt(tx *gorm.DB, script string) error {
+	for _, statement := range statements(script) {
+		match := createTableIfNotExists.FindStringSubmatch(statement)
+		if match == nil || !tx.Migrator().HasTable(match[1]) {
+			continue
+		}
+		table := match[1]
+		for _, line := range strings.Split(match[2], "\n") {
+			column := strings.TrimSuffix(strings.TrimSpace(line), ",")
+			fields := strings.Fields(column)
+			// Keys cannot be added to a table, and a table has its
+			// primary key already.
+			if len(fields) < 2 || strings.EqualFold(fields[0], "CONSTRAINT") || strings.Contains(strings.ToUpper(column), "PRIMARY KEY") {
+				continue
+			}
+			if tx.Migrator().HasColumn(table, fields[0]) {
+				continue
+			}
+			if err := tx.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s", table, column)).Error; err != nil {
+				return fmt.Errorf("failed to add column %s.%s: %w", table, fields[0], err)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,213 @@
+package database
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+func openTestDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	db, err := open(Config{Driver: SQLite, Path: filepath.Join(t.TempDir(), "test.db")})
+	if err != nil {
+		t.Fatalf("failed to open test database: %v", err)
+	}
+	t.Cleanup(func() {
+		sqlDB, _ := db.DB()
+		sqlDB.Close()
+	})
+	return db
+}
+
+func TestMigrations(t *testing.T) {
+	postgres, err := Migrations(Postgres)
+	if err != nil {
+		t.Fatalf("Migrations(%q) unexpected error: %v", Postgres, err)
+	}
+	sqlite, err := Migrations(SQLite)
+	if err != nil {
+		t.Fatalf("Migrations(%q) unexpected error: %v", SQLite, err)
+	}
+	if len(postgres) == 0 || len(postgres) != len(sqlite) {
+		t.Fatalf("Migrations() returned %d for postgres and %d for sqlite, want the same steps", len(postgres), len(sqlite))
+	}
+	for i := range postgres {
+		if postgres[i].Version != sqlite[i].Version || postgres[i].Name != sqlite[i].Name {
+			t.Errorf("migration %d is %d_%s for postgres but %d_%s for sqlite",
+				i, postgres[i].Version, postgres[i].Name, sqlite[i].Version, sqlite[i].Name)
+		}
+		if i > 0 && postgres[i].Version <= postgres[i-1].Version {
+			t.Errorf("migration versions are not increasing: %d after %d", postgres[i].Version, postgres[i-1].Version)
+		}
+	}
+	if _, err := Migrations("mysql"); !errors.Is(err, ErrUnknownDriver) {
+		t.Errorf("Migrations(mysql) error = %v, want %v", err, ErrUnknownDriver)
+	}
+}
+
+func TestMigrateUpDown(t *testing.T) {
+	db := openTestDB(t)
+	migrations, _ := Migrations(SQLite)
+
+	statuses, err := Status(db)
+	if err != nil || len(statuses) != len(migrations) || !statuses[0].AppliedAt.IsZero() {
+		t.Fatalf("Status() of a new database = %+v, %v, want every migration pending", statuses, err)
+	}
+
+	done, err := MigrateUp(db, 1)
+	if err != nil || len(done) != 1 || done[0].Version != migrations[0].Version {
+		t.Fatalf("MigrateUp(1) = %+v, %v, want the first migration", done, err)
+	}
+	done, err = MigrateUp(db, 0)
+	if err != nil || len(done) != len(migrations)-1 {
+		t.Fatalf("MigrateUp(0) applied %d, %v, want the %d left", len(done), err, len(migrations)-1)
+	}
+	for _, table := range []string{"users", "history_entries", "permissions", "login_audit", "user_settings"} {
+		if !db.Migrator().HasTable(table) {
+			t.Errorf("table %s missing after MigrateUp()", table)
+		}
+	}
+	statuses, _ = Status(db)
+	for _, status := range statuses {
+		if status.AppliedAt.IsZero() {
+			t.Errorf("migration %d_%s still pending after MigrateUp()", status.Version, status.Name)
+		}
+	}
+	if done, err := MigrateUp(db, 0); err != nil || len(done) != 0 {
+		t.Errorf("MigrateUp() when up to date = %+v, %v, want nothing done", done, err)
+	}
+
+	done, err = MigrateDown(db, len(migrations))
+	if err != nil || len(done) != len(migrations) || done[0].Version != migrations[len(migrations)-1].Version {
+		t.Fatalf("MigrateDown() = %+v, %v, want every migration reverted, latest first", done, err)
+	}
+	if db.Migrator().HasTable("users") {
+		t.Errorf("table users left after reverting every migration")
+	}
+	if done, err := MigrateDown(db, 1); err != nil || len(done) != 0 {
+		t.Errorf("MigrateDown() of an empty database = %+v, %v, want nothing done", done, err)
+	}
+}
+
+func TestMigrateUp_AdoptsExistingSchema(t *testing.T) {
+	db := openTestDB(t)
+	// Databases from before migrations were created by the models.
+	err := db.Exec("CREATE TABLE users (id integer PRIMARY KEY AUTOINCREMENT, user_name text, password text, history text)").Error
+	if err != nil {
+		t.Fatalf("failed to create legacy table: %v", err)
+	}
+	if err := db.Exec("INSERT INTO users (user_name, password, history) VALUES ('alice', 'x', '{}')").Error; err != nil {
+		t.Fatalf("failed to insert legacy row: %v", err)
+	}
+
+	if _, err := MigrateUp(db, 0); err != nil {
+		t.Fatalf("MigrateUp() unexpected error: %v", err)
+	}
+	var count int64
+	db.Table("users").Count(&count)
+	if count != 1 {
+		t.Errorf("users after MigrateUp() = %d, want the legacy row kept", count)
+	}
+	for _, column := range []string{"role", "home_dir", "failed_logins", "locked_until", "totp_secret", "totp_step"} {
+		if !db.Migrator().HasColumn("users", column) {
+			t.Errorf("column users.%s missing after MigrateUp() adopted the table", column)
+		}
+	}
+	var role string
+	db.Table("users").Select("role").Scan(&role)
+	if role != "user" {
+		t.Errorf("role of the legacy row = %q, want the column default", role)
+	}
+}
+
+func TestAdopt(t *testing.T) {
+	db := openTestDB(t)
+	if err := db.Exec("CREATE TABLE items (id integer PRIMARY KEY, name text)").Error; err != nil {
+		t.Fatalf("failed to create table: %v", err)
+	}
+	script := "CREATE TABLE IF NOT EXISTS items (\n    id INTEGER PRIMARY KEY AUTOINCREMENT,\n    name TEXT,\n    kind TEXT DEFAULT 'plain',\n    CONSTRAINT uni_items_name UNIQUE (name)\n);\nCREATE TABLE IF NOT EXISTS other (\n    id INTEGER\n);\n"
+	if err := adopt(db, script); err != nil {
+		t.Fatalf("adopt() unexpected error: %v", err)
+	}
+	if !db.Migrator().HasColumn("items", "kind") {
+		t.Errorf("adopt() did not add the missing column")
+	}
+	if db.Migrator().HasTable("other") {
+		t.Errorf("adopt() created a missing table, want it left to the script")
+	}
+}
+
+func TestMigrateUp_MovesHistory(t *testing.T) {
+	db := openTestDB(t)
+	if _, err := MigrateUp(db, 1); err != nil {
+		t.Fatalf("MigrateUp(1) unexpected error: %v", err)
+	}
+	users := map[string]string{
+		"alice": `{"ls": 3, "git status": 1, "rm": 0}`,
+		"bob":   "",
+		"carol": "not json",
+	}
+	for name, history := range users {
+		if err := db.Exec("INSERT INTO users (user_name, history) VALUES (?, ?)", name, history).Error; err != nil {
+			t.Fatalf("failed to insert user: %v", err)
+		}
+	}
+
+	if _, err := MigrateUp(db, 0); err != nil {
+		t.Fatalf("MigrateUp() unexpected error: %v", err)
+	}
+	if db.Migrator().HasColumn("users", "history") {
+		t.Errorf("column users.history left after MigrateUp()")
+	}
+	var rows []struct {
+		Line  string
+		Count int
+	}
+	db.Table("history_counts").Order("line").Find(&rows)
+	if len(rows) != 2 || rows[0].Line != "git status" || rows[1].Line != "ls" || rows[1].Count != 3 {
+		t.Errorf("history_counts after MigrateUp() = %+v, want the positive counts of alice", rows)
+	}
+
+	if _, err := MigrateDown(db, 1); err != nil {
+		t.Fatalf("MigrateDown(1) unexpected error: %v", err)
+	}
+	var history string
+	db.Table("users").Where("user_name = ?", "alice").Select("history").Scan(&history)
+	if history != `{"git status":1,"ls":3}` {
+		t.Errorf("users.history after MigrateDown(1) = %q, want the counts back as JSON", history)
+	}
+	db.Table("users").Where("user_name = ?", "bob").Select("history").Scan(&history)
+	if history != "{}" {
+		t.Errorf("users.history without counts = %q, want {}", history)
+	}
+}
+
+func TestMigrateUp_NewerSchema(t *testing.T) {
+	db := openTestDB(t)
+	if _, err := MigrateUp(db, 0); err != nil {
+		t.Fatalf("MigrateUp() unexpected error: %v", err)
+	}
+	if err := db.Create(&schemaMigration{Version: 9999, Name: "future", AppliedAt: time.Now()}).Error; err != nil {
+		t.Fatalf("failed to record migration: %v", err)
+	}
+	if _, err := MigrateUp(db, 0); !errors.Is(err, ErrUnknownVersion) {
+		t.Errorf("MigrateUp() on a newer schema error = %v, want %v", err, ErrUnknownVersion)
+	}
+	if _, err := MigrateDown(db, 1); !errors.Is(err, ErrUnknownVersion) {
+		t.Errorf("MigrateDown() on a newer schema error = %v, want %v", err, ErrUnknownVersion)
+	}
+}
+
+func TestExec(t *testing.T) {
+	db := openTestDB(t)
+	script := "-- two statements\nCREATE TABLE a (id integer);\n\nCREATE TABLE b (\n    id integer\n);\n"
+	if err := exec(db, script); err != nil {
+		t.Fatalf("exec() unexpected error: %v", err)
+	}
+	if !db.Migrator().HasTable("a") || !db.Migrator().HasTable("b") {
+		t.Errorf("exec() did not run every statement")
+	}
+}
@@ -0,0 +1,5 @@
+DROP TABLE IF EXISTS user_settings;
+DROP TABLE IF EXISTS login_audit;
+DROP TABLE IF EXISTS permissions;
+DROP TABLE IF EXISTS history_entries;
+DROP TABLE IF EXISTS users;
@@ -0,0 +1,57 @@
+-- The tables are created only when missing, so that databases set up
+-- before migrations existed are adopted as they are.
+CREATE TABLE IF NOT EXISTS users (
+    id BIGSERIAL PRIMARY KEY,
+    user_name TEXT,
+    password TEXT,
+    role TEXT DEFAULT 'user',
+    home_dir TEXT,
+    history TEXT,
+    failed_logins BIGINT,
+    locked_until TIMESTAMPTZ,
+    totp_secret TEXT,
+    totp_step BIGINT,
+    CONSTRAINT uni_users_user_name UNIQUE (user_name)
+);
+
+CREATE TABLE IF NOT EXISTS history_entries (
+    id BIGSERIAL PRIMARY KEY,
+    user_id BIGINT,
+    command TEXT,
+    started_at TIMESTAMPTZ,
+    duration BIGINT,
+    cwd TEXT,
+    exit_status BIGINT,
+    session_id TEXT
+);
+CREATE INDEX IF NOT EXISTS idx_history_entries_user_id ON history_entries (user_id);
+CREATE INDEX IF NOT EXISTS idx_history_entries_session_id ON history_entries (session_id);
+
+CREATE TABLE IF NOT EXISTS permissions (
+    id BIGSERIAL PRIMARY KEY,
+    role TEXT,
+    command TEXT,
+    allowed BOOLEAN
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_permission_role_command ON permissions (role, command);
+
+CREATE TABLE IF NOT EXISTS login_audit (
+    id BIGSERIAL PRIMARY KEY,
+    username TEXT,
+    user_id BIGINT,
+    success BOOLEAN,
+    reason TEXT,
+    session_id TEXT,
+    at TIMESTAMPTZ
+);
+CREATE INDEX IF NOT EXISTS idx_login_audit_username ON login_audit (username);
+CREATE INDEX IF NOT EXISTS idx_login_audit_at ON login_audit (at);
+
+CREATE TABLE IF NOT EXISTS user_settings (
+    id BIGSERIAL PRIMARY KEY,
+    user_id BIGINT,
+    kind TEXT,
+    name TEXT,
+    value TEXT
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_setting_user_kind_name ON user_settings (user_id, kind, name);
@@ -0,0 +1,7 @@
+ALTER TABLE users ADD COLUMN history TEXT;
+
+UPDATE users SET history = COALESCE(
+    (SELECT json_object_agg(line, count)::TEXT FROM history_counts WHERE history_counts.user_id = users.id),
+    '{}');
+
+DROP TABLE history_counts;
@@ -0,0 +1,18 @@
+-- History counts move out of the JSON of users.history into a row per
+-- command line, so that a session only writes the lines it ran.
+CREATE TABLE history_counts (
+    user_id BIGINT NOT NULL,
+    line TEXT NOT NULL,
+    count BIGINT NOT NULL,
+    PRIMARY KEY (user_id, line)
+);
+CREATE INDEX idx_history_counts_user_count ON history_counts (user_id, count);
+-- Prefix lookups compare bytes whatever the collation of the database.
+CREATE INDEX idx_history_counts_user_line_prefix ON history_counts (user_id, line text_pattern_ops);
+
+INSERT INTO history_counts (user_id, line, count)
+SELECT users.id, counts.key, counts.value::BIGINT
+FROM users, jsonb_each_text(users.history::jsonb) AS counts
+WHERE users.history LIKE '{%' AND counts.value ~ '^[0-9]+$' AND counts.value::BIGINT > 0;
+
+ALTER TABLE users DROP COLUMN history;
@@ -0,0 +1,57 @@
+-- The tables are created only when missing, so that databases set up
+-- before migrations existed are adopted as they are.
+CREATE TABLE IF NOT EXISTS users (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_name TEXT,
+    password TEXT,
+    role TEXT DEFAULT 'user',
+    home_dir TEXT,
+    history TEXT,
+    failed_logins INTEGER,
+    locked_until DATETIME,
+    totp_secret TEXT,
+    totp_step INTEGER,
+    CONSTRAINT uni_users_user_name UNIQUE (user_name)
+);
+
+CREATE TABLE IF NOT EXISTS history_entries (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_id INTEGER,
+    command TEXT,
+    started_at DATETIME,
+    duration INTEGER,
+    cwd TEXT,
+    exit_status INTEGER,
+    session_id TEXT
+);
+CREATE INDEX IF NOT EXISTS idx_history_entries_user_id ON history_entries (user_id);
+CREATE INDEX IF NOT EXISTS idx_history_entries_session_id ON history_entries (session_id);
+
+CREATE TABLE IF NOT EXISTS permissions (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    role TEXT,
+    command TEXT,
+    allowed NUMERIC
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_permission_role_command ON permissions (role, command);
+
+CREATE TABLE IF NOT EXISTS login_audit (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    username TEXT,
+    user_id INTEGER,
+    success NUMERIC,
+    reason TEXT,
+    session_id TEXT,
+    at DATETIME
+);
+CREATE INDEX IF NOT EXISTS idx_login_audit_username ON login_audit (username);
+CREATE INDEX IF NOT EXISTS idx_login_audit_at ON login_audit (at);
+
+CREATE TABLE IF NOT EXISTS user_settings (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_id INTEGER,
+    kind TEXT,
+    name TEXT,
+    value TEXT
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_setting_user_kind_name ON user_settings (user_id, kind, name);
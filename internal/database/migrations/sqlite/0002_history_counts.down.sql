@@ -0,0 +1,7 @@
+ALTER TABLE users ADD COLUMN history TEXT;
+
+UPDATE users SET history = COALESCE(
+    (SELECT json_group_object(line, count) FROM history_counts WHERE user_id = users.id),
+    '{}');
+
+DROP TABLE history_counts;
@@ -0,0 +1,17 @@
+-- History counts move out of the JSON of users.history into a row per
+-- command line, so that a session only writes the lines it ran.
+CREATE TABLE history_counts (
+    user_id INTEGER NOT NULL,
+    line TEXT NOT NULL,
+    count INTEGER NOT NULL,
+    PRIMARY KEY (user_id, line)
+);
+CREATE INDEX idx_history_counts_user_count ON history_counts (user_id, count);
+
+INSERT INTO history_counts (user_id, line, count)
+SELECT users.id, counts.key, counts.value
+FROM users, json_each(users.history) AS counts
+WHERE json_valid(users.history) AND json_type(users.history) = 'object'
+    AND counts.type = 'integer' AND counts.value > 0;
+
+ALTER TABLE users DROP COLUMN history;
@@ -0,0 +1,199 @@
+package highlight
+
+import (
+	"asa/shell/utils"
+	"strings"
+)
+
+type Highlighter struct {
+	isCommand func(name string) bool
+}
+
+// NewHighlighter creates a highlighter that asks isCommand whether the
+// command word of a line resolves to something runnable.
+func NewHighlighter(isCommand func(name string) bool) *Highlighter {
+	return &Highlighter{isCommand: isCommand}
+}
+
+// Highlight colors line for display: the command word green when it
+// resolves and red when it does not, quoted strings yellow, redirection
+// operators cyan and variables magenta. The visible text is unchanged and
+// nothing is colored while color mode is off.
+func (h *Highlighter) Highlight(line string) string {
+	if !utils.IsColor() || line == "" {
+		return line
+	}
+	var b strings.Builder
+	commandSeen := false
+	expectTarget := false
+	for _, tok := range split(line) {
+		switch {
+		case tok.space:
+			b.WriteString(tok.text)
+		case isRedirection(tok.text):
+			b.WriteString(utils.ColorText(tok.text, utils.TextCyan))
+			expectTarget = true
+		case expectTarget:
+			b.WriteString(colorWord(tok.text))
+			expectTarget = false
+		case !commandSeen:
+			commandSeen = true
+			color := utils.TextRed
+			if name := unquote(tok.text); name != "" && h.isCommand(name) {
+				color = utils.TextGreen
+			}
+			b.WriteString(utils.ColorText(tok.text, color))
+		default:
+			b.WriteString(colorWord(tok.text))
+		}
+	}
+	return b.String()
+}
+
+type token struct {
+	text  string
+	space bool
+}
+
+// split cuts line into words and the runs of spaces between them, keeping
+// quoted spaces and escaped characters inside their word. Unterminated
+// quotes run to the end of the line, as the user is still typing them.
+func split(line string) []token {
+	tokens := []token{}
+	start := 0
+	var quote byte
+	for i := 0; i < len(line); i++ {
+		c := line[i]
+		switch {
+		case quote != 0:
+			if c == '\\' && quote == '"' && i+1 < len(line) {
+				i++
+			} else if c == quote {
+				quote = 0
+			}
+		case c == '\\':
+			i++
+		case c == '\'' || c == '"':
+			quote = c
+		case c == ' ':
+			if i > start {
+				tokens = append(tokens, token{text: line[start:i]})
+			}
+			end := i
+			for end < len(line) && line[end] == ' ' {
+				end++
+			}
+			tokens = append(tokens, token{text: line[i:end], space: true})
+			start = end
+			i = end - 1
+		}
+	}
+	if start < len(line) {
+		tokens = append(tokens, token{text: line[start:]})
+	}
+	return tokens
+}
+
+// colorWord colors the quoted parts and variables inside one word.
+func colorWord(word string) string {
+	var b strings.Builder
+	for i := 0; i < len(word); {
+		c := word[i]
+		switch {
+		case c == '\\':
+			end := min(i+2, len(word))
+			b.WriteString(word[i:end])
+			i = end
+		case c == '\'':
+			end := strings.IndexByte(word[i+1:], '\'')
+			if end < 0 {
+				end = len(word)
+			} else {
+				end += i + 2
+			}
+			b.WriteString(utils.ColorText(word[i:end], utils.TextYellow))
+			i = end
+		case c == '"':
+			end := closingDoubleQuote(word, i+1)
+			b.WriteString(colorDoubleQuoted(word[i:end]))
+			i = end
+		case c == '$':
+			end := variableEnd(word, i)
+			b.WriteString(utils.ColorText(word[i:end], utils.TextMagenta))
+			i = end
+		default:
+			end := i + 1
+			for end < len(word) && !strings.ContainsRune("\\'\"$", rune(word[end])) {
+				end++
+			}
+			b.WriteString(word[i:end])
+			i = end
+		}
+	}
+	return b.String()
+}
+
+// colorDoubleQuoted colors a double-quoted string yellow with the variables
+// it expands in magenta.
+func colorDoubleQuoted(s string) string {
+	var b strings.Builder
+	plain := 0
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' {
+			i++
+			continue
+		}
+		if s[i] != '$' {
+			continue
+		}
+		end := variableEnd(s, i)
+		if end == i+1 {
+			continue
+		}
+		b.WriteString(utils.ColorText(s[plain:i], utils.TextYellow))
+		b.WriteString(utils.ColorText(s[i:end], utils.TextMagenta))
+		plain = end
+		i = end - 1
+	}
+	if plain < len(s) {
+		b.WriteString(utils.ColorText(s[plain:], utils.TextYellow))
+	}
+	return b.String()
+}
+
+func closingDoubleQuote(word string, from int) int {
+	for i := from; i < len(word); i++ {
+		if word[i] == '\\' {
+			i++
+			continue
+		}
+		if word[i] == '"' {
+			return i + 1
+		}
+	}
+	return len(word)
+}
+
+func variableEnd(s string, dollar int) int {
+	end := dollar + 1
+	for end < len(s) && (utils.IsAlphaNumeric(s[end]) || s[end] == '_') {
+		end++
+	}
+	return end
+}
+
+func isRedirection(word string) bool {
+	switch word {
+	case ">", ">>", "2>", "2>>":
+		return true
+	}
+	return false
+}
+
+func unquote(word string) string {
+	args, err := utils.ParseArgs(word)
+	if err != nil || len(args) == 0 {
+		return word
+	}
+	return args[0]
+}
@@ -0,0 +1,124 @@
+package highlight
+
+import (
+	"asa/shell/utils"
+	"os"
+	"testing"
+)
+
+func TestHighlighter_Highlight(t *testing.T) {
+	originalColor, colorSet := os.LookupEnv("SHELLCOLOR")
+	defer func() {
+		if colorSet {
+			os.Setenv("SHELLCOLOR", originalColor)
+		} else {
+			os.Unsetenv("SHELLCOLOR")
+		}
+	}()
+	os.Setenv("SHELLCOLOR", "1")
+
+	known := map[string]bool{"echo": true, "cat": true, "my cmd": true}
+	h := NewHighlighter(func(name string) bool { return known[name] })
+
+	green := func(s string) string { return utils.ColorText(s, utils.TextGreen) }
+	red := func(s string) string { return utils.ColorText(s, utils.TextRed) }
+	yellow := func(s string) string { return utils.ColorText(s, utils.TextYellow) }
+	cyan := func(s string) string { return utils.ColorText(s, utils.TextCyan) }
+	magenta := func(s string) string { return utils.ColorText(s, utils.TextMagenta) }
+
+	tests := []struct {
+		name string
+		line string
+		want string
+	}{
+		{
+			name: "empty line",
+			line: "",
+			want: "",
+		},
+		{
+			name: "known command",
+			line: "echo hi",
+			want: green("echo") + " hi",
+		},
+		{
+			name: "unknown command",
+			line: "ehco hi",
+			want: red("ehco") + " hi",
+		},
+		{
+			name: "quoted command word",
+			line: "'my cmd' x",
+			want: green("'my cmd'") + " x",
+		},
+		{
+			name: "strings",
+			line: `echo 'a b' "c"`,
+			want: green("echo") + " " + yellow("'a b'") + " " + yellow(`"c"`),
+		},
+		{
+			name: "unterminated string",
+			line: `echo "abc`,
+			want: green("echo") + " " + yellow(`"abc`),
+		},
+		{
+			name: "variables",
+			line: "echo $HOME/x",
+			want: green("echo") + " " + magenta("$HOME") + "/x",
+		},
+		{
+			name: "variable inside double quotes",
+			line: `echo "hi $USER!"`,
+			want: green("echo") + " " + yellow(`"hi `) + magenta("$USER") + yellow(`!"`),
+		},
+		{
+			name: "no variable inside single quotes",
+			line: "echo '$HOME'",
+			want: green("echo") + " " + yellow("'$HOME'"),
+		},
+		{
+			name: "redirection",
+			line: "cat a 2>> err.log",
+			want: green("cat") + " a " + cyan("2>>") + " err.log",
+		},
+		{
+			name: "leading redirection",
+			line: "> out.txt cat a",
+			want: cyan(">") + " out.txt " + green("cat") + " a",
+		},
+		{
+			name: "escaped space",
+			line: `cat my\ file`,
+			want: green("cat") + ` my\ file`,
+		},
+		{
+			name: "leading spaces",
+			line: "  echo",
+			want: "  " + green("echo"),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := h.Highlight(tt.line); got != tt.want {
+				t.Errorf("Highlight(%q) = %q, want %q", tt.line, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHighlighter_ColorOff(t *testing.T) {
+	originalColor, colorSet := os.LookupEnv("SHELLCOLOR")
+	defer func() {
+		if colorSet {
+			os.Setenv("SHELLCOLOR", originalColor)
+		}
+	}()
+	os.Unsetenv("SHELLCOLOR")
+
+	h := NewHighlighter(func(string) bool { return true })
+	line := `echo "$HOME" > out`
+	if got := h.Highlight(line); got != line {
+		t.Errorf("Highlight(%q) with color off = %q, want it unchanged", line, got)
+	}
+}
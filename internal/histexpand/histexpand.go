@@ -0,0 +1,320 @@
+package histexpand
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+var (
+	ErrEventNotFound      = errors.New("event not found")
+	ErrBadWordSpecifier   = errors.New("bad word specifier")
+	ErrBadModifier        = errors.New("unrecognized history modifier")
+	ErrSubstitutionFailed = errors.New("substitution failed")
+)
+
+// Expand applies csh-style history expansion to line. history holds the
+// previous command lines oldest first, so that !n refers to the n-th line
+// of the history listing. It reports whether anything was expanded.
+func Expand(line string, history []string) (string, bool, error) {
+	if strings.HasPrefix(line, "^") {
+		// ^old^new^ is shorthand for !!:s^old^new^.
+		line = "!!:s" + line
+	}
+
+	var b strings.Builder
+	expanded := false
+	inSingle, inDouble := false, false
+	for i := 0; i < len(line); i++ {
+		c := line[i]
+		switch {
+		case c == '\\' && !inSingle && i+1 < len(line):
+			b.WriteString(line[i : i+2])
+			i++
+			continue
+		case c == '\'' && !inDouble:
+			inSingle = !inSingle
+		case c == '"' && !inSingle:
+			inDouble = !inDouble
+		case c == '!' && !inSingle:
+			text, n, err := expandEvent(line[i:], history, inDouble)
+			if err != nil {
+				return "", false, err
+			}
+			if n > 0 {
+				b.WriteString(text)
+				i += n - 1
+				expanded = true
+				continue
+			}
+		}
+		b.WriteByte(c)
+	}
+	return b.String(), expanded, nil
+}
+
+// expandEvent expands the history reference at the start of s and returns
+// its text and the number of bytes it spans, or 0 when the ! is literal.
+func expandEvent(s string, history []string, inDouble bool) (string, int, error) {
+	if len(s) == 1 || strings.IndexByte(" \t\n=(", s[1]) >= 0 || (inDouble && s[1] == '"') {
+		return "", 0, nil
+	}
+
+	event, pos, err := findEvent(s, history)
+	if err != nil {
+		return "", 0, err
+	}
+	words := splitWords(event)
+
+	text := event
+	if pos < len(s) && strings.IndexByte("^$*", s[pos]) >= 0 {
+		text, pos, err = selectWords(s, pos, words)
+	} else if pos+1 < len(s) && s[pos] == ':' && strings.IndexByte("0123456789^$*-", s[pos+1]) >= 0 {
+		text, pos, err = selectWords(s, pos+1, words)
+	}
+	if err != nil {
+		return "", 0, err
+	}
+
+	for pos < len(s) && s[pos] == ':' {
+		text, pos, err = modify(s, pos+1, text)
+		if err != nil {
+			return "", 0, err
+		}
+	}
+	return text, pos, nil
+}
+
+// findEvent resolves the event designator at the start of s and returns the
+// referenced line and where the designator ends.
+func findEvent(s string, history []string) (string, int, error) {
+	notFound := func(end int) error {
+		return fmt.Errorf("%s: %w", s[:end], ErrEventNotFound)
+	}
+	last := func(end int) (string, int, error) {
+		if len(history) == 0 {
+			return "", 0, notFound(end)
+		}
+		return history[len(history)-1], end, nil
+	}
+
+	switch c := s[1]; {
+	case c == '!':
+		return last(2)
+	case strings.IndexByte("^$*:", c) >= 0:
+		return last(1)
+	case c == '-' || (c >= '0' && c <= '9'):
+		end := 2
+		for end < len(s) && s[end] >= '0' && s[end] <= '9' {
+			end++
+		}
+		n, err := strconv.Atoi(s[1:end])
+		if err != nil {
+			return "", 0, notFound(end)
+		}
+		if n < 0 {
+			n += len(history) + 1
+		}
+		if n < 1 || n > len(history) {
+			return "", 0, notFound(end)
+		}
+		return history[n-1], end, nil
+	case c == '?':
+		end := strings.IndexByte(s[2:], '?')
+		query, next := s[2:], len(s)
+		if end >= 0 {
+			query, next = s[2:2+end], end+3
+		}
+		for i := len(history) - 1; i >= 0; i-- {
+			if query != "" && strings.Contains(history[i], query) {
+				return history[i], next, nil
+			}
+		}
+		return "", 0, notFound(next)
+	default:
+		end := 1
+		for end < len(s) && strings.IndexByte(" \t\n:;&|<>()'\"", s[end]) < 0 {
+			end++
+		}
+		for i := len(history) - 1; i >= 0; i-- {
+			if strings.HasPrefix(history[i], s[1:end]) {
+				return history[i], end, nil
+			}
+		}
+		return "", 0, notFound(end)
+	}
+}
+
+// selectWords applies the word designator starting at s[pos] to words.
+func selectWords(s string, pos int, words []string) (string, int, error) {
+	last := len(words) - 1
+	number := func() (int, bool) {
+		end := pos
+		for end < len(s) && s[end] >= '0' && s[end] <= '9' {
+			end++
+		}
+		if end == pos {
+			return 0, false
+		}
+		n, _ := strconv.Atoi(s[pos:end])
+		pos = end
+		return n, true
+	}
+
+	var from, to int
+	switch s[pos] {
+	case '^':
+		from, to = 1, 1
+		pos++
+	case '$':
+		from, to = last, last
+		pos++
+	case '*':
+		from, to = 1, last
+		pos++
+		if last < 1 {
+			return "", pos, nil
+		}
+	case '-':
+		from = 0
+		pos++
+		n, ok := number()
+		if !ok {
+			return "", 0, ErrBadWordSpecifier
+		}
+		to = n
+	default:
+		from, _ = number()
+		to = from
+		switch {
+		case pos < len(s) && s[pos] == '*':
+			to = last
+			pos++
+		case pos < len(s) && s[pos] == '-':
+			pos++
+			switch n, ok := number(); {
+			case ok:
+				to = n
+			case pos < len(s) && s[pos] == '$':
+				to = last
+				pos++
+			default:
+				// x- abbreviates x-$ without the last word.
+				to = last - 1
+			}
+		}
+	}
+
+	if from < 0 || to > last || from > to {
+		return "", 0, ErrBadWordSpecifier
+	}
+	return strings.Join(words[from:to+1], " "), pos, nil
+}
+
+// modify applies the modifier starting at s[pos] to text.
+func modify(s string, pos int, text string) (string, int, error) {
+	if pos >= len(s) {
+		return "", 0, ErrBadModifier
+	}
+	switch s[pos] {
+	case 'h':
+		if i := strings.LastIndexByte(text, '/'); i > 0 {
+			text = text[:i]
+		}
+		return text, pos + 1, nil
+	case 't':
+		return text[strings.LastIndexByte(text, '/')+1:], pos + 1, nil
+	case 'r':
+		if i := strings.LastIndexByte(text, '.'); i > strings.LastIndexByte(text, '/') {
+			text = text[:i]
+		}
+		return text, pos + 1, nil
+	case 'e':
+		if i := strings.LastIndexByte(text, '.'); i > strings.LastIndexByte(text, '/') {
+			return text[i:], pos + 1, nil
+		}
+		return "", pos + 1, nil
+	case 's':
+		return substitute(s, pos+1, text, false)
+	case 'g':
+		if pos+1 < len(s) && s[pos+1] == 's' {
+			return substitute(s, pos+2, text, true)
+		}
+	}
+	return "", 0, fmt.Errorf("%c: %w", s[pos], ErrBadModifier)
+}
+
+// substitute applies s/old/new/ starting at the delimiter s[pos]. The final
+// delimiter may be omitted at the end of the line, the delimiter can be
+// escaped with a backslash and & in new stands for old.
+func substitute(s string, pos int, text string, global bool) (string, int, error) {
+	if pos >= len(s) {
+		return "", 0, ErrSubstitutionFailed
+	}
+	delim := s[pos]
+	pos++
+
+	field := func() string {
+		var b strings.Builder
+		for pos < len(s) && s[pos] != delim {
+			if s[pos] == '\\' && pos+1 < len(s) && s[pos+1] == delim {
+				pos++
+			}
+			b.WriteByte(s[pos])
+			pos++
+		}
+		if pos < len(s) {
+			pos++
+		}
+		return b.String()
+	}
+	old := field()
+	replacement := strings.ReplaceAll(field(), "&", old)
+
+	if old == "" || !strings.Contains(text, old) {
+		return "", 0, ErrSubstitutionFailed
+	}
+	n := 1
+	if global {
+		n = -1
+	}
+	return strings.Replace(text, old, replacement, n), pos, nil
+}
+
+// splitWords splits line on unquoted blanks, keeping quotes and escapes in
+// the words as typed.
+func splitWords(line string) []string {
+	words := []string{}
+	var word strings.Builder
+	inWord := false
+	var quote byte
+	for i := 0; i < len(line); i++ {
+		c := line[i]
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '\\' && i+1 < len(line):
+			word.WriteByte(c)
+			i++
+			c = line[i]
+		case c == '\'' || c == '"':
+			quote = c
+		case c == ' ' || c == '\t':
+			if inWord {
+				words = append(words, word.String())
+				word.Reset()
+				inWord = false
+			}
+			continue
+		}
+		word.WriteByte(c)
+		inWord = true
+	}
+	if inWord {
+		words = append(words, word.String())
+	}
+	return words
+}
@@ -0,0 +1,91 @@
+package histexpand
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestExpand(t *testing.T) {
+	history := []string{
+		"cd /usr/local/src",
+		"tar xzf archive.tar.gz",
+		"echo 'hello world' one two",
+		"git commit -m fix",
+	}
+
+	tests := []struct {
+		name     string
+		line     string
+		want     string
+		expanded bool
+		wantErr  error
+	}{
+		{name: "no expansion", line: "ls -l", want: "ls -l"},
+		{name: "previous command", line: "!!", want: "git commit -m fix", expanded: true},
+		{name: "previous command with args", line: "sudo !!", want: "sudo git commit -m fix", expanded: true},
+		{name: "absolute event", line: "!1", want: "cd /usr/local/src", expanded: true},
+		{name: "relative event", line: "!-2", want: "echo 'hello world' one two", expanded: true},
+		{name: "prefix event", line: "!ta", want: "tar xzf archive.tar.gz", expanded: true},
+		{name: "substring event", line: "!?world?", want: "echo 'hello world' one two", expanded: true},
+		{name: "last argument", line: "ls !$", want: "ls fix", expanded: true},
+		{name: "first argument", line: "ls !^", want: "ls commit", expanded: true},
+		{name: "all arguments", line: "echo !*", want: "echo commit -m fix", expanded: true},
+		{name: "numbered word", line: "echo !!:2", want: "echo -m", expanded: true},
+		{name: "command word", line: "!!:0 status", want: "git status", expanded: true},
+		{name: "word range", line: "echo !-2:1-2", want: "echo 'hello world' one", expanded: true},
+		{name: "range to end", line: "echo !-2:2*", want: "echo one two", expanded: true},
+		{name: "range without last word", line: "echo !-2:1-", want: "echo 'hello world' one", expanded: true},
+		{name: "range from start", line: "!-2:-1", want: "echo 'hello world'", expanded: true},
+		{name: "word of prefix event", line: "cd !cd:$", want: "cd /usr/local/src", expanded: true},
+		{name: "substitute modifier", line: "!!:s/fix/feat/", want: "git commit -m feat", expanded: true},
+		{name: "substitute without final delimiter", line: "!!:s/fix/feat", want: "git commit -m feat", expanded: true},
+		{name: "global substitute", line: "!tar:gs/ar/AR/", want: "tAR xzf ARchive.tAR.gz", expanded: true},
+		{name: "substitute ampersand", line: "!!:s/fix/&es/", want: "git commit -m fixes", expanded: true},
+		{name: "head and tail", line: "echo !cd:1:h !cd:1:t", want: "echo /usr/local src", expanded: true},
+		{name: "root and extension", line: "echo !tar:$:r !tar:$:e", want: "echo archive.tar .gz", expanded: true},
+		{name: "quick substitution", line: "^fix^feat^", want: "git commit -m feat", expanded: true},
+		{name: "quick substitution with trailing text", line: "^fix^feat^ --amend", want: "git commit -m feat --amend", expanded: true},
+		{name: "inside double quotes", line: `echo "!!"`, want: `echo "git commit -m fix"`, expanded: true},
+		{name: "single quotes are literal", line: "echo '!!'", want: "echo '!!'"},
+		{name: "escaped bang", line: `echo \!!`, want: `echo \!!`},
+		{name: "bang before blank", line: "echo hi ! there", want: "echo hi ! there"},
+		{name: "bang at end", line: "echo hi!", want: "echo hi!"},
+		{name: "bang before equals", line: "test a != b", want: "test a != b"},
+		{name: "unknown prefix", line: "!nope", wantErr: ErrEventNotFound},
+		{name: "event out of range", line: "!9", wantErr: ErrEventNotFound},
+		{name: "word out of range", line: "!!:9", wantErr: ErrBadWordSpecifier},
+		{name: "failed substitution", line: "^nope^x^", wantErr: ErrSubstitutionFailed},
+		{name: "unknown modifier", line: "!!:z", wantErr: ErrBadModifier},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, expanded, err := Expand(tt.line, history)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Expand(%q) error = %v, wantErr %v", tt.line, err, tt.wantErr)
+			}
+			if got != tt.want || expanded != tt.expanded {
+				t.Errorf("Expand(%q) = %q, %v, want %q, %v", tt.line, got, expanded, tt.want, tt.expanded)
+			}
+		})
+	}
+}
+
+func TestExpand_EmptyHistory(t *testing.T) {
+	if _, _, err := Expand("!!", nil); !errors.Is(err, ErrEventNotFound) {
+		t.Errorf("Expand(!!) on empty history error = %v, want %v", err, ErrEventNotFound)
+	}
+}
+
+func TestSplitWords(t *testing.T) {
+	got := splitWords(`echo "a b" c\ d  'e'`)
+	want := []string{"echo", `"a b"`, `c\ d`, "'e'"}
+	if len(got) != len(want) {
+		t.Fatalf("splitWords() = %q, want %q", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("splitWords()[%d] = %q, want %q", i, got[i], want[i])
+		}
+	}
+}
@@ -0,0 +1,157 @@
+package histfile
+
+import (
+	"asa/shell/internal/histio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	// DefaultSize is the default of both HISTSIZE and HISTFILESIZE.
+	DefaultSize = 500
+	fileName    = ".shell_history"
+)
+
+// Path returns the history file of anonymous sessions: $HISTFILE when set,
+// ~/.shell_history otherwise. An empty HISTFILE disables the file and
+// yields an empty path.
+func Path() (string, error) {
+	if path, ok := os.LookupEnv("HISTFILE"); ok {
+		return path, nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, fileName), nil
+}
+
+// Limits returns how many entries to keep in memory (HISTSIZE) and in the
+// file (HISTFILESIZE). A negative value means no limit; unset or invalid
+// values fall back to DefaultSize.
+func Limits() (size, fileSize int) {
+	return limit("HISTSIZE"), limit("HISTFILESIZE")
+}
+
+func limit(name string) int {
+	n, err := strconv.Atoi(os.Getenv(name))
+	if err != nil {
+		return DefaultSize
+	}
+	return n
+}
+
+// Load reads the history file, entries oldest first. A missing file reads
+// as an empty history.
+func Load(path string) (histio.History, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return histio.History{Counts: map[string]int{}}, nil
+		}
+		return histio.History{}, err
+	}
+	defer file.Close()
+
+	if err := lock(file, false); err != nil {
+		return histio.History{}, fmt.Errorf("failed to lock history file: %w", err)
+	}
+	defer unlock(file)
+	h, err := histio.Decode(file, histio.ZshExtended)
+	if err != nil {
+		return histio.History{}, err
+	}
+	// Imports append older entries after newer ones.
+	sort.SliceStable(h.Entries, func(i, j int) bool {
+		return h.Entries[i].StartedAt.Before(h.Entries[j].StartedAt)
+	})
+	return h, nil
+}
+
+// Append adds h to the history file in zsh extended format, then drops the
+// oldest records beyond fileSize. The file is locked meanwhile so that
+// concurrent shells interleave whole records.
+func Append(path string, h histio.History, fileSize int) error {
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0600)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	if err := lock(file, true); err != nil {
+		return fmt.Errorf("failed to lock history file: %w", err)
+	}
+	defer unlock(file)
+
+	if _, err := file.Seek(0, io.SeekEnd); err != nil {
+		return err
+	}
+	if err := histio.Encode(file, histio.ZshExtended, h); err != nil {
+		return fmt.Errorf("failed to write history file: %w", err)
+	}
+	if fileSize < 0 {
+		return nil
+	}
+	return truncate(file, fileSize)
+}
+
+// Clear empties the history file.
+func Clear(path string) error {
+	err := os.Truncate(path, 0)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	return err
+}
+
+// truncate keeps the last size records of the locked file.
+func truncate(file *os.File, size int) error {
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	data, err := io.ReadAll(file)
+	if err != nil {
+		return err
+	}
+	records := splitRecords(data)
+	if len(records) <= size {
+		return nil
+	}
+
+	kept := bytes.Join(records[len(records)-size:], nil)
+	if err := file.Truncate(0); err != nil {
+		return err
+	}
+	_, err = file.WriteAt(kept, 0)
+	return err
+}
+
+// splitRecords splits zsh extended history into records, each with its
+// trailing newline. A line ending in a backslash continues the record.
+func splitRecords(data []byte) [][]byte {
+	records := [][]byte{}
+	start := 0
+	for i := 0; i < len(data); i++ {
+		if data[i] != '\n' {
+			continue
+		}
+		if i > 0 && data[i-1] == '\\' {
+			continue
+		}
+		if strings.TrimSpace(string(data[start:i])) != "" {
+			records = append(records, data[start:i+1])
+		}
+		start = i + 1
+	}
+	if start < len(data) && strings.TrimSpace(string(data[start:])) != "" {
+		records = append(records, append(data[start:len(data):len(data)], '\n'))
+	}
+	return records
+}
@@ -0,0 +1,141 @@
+package histfile
+
+import (
+	"asa/shell/internal/histio"
+	user "asa/shell/internal/service"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+func setEnv(t *testing.T, name, value string, set bool) {
+	t.Helper()
+	original, wasSet := os.LookupEnv(name)
+	t.Cleanup(func() {
+		if wasSet {
+			os.Setenv(name, original)
+		} else {
+			os.Unsetenv(name)
+		}
+	})
+	if set {
+		os.Setenv(name, value)
+	} else {
+		os.Unsetenv(name)
+	}
+}
+
+func entry(command string, sec int64) histio.History {
+	return histio.History{Entries: []user.HistoryEntry{{Command: command, StartedAt: time.Unix(sec, 0)}}}
+}
+
+func TestPath(t *testing.T) {
+	setEnv(t, "HISTFILE", "/tmp/hist", true)
+	if got, _ := Path(); got != "/tmp/hist" {
+		t.Errorf("Path() = %q, want %q", got, "/tmp/hist")
+	}
+
+	setEnv(t, "HISTFILE", "", true)
+	if got, _ := Path(); got != "" {
+		t.Errorf("Path() with empty HISTFILE = %q, want it disabled", got)
+	}
+
+	setEnv(t, "HISTFILE", "", false)
+	home, _ := os.UserHomeDir()
+	if got, _ := Path(); got != filepath.Join(home, ".shell_history") {
+		t.Errorf("Path() = %q, want the file in the home directory", got)
+	}
+}
+
+func TestLimits(t *testing.T) {
+	setEnv(t, "HISTSIZE", "100", true)
+	setEnv(t, "HISTFILESIZE", "bogus", true)
+	if size, fileSize := Limits(); size != 100 || fileSize != DefaultSize {
+		t.Errorf("Limits() = %d, %d, want 100, %d", size, fileSize, DefaultSize)
+	}
+
+	setEnv(t, "HISTSIZE", "", false)
+	setEnv(t, "HISTFILESIZE", "-1", true)
+	if size, fileSize := Limits(); size != DefaultSize || fileSize != -1 {
+		t.Errorf("Limits() = %d, %d, want %d, -1", size, fileSize, DefaultSize)
+	}
+}
+
+func TestAppendLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history")
+
+	h, err := Load(path)
+	if err != nil || len(h.Counts) != 0 {
+		t.Fatalf("Load() of a missing file = %v, %v, want an empty history", h, err)
+	}
+
+	for i, command := range []string{"ls", "pwd", "ls", "cd /tmp", "make"} {
+		if err := Append(path, entry(command, int64(1700000000+i)), 3); err != nil {
+			t.Fatalf("Append() unexpected error: %v", err)
+		}
+	}
+
+	h, err = Load(path)
+	if err != nil {
+		t.Fatalf("Load() unexpected error: %v", err)
+	}
+	if len(h.Entries) != 3 || h.Entries[0].Command != "ls" || h.Entries[2].Command != "make" {
+		t.Errorf("Load() entries = %+v, want the last three", h.Entries)
+	}
+	if h.Counts["ls"] != 1 || h.Counts["pwd"] != 0 {
+		t.Errorf("Load() counts = %v, want those of the kept entries", h.Counts)
+	}
+
+	if err := Clear(path); err != nil {
+		t.Fatalf("Clear() unexpected error: %v", err)
+	}
+	if h, _ := Load(path); len(h.Counts) != 0 {
+		t.Errorf("Load() after Clear() = %v, want an empty history", h)
+	}
+	if err := Clear(filepath.Join(t.TempDir(), "missing")); err != nil {
+		t.Errorf("Clear() of a missing file unexpected error: %v", err)
+	}
+}
+
+func TestAppend_Concurrent(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history")
+
+	var wg sync.WaitGroup
+	for shell := 0; shell < 4; shell++ {
+		wg.Add(1)
+		go func(shell int) {
+			defer wg.Done()
+			for i := 0; i < 25; i++ {
+				if err := Append(path, entry(fmt.Sprintf("echo %d %d", shell, i), 1700000000), -1); err != nil {
+					t.Errorf("Append() unexpected error: %v", err)
+				}
+			}
+		}(shell)
+	}
+	wg.Wait()
+
+	h, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load() unexpected error: %v", err)
+	}
+	if len(h.Entries) != 100 || len(h.Counts) != 100 {
+		t.Errorf("Load() read %d entries and %d lines, want 100 of each", len(h.Entries), len(h.Counts))
+	}
+}
+
+func TestSplitRecords(t *testing.T) {
+	data := []byte(": 1:0;ls\n: 2:0;for x\\\ndone\n\n: 3:0;pwd")
+	records := splitRecords(data)
+	want := []string{": 1:0;ls\n", ": 2:0;for x\\\ndone\n", ": 3:0;pwd\n"}
+	if len(records) != len(want) {
+		t.Fatalf("splitRecords() = %q, want %q", records, want)
+	}
+	for i := range want {
+		if string(records[i]) != want[i] {
+			t.Errorf("splitRecords()[%d] = %q, want %q", i, records[i], want[i])
+		}
+	}
+}
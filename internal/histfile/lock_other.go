@@ -0,0 +1,15 @@
+//go:build !unix
+
+package histfile
+
+import "os"
+
+// Other platforms go without locking; concurrent shells may then
+// interleave their writes.
+func lock(file *os.File, exclusive bool) error {
+	return nil
+}
+
+func unlock(file *os.File) error {
+	return nil
+}
@@ -0,0 +1,234 @@
+package histio
+
+import (
+	user "asa/shell/internal/service"
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	Bash        = "bash"
+	ZshExtended = "zsh-extended"
+	JSON        = "json"
+)
+
+var (
+	ErrUnknownFormat = errors.New("unknown history format")
+	ErrMalformed     = errors.New("malformed history file")
+)
+
+var (
+	bashTimestamp = regexp.MustCompile(`^#([0-9]+)$`)
+	zshHeader     = regexp.MustCompile(`^: *([0-9]+):([0-9]+);`)
+)
+
+// History is what gets exported and imported: the frequency table and the
+// timed executions. Lines counted more often than they have entries were
+// run before timestamps were recorded.
+type History struct {
+	Counts  map[string]int
+	Entries []user.HistoryEntry
+}
+
+type jsonEntry struct {
+	Command    string        `json:"command"`
+	StartedAt  time.Time     `json:"started_at"`
+	Duration   time.Duration `json:"duration"`
+	Cwd        string        `json:"cwd,omitempty"`
+	ExitStatus int           `json:"exit_status"`
+}
+
+type jsonHistory struct {
+	Counts  map[string]int `json:"counts"`
+	Entries []jsonEntry    `json:"entries"`
+}
+
+// Encode writes h to w in the given format. The bash and zsh formats have
+// no room for counts, so untimed lines are repeated as often as they ran.
+func Encode(w io.Writer, format string, h History) error {
+	switch format {
+	case Bash:
+		bw := bufio.NewWriter(w)
+		for _, line := range untimed(h) {
+			fmt.Fprintln(bw, line)
+		}
+		for _, entry := range h.Entries {
+			fmt.Fprintf(bw, "#%d\n%s\n", entry.StartedAt.Unix(), entry.Command)
+		}
+		return bw.Flush()
+	case ZshExtended:
+		bw := bufio.NewWriter(w)
+		escape := strings.NewReplacer("\n", "\\\n")
+		for _, line := range untimed(h) {
+			fmt.Fprintf(bw, ": 0:0;%s\n", escape.Replace(line))
+		}
+		for _, entry := range h.Entries {
+			fmt.Fprintf(bw, ": %d:%d;%s\n", entry.StartedAt.Unix(), int64(entry.Duration.Seconds()), escape.Replace(entry.Command))
+		}
+		return bw.Flush()
+	case JSON:
+		out := jsonHistory{Counts: h.Counts, Entries: []jsonEntry{}}
+		if out.Counts == nil {
+			out.Counts = map[string]int{}
+		}
+		for _, entry := range h.Entries {
+			out.Entries = append(out.Entries, jsonEntry{
+				Command:    entry.Command,
+				StartedAt:  entry.StartedAt,
+				Duration:   entry.Duration,
+				Cwd:        entry.Cwd,
+				ExitStatus: entry.ExitStatus,
+			})
+		}
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(out)
+	default:
+		return fmt.Errorf("%w: %s", ErrUnknownFormat, format)
+	}
+}
+
+// Decode reads a history in the given format, or guesses the format when
+// it is empty.
+func Decode(r io.Reader, format string) (History, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return History{}, err
+	}
+	if format == "" {
+		format = Detect(data)
+	}
+
+	switch format {
+	case Bash:
+		return decodeBash(data), nil
+	case ZshExtended:
+		return decodeZsh(data)
+	case JSON:
+		return decodeJSON(data)
+	default:
+		return History{}, fmt.Errorf("%w: %s", ErrUnknownFormat, format)
+	}
+}
+
+// Detect guesses the format of a history file from its first line.
+func Detect(data []byte) string {
+	trimmed := bytes.TrimSpace(data)
+	switch {
+	case bytes.HasPrefix(trimmed, []byte("{")):
+		return JSON
+	case zshHeader.Match(trimmed):
+		return ZshExtended
+	default:
+		return Bash
+	}
+}
+
+func decodeBash(data []byte) History {
+	h := History{Counts: map[string]int{}}
+	var at time.Time
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimRight(line, "\r")
+		if m := bashTimestamp.FindStringSubmatch(line); m != nil {
+			sec, _ := strconv.ParseInt(m[1], 10, 64)
+			at = time.Unix(sec, 0)
+			continue
+		}
+		h.add(line, at, 0)
+		at = time.Time{}
+	}
+	return h
+}
+
+func decodeZsh(data []byte) (History, error) {
+	h := History{Counts: map[string]int{}}
+	lines := strings.Split(string(data), "\n")
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+		for strings.HasSuffix(line, "\\") && i+1 < len(lines) {
+			i++
+			line = line[:len(line)-1] + "\n" + lines[i]
+		}
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		m := zshHeader.FindStringSubmatchIndex(line)
+		if m == nil {
+			return History{}, fmt.Errorf("%w: line %d", ErrMalformed, i+1)
+		}
+		sec, _ := strconv.ParseInt(line[m[2]:m[3]], 10, 64)
+		elapsed, _ := strconv.ParseInt(line[m[4]:m[5]], 10, 64)
+		var at time.Time
+		if sec > 0 {
+			at = time.Unix(sec, 0)
+		}
+		h.add(line[m[1]:], at, time.Duration(elapsed)*time.Second)
+	}
+	return h, nil
+}
+
+func decodeJSON(data []byte) (History, error) {
+	var in jsonHistory
+	if err := json.Unmarshal(data, &in); err != nil {
+		return History{}, fmt.Errorf("%w: %v", ErrMalformed, err)
+	}
+	h := History{Counts: map[string]int{}}
+	for _, entry := range in.Entries {
+		n := len(h.Entries)
+		h.add(entry.Command, entry.StartedAt, entry.Duration)
+		if len(h.Entries) > n {
+			h.Entries[n].Cwd = entry.Cwd
+			h.Entries[n].ExitStatus = entry.ExitStatus
+		}
+	}
+	// The counts of the file already include its entries.
+	for line, count := range in.Counts {
+		if count > h.Counts[line] {
+			h.Counts[line] = count
+		}
+	}
+	return h, nil
+}
+
+// add records one execution of line; a zero time makes it count-only.
+func (h *History) add(line string, at time.Time, elapsed time.Duration) {
+	line = strings.TrimSpace(line)
+	if line == "" {
+		return
+	}
+	h.Counts[line]++
+	if at.IsZero() {
+		return
+	}
+	h.Entries = append(h.Entries, user.HistoryEntry{Command: line, StartedAt: at, Duration: elapsed})
+}
+
+// untimed lists the executions of h known only from its counts, sorted.
+func untimed(h History) []string {
+	timed := map[string]int{}
+	for _, entry := range h.Entries {
+		timed[entry.Command]++
+	}
+	keys := make([]string, 0, len(h.Counts))
+	for line := range h.Counts {
+		keys = append(keys, line)
+	}
+	sort.Strings(keys)
+
+	lines := []string{}
+	for _, line := range keys {
+		for i := timed[line]; i < h.Counts[line]; i++ {
+			lines = append(lines, line)
+		}
+	}
+	return lines
+}
@@ -0,0 +1,164 @@
+package histio
+
+import (
+	user "asa/shell/internal/service"
+	"bytes"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func sampleHistory() History {
+	at := time.Unix(1700000000, 0)
+	return History{
+		Counts: map[string]int{"ls -l": 3, "cd /tmp": 1, "make": 1},
+		Entries: []user.HistoryEntry{
+			{Command: "ls -l", StartedAt: at, Duration: 2 * time.Second},
+			{Command: "cd /tmp", StartedAt: at.Add(time.Minute), Cwd: "/home/me", ExitStatus: 1},
+		},
+	}
+}
+
+func TestEncode(t *testing.T) {
+	tests := []struct {
+		format string
+		want   string
+	}{
+		{
+			format: Bash,
+			want:   "ls -l\nls -l\nmake\n#1700000000\nls -l\n#1700000060\ncd /tmp\n",
+		},
+		{
+			format: ZshExtended,
+			want:   ": 0:0;ls -l\n: 0:0;ls -l\n: 0:0;make\n: 1700000000:2;ls -l\n: 1700000060:0;cd /tmp\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			var out bytes.Buffer
+			if err := Encode(&out, tt.format, sampleHistory()); err != nil {
+				t.Fatalf("Encode() unexpected error: %v", err)
+			}
+			if out.String() != tt.want {
+				t.Errorf("Encode() = %q, want %q", out.String(), tt.want)
+			}
+		})
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	for _, format := range []string{Bash, ZshExtended, JSON} {
+		t.Run(format, func(t *testing.T) {
+			want := sampleHistory()
+			var out bytes.Buffer
+			if err := Encode(&out, format, want); err != nil {
+				t.Fatalf("Encode() unexpected error: %v", err)
+			}
+			got, err := Decode(&out, "")
+			if err != nil {
+				t.Fatalf("Decode() unexpected error: %v", err)
+			}
+
+			if !reflect.DeepEqual(got.Counts, want.Counts) {
+				t.Errorf("Decode() counts = %v, want %v", got.Counts, want.Counts)
+			}
+			if len(got.Entries) != len(want.Entries) {
+				t.Fatalf("Decode() entries = %+v, want %+v", got.Entries, want.Entries)
+			}
+			for i := range want.Entries {
+				if got.Entries[i].Command != want.Entries[i].Command || !got.Entries[i].StartedAt.Equal(want.Entries[i].StartedAt) {
+					t.Errorf("Decode() entry %d = %+v, want %+v", i, got.Entries[i], want.Entries[i])
+				}
+			}
+			if format == JSON && (got.Entries[1].Cwd != "/home/me" || got.Entries[1].ExitStatus != 1) {
+				t.Errorf("Decode() entry = %+v, want cwd and exit status kept", got.Entries[1])
+			}
+		})
+	}
+}
+
+func TestDecode(t *testing.T) {
+	tests := []struct {
+		name        string
+		input       string
+		format      string
+		wantCounts  map[string]int
+		wantEntries []string
+		wantErr     error
+	}{
+		{
+			name:       "plain bash history",
+			input:      "ls\ngit status\n\nls\n",
+			wantCounts: map[string]int{"ls": 2, "git status": 1},
+		},
+		{
+			name:        "bash history with timestamps",
+			input:       "#1700000000\nls\npwd\n#1700000100\nls\n",
+			wantCounts:  map[string]int{"ls": 2, "pwd": 1},
+			wantEntries: []string{"ls", "ls"},
+		},
+		{
+			name:        "zsh multiline command",
+			input:       ": 1700000000:0;for f in *; do\\\necho $f\\\ndone\n: 1700000005:1;pwd\n",
+			wantCounts:  map[string]int{"for f in *; do\necho $f\ndone": 1, "pwd": 1},
+			wantEntries: []string{"for f in *; do\necho $f\ndone", "pwd"},
+		},
+		{
+			name:    "malformed zsh history",
+			input:   ": 1700000000:0;ls\nnot a zsh line\n",
+			format:  ZshExtended,
+			wantErr: ErrMalformed,
+		},
+		{
+			name:    "malformed json",
+			input:   "{not json",
+			wantErr: ErrMalformed,
+		},
+		{
+			name:    "unknown format",
+			input:   "ls\n",
+			format:  "fish",
+			wantErr: ErrUnknownFormat,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Decode(strings.NewReader(tt.input), tt.format)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Decode() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr != nil {
+				return
+			}
+			if !reflect.DeepEqual(got.Counts, tt.wantCounts) {
+				t.Errorf("Decode() counts = %v, want %v", got.Counts, tt.wantCounts)
+			}
+			commands := []string{}
+			for _, entry := range got.Entries {
+				commands = append(commands, entry.Command)
+			}
+			if len(commands) != len(tt.wantEntries) || (len(commands) > 0 && !reflect.DeepEqual(commands, tt.wantEntries)) {
+				t.Errorf("Decode() entries = %q, want %q", commands, tt.wantEntries)
+			}
+		})
+	}
+}
+
+func TestDetect(t *testing.T) {
+	tests := map[string]string{
+		"ls\npwd\n":           Bash,
+		"#1700000000\nls\n":   Bash,
+		": 1700000000:0;ls\n": ZshExtended,
+		"  {\"counts\": {}}":  JSON,
+		"":                    Bash,
+	}
+	for input, want := range tests {
+		if got := Detect([]byte(input)); got != want {
+			t.Errorf("Detect(%q) = %q, want %q", input, got, want)
+		}
+	}
+}
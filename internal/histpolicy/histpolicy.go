@@ -0,0 +1,303 @@
+package histpolicy
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// Mask replaces every redacted secret in the recorded history.
+const Mask = "***"
+
+// secretArgs lists the builtins that take a password after their leading
+// arguments, with the number of arguments to keep in clear.
+var secretArgs = map[string]int{
+	"login":   1,
+	"adduser": 1,
+	"passwd":  1,
+	"deluser": 1,
+	"su":      1,
+}
+
+// Policy decides which command lines are recorded in the history and how.
+type Policy struct {
+	// IgnoreSpace skips lines that start with a blank.
+	IgnoreSpace bool
+	// IgnoreDups skips a line identical to the previous one.
+	IgnoreDups bool
+	// Ignore skips lines matching any of these patterns.
+	Ignore []*regexp.Regexp
+	// Redact masks the secrets matched by these rules.
+	Redact []*regexp.Regexp
+}
+
+// FromEnv builds the policy from the environment, in the spirit of bash:
+//
+//	HISTCONTROL  colon-separated options: ignorespace, ignoredups, ignoreboth
+//	HISTIGNORE   colon-separated glob patterns matched against the whole line
+//	HISTREDACT   colon-separated redaction rules
+//
+// A redaction rule ending in "=" is a glob on variable names whose assigned
+// values are masked, such as *_TOKEN=. Any other rule is a regular
+// expression masking its first capture group, or the whole match when it
+// has none. A literal colon is written \:. Invalid entries are skipped and
+// the first of them is reported along with the rest of the policy.
+func FromEnv() (*Policy, error) {
+	p := &Policy{}
+	var firstErr error
+	report := func(name, entry string, err error) {
+		if firstErr == nil {
+			firstErr = fmt.Errorf("%s: invalid pattern %q: %w", name, entry, err)
+		}
+	}
+
+	for _, option := range split(os.Getenv("HISTCONTROL")) {
+		switch option {
+		case "ignorespace":
+			p.IgnoreSpace = true
+		case "ignoredups":
+			p.IgnoreDups = true
+		case "ignoreboth":
+			p.IgnoreSpace, p.IgnoreDups = true, true
+		}
+	}
+
+	for _, pattern := range split(os.Getenv("HISTIGNORE")) {
+		re, err := regexp.Compile("^" + globToRegexp(pattern, ".") + "$")
+		if err != nil {
+			report("HISTIGNORE", pattern, err)
+			continue
+		}
+		p.Ignore = append(p.Ignore, re)
+	}
+
+	for _, rule := range split(os.Getenv("HISTREDACT")) {
+		re, err := compileRule(rule)
+		if err != nil {
+			report("HISTREDACT", rule, err)
+			continue
+		}
+		p.Redact = append(p.Redact, re)
+	}
+	return p, firstErr
+}
+
+// Filter returns the form of line to record in the history and whether to
+// record it at all; previous is the line recorded last.
+func (p *Policy) Filter(line, previous string) (string, bool) {
+	if p.IgnoreSpace && (strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t")) {
+		return "", false
+	}
+	line = strings.TrimSpace(line)
+	if line == "" {
+		return "", false
+	}
+	for _, re := range p.Ignore {
+		if re.MatchString(line) {
+			return "", false
+		}
+	}
+
+	line = p.redact(line)
+
+	if p.IgnoreDups && line == previous {
+		return "", false
+	}
+	return line, true
+}
+
+// FilterExpanded is Filter for a line whose aliases expand to expanded.
+// The line is skipped when either form is ignored, and when the expansion
+// holds secrets the alias hides, such as with alias l='login root', its
+// redacted form is recorded instead.
+func (p *Policy) FilterExpanded(line, expanded, previous string) (string, bool) {
+	recorded, keep := p.Filter(line, previous)
+	expanded = strings.TrimSpace(expanded)
+	if !keep || expanded == strings.TrimSpace(line) {
+		return recorded, keep
+	}
+	for _, re := range p.Ignore {
+		if re.MatchString(expanded) {
+			return "", false
+		}
+	}
+	if redacted := p.redact(expanded); redacted != expanded {
+		recorded = redacted
+	}
+	if p.IgnoreDups && recorded == previous {
+		return "", false
+	}
+	return recorded, true
+}
+
+// redact masks the secrets of line.
+func (p *Policy) redact(line string) string {
+	line = redactArgs(line)
+	for _, re := range p.Redact {
+		line = mask(re, line)
+	}
+	return line
+}
+
+func compileRule(rule string) (*regexp.Regexp, error) {
+	if name, ok := strings.CutSuffix(rule, "="); ok && !strings.ContainsAny(name, " \t") {
+		return regexp.Compile(`(?:^|[\s;])` + globToRegexp(name, `[A-Za-z0-9_]`) + `=((?:'[^']*'|"[^"]*"|\S)+)`)
+	}
+	return regexp.Compile(rule)
+}
+
+// mask replaces the first capture group of every match of re in line, or
+// the whole match when re has no groups.
+func mask(re *regexp.Regexp, line string) string {
+	group := 0
+	if re.NumSubexp() > 0 {
+		group = 1
+	}
+	var b strings.Builder
+	last := 0
+	for _, m := range re.FindAllStringSubmatchIndex(line, -1) {
+		start, end := m[2*group], m[2*group+1]
+		if start < 0 || start == end {
+			continue
+		}
+		b.WriteString(line[last:start])
+		b.WriteString(Mask)
+		last = end
+	}
+	b.WriteString(line[last:])
+	return b.String()
+}
+
+// redactArgs masks the password arguments of the builtins in secretArgs,
+// leaving flags and redirections alone.
+func redactArgs(line string) string {
+	spans := words(line)
+	if len(spans) == 0 {
+		return line
+	}
+	keep, ok := secretArgs[line[spans[0][0]:spans[0][1]]]
+	if !ok {
+		return line
+	}
+
+	var b strings.Builder
+	last := 0
+	for _, span := range spans[1:] {
+		word := line[span[0]:span[1]]
+		if isRedirection(word) {
+			break
+		}
+		if keep > 0 {
+			if !strings.HasPrefix(word, "-") {
+				keep--
+			}
+			continue
+		}
+		b.WriteString(line[last:span[0]])
+		b.WriteString(Mask)
+		last = span[1]
+	}
+	b.WriteString(line[last:])
+	return b.String()
+}
+
+func isRedirection(word string) bool {
+	return strings.HasPrefix(word, ">") || strings.HasPrefix(word, "2>")
+}
+
+// words returns the byte ranges of the blank-separated words of line,
+// honouring quotes and backslash escapes.
+func words(line string) [][2]int {
+	spans := [][2]int{}
+	start := -1
+	var quote byte
+	for i := 0; i < len(line); i++ {
+		c := line[i]
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+			continue
+		case c == ' ' || c == '\t':
+			if start >= 0 {
+				spans = append(spans, [2]int{start, i})
+				start = -1
+			}
+			continue
+		case c == '\\':
+			i++
+		case c == '\'' || c == '"':
+			quote = c
+		}
+		if start < 0 {
+			start = i
+			if c == '\\' {
+				start--
+			}
+		}
+	}
+	if start >= 0 {
+		spans = append(spans, [2]int{start, len(line)})
+	}
+	return spans
+}
+
+// split splits a colon-separated list, where \: stands for a literal colon.
+func split(list string) []string {
+	items := []string{}
+	var item strings.Builder
+	for i := 0; i < len(list); i++ {
+		switch {
+		case list[i] == '\\' && i+1 < len(list) && list[i+1] == ':':
+			item.WriteByte(':')
+			i++
+		case list[i] == ':':
+			if item.Len() > 0 {
+				items = append(items, item.String())
+			}
+			item.Reset()
+		default:
+			item.WriteByte(list[i])
+		}
+	}
+	if item.Len() > 0 {
+		items = append(items, item.String())
+	}
+	return items
+}
+
+// globToRegexp translates the glob wildcards *, ? and [...] into a regular
+// expression; a single wildcard character matches the wildcard expression.
+func globToRegexp(pattern, wildcard string) string {
+	var b strings.Builder
+	for i := 0; i < len(pattern); i++ {
+		switch c := pattern[i]; c {
+		case '*':
+			b.WriteString(wildcard + "*")
+		case '?':
+			b.WriteString(wildcard)
+		case '[':
+			end := strings.IndexByte(pattern[i+1:], ']')
+			if end < 0 {
+				b.WriteString(`\[`)
+				continue
+			}
+			class := pattern[i+1 : i+1+end]
+			if strings.HasPrefix(class, "!") {
+				class = "^" + class[1:]
+			}
+			b.WriteString("[" + strings.ReplaceAll(class, `\`, `\\`) + "]")
+			i += end + 1
+		case '\\':
+			if i+1 < len(pattern) {
+				i++
+				b.WriteString(regexp.QuoteMeta(pattern[i : i+1]))
+			}
+		default:
+			b.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	return b.String()
+}
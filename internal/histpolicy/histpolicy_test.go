@@ -0,0 +1,251 @@
+package histpolicy
+
+import (
+	"os"
+	"testing"
+)
+
+func setEnv(t *testing.T, env map[string]string) {
+	t.Helper()
+	for _, name := range []string{"HISTCONTROL", "HISTIGNORE", "HISTREDACT"} {
+		original, set := os.LookupEnv(name)
+		t.Cleanup(func() {
+			if set {
+				os.Setenv(name, original)
+			} else {
+				os.Unsetenv(name)
+			}
+		})
+		if value, ok := env[name]; ok {
+			os.Setenv(name, value)
+		} else {
+			os.Unsetenv(name)
+		}
+	}
+}
+
+func TestPolicy_Filter(t *testing.T) {
+	tests := []struct {
+		name     string
+		env      map[string]string
+		line     string
+		previous string
+		want     string
+		wantKeep bool
+	}{
+		{
+			name:     "plain line",
+			line:     "ls -l",
+			want:     "ls -l",
+			wantKeep: true,
+		},
+		{
+			name:     "login password",
+			line:     "login alice s3cret",
+			want:     "login alice ***",
+			wantKeep: true,
+		},
+		{
+			name:     "adduser password",
+			line:     `adduser bob "hunter 2"`,
+			want:     "adduser bob ***",
+			wantKeep: true,
+		},
+		{
+			name:     "passwd keeps redirection",
+			line:     "passwd bob n3w > out.txt",
+			want:     "passwd bob *** > out.txt",
+			wantKeep: true,
+		},
+		{
+			name:     "login without password",
+			line:     "login alice",
+			want:     "login alice",
+			wantKeep: true,
+		},
+		{
+			name:     "deluser password",
+			line:     "deluser alice s3cret",
+			want:     "deluser alice ***",
+			wantKeep: true,
+		},
+		{
+			name:     "su password",
+			line:     "su alice s3cret",
+			want:     "su alice ***",
+			wantKeep: true,
+		},
+		{
+			name:     "login prompt flag",
+			line:     "login -p alice",
+			want:     "login -p alice",
+			wantKeep: true,
+		},
+		{
+			name:     "leading space recorded by default",
+			line:     " ls",
+			want:     "ls",
+			wantKeep: true,
+		},
+		{
+			name: "ignorespace",
+			env:  map[string]string{"HISTCONTROL": "ignorespace"},
+			line: " ls",
+		},
+		{
+			name:     "ignoredups",
+			env:      map[string]string{"HISTCONTROL": "ignoredups"},
+			line:     "ls",
+			previous: "ls",
+		},
+		{
+			name:     "ignoredups compares redacted lines",
+			env:      map[string]string{"HISTCONTROL": "ignoreboth"},
+			line:     "login alice other",
+			previous: "login alice ***",
+		},
+		{
+			name:     "ignoredups keeps different lines",
+			env:      map[string]string{"HISTCONTROL": "ignoreboth"},
+			line:     "pwd",
+			previous: "ls",
+			want:     "pwd",
+			wantKeep: true,
+		},
+		{
+			name: "histignore",
+			env:  map[string]string{"HISTIGNORE": "ls:cd *:[bf]g"},
+			line: "cd /tmp",
+		},
+		{
+			name: "histignore class",
+			env:  map[string]string{"HISTIGNORE": "ls:cd *:[bf]g"},
+			line: "fg",
+		},
+		{
+			name:     "histignore matches whole lines",
+			env:      map[string]string{"HISTIGNORE": "ls:cd *:[bf]g"},
+			line:     "ls -l",
+			want:     "ls -l",
+			wantKeep: true,
+		},
+		{
+			name:     "variable rule",
+			env:      map[string]string{"HISTREDACT": "*_TOKEN="},
+			line:     "GITHUB_TOKEN=abc123 deploy --to 'prod' NPM_TOKEN='x y'",
+			want:     "GITHUB_TOKEN=*** deploy --to 'prod' NPM_TOKEN=***",
+			wantKeep: true,
+		},
+		{
+			name:     "variable rule ignores other names",
+			env:      map[string]string{"HISTREDACT": "*_TOKEN="},
+			line:     "echo TOKEN_FILE=x",
+			want:     "echo TOKEN_FILE=x",
+			wantKeep: true,
+		},
+		{
+			name:     "regexp rule with group",
+			env:      map[string]string{"HISTREDACT": `--password[= ](\S+)`},
+			line:     "mysql --password=pw -u root",
+			want:     "mysql --password=*** -u root",
+			wantKeep: true,
+		},
+		{
+			name:     "regexp rule without group",
+			env:      map[string]string{"HISTREDACT": `ghp_[A-Za-z0-9]+`},
+			line:     "curl -H ghp_abcDEF123 example.com",
+			want:     "curl -H *** example.com",
+			wantKeep: true,
+		},
+		{
+			name:     "escaped colon in rule",
+			env:      map[string]string{"HISTREDACT": `https\://[^@]+@`},
+			line:     "git clone https://me:pw@host/repo",
+			want:     "git clone ***host/repo",
+			wantKeep: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setEnv(t, tt.env)
+			p, err := FromEnv()
+			if err != nil {
+				t.Fatalf("FromEnv() unexpected error: %v", err)
+			}
+
+			got, keep := p.Filter(tt.line, tt.previous)
+			if got != tt.want || keep != tt.wantKeep {
+				t.Errorf("Filter(%q) = %q, %v, want %q, %v", tt.line, got, keep, tt.want, tt.wantKeep)
+			}
+		})
+	}
+}
+
+func TestPolicy_FilterExpanded(t *testing.T) {
+	tests := []struct {
+		name     string
+		env      map[string]string
+		line     string
+		expanded string
+		previous string
+		want     string
+		wantKeep bool
+	}{
+		{name: "no alias", line: "ls -l", expanded: "ls -l", want: "ls -l", wantKeep: true},
+		{name: "alias without secrets", line: "ll", expanded: "ls -l", want: "ll", wantKeep: true},
+		{name: "alias hiding the command", line: "l s3cret", expanded: "login root s3cret", want: "login root ***", wantKeep: true},
+		{name: "alias of a builtin", line: "p root old new", expanded: "passwd root old new", want: "passwd root *** ***", wantKeep: true},
+		{
+			name:     "redaction rule on the expansion",
+			env:      map[string]string{"HISTREDACT": "*_TOKEN="},
+			line:     "deploy",
+			expanded: "GH_TOKEN=abc make deploy",
+			want:     "GH_TOKEN=*** make deploy",
+			wantKeep: true,
+		},
+		{
+			name:     "ignored expansion",
+			env:      map[string]string{"HISTIGNORE": "login *"},
+			line:     "l s3cret",
+			expanded: "login root s3cret",
+		},
+		{
+			name:     "duplicate of the redacted expansion",
+			env:      map[string]string{"HISTCONTROL": "ignoredups"},
+			line:     "l s3cret",
+			expanded: "login root s3cret",
+			previous: "login root ***",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setEnv(t, tt.env)
+			p, err := FromEnv()
+			if err != nil {
+				t.Fatalf("FromEnv() unexpected error: %v", err)
+			}
+
+			got, keep := p.FilterExpanded(tt.line, tt.expanded, tt.previous)
+			if got != tt.want || keep != tt.wantKeep {
+				t.Errorf("FilterExpanded(%q, %q) = %q, %v, want %q, %v", tt.line, tt.expanded, got, keep, tt.want, tt.wantKeep)
+			}
+		})
+	}
+}
+
+func TestFromEnv_InvalidRule(t *testing.T) {
+	setEnv(t, map[string]string{"HISTREDACT": "(unclosed:secret"})
+
+	p, err := FromEnv()
+	if err == nil {
+		t.Fatal("FromEnv() expected an error for an invalid rule")
+	}
+	if len(p.Redact) != 1 {
+		t.Errorf("FromEnv() kept %d rules, want the valid one", len(p.Redact))
+	}
+	if got, _ := p.Filter("echo secret", ""); got != "echo ***" {
+		t.Errorf("Filter() = %q, want %q", got, "echo ***")
+	}
+}
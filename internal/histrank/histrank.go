@@ -0,0 +1,77 @@
+package histrank
+
+import (
+	"sort"
+	"time"
+)
+
+// Use is one execution of a command line at a known time.
+type Use struct {
+	Line string
+	At   time.Time
+}
+
+// untimedWeight scores executions known only from the frequency counts.
+const untimedWeight = 10
+
+// weight favours recent executions, in the spirit of browser "frecency".
+func weight(age time.Duration) int {
+	switch {
+	case age <= 4*time.Hour:
+		return 100
+	case age <= 24*time.Hour:
+		return 70
+	case age <= 7*24*time.Hour:
+		return 50
+	case age <= 30*24*time.Hour:
+		return 30
+	default:
+		return untimedWeight
+	}
+}
+
+// Rank orders the lines accepted by match by recency and frequency, best
+// first. counts holds how often each line ran overall and uses the timed
+// executions; uses also counted in counts are not scored twice. Ties go to
+// the most recently used line.
+func Rank(counts map[string]int, uses []Use, now time.Time, match func(string) bool) []string {
+	scores := make(map[string]int)
+	timed := make(map[string]int)
+	last := make(map[string]time.Time)
+	for _, use := range uses {
+		if !match(use.Line) {
+			continue
+		}
+		scores[use.Line] += weight(now.Sub(use.At))
+		timed[use.Line]++
+		if use.At.After(last[use.Line]) {
+			last[use.Line] = use.At
+		}
+	}
+	for line, count := range counts {
+		if !match(line) {
+			continue
+		}
+		if untimed := count - timed[line]; untimed > 0 {
+			scores[line] += untimed * untimedWeight
+		} else if _, ok := scores[line]; !ok {
+			scores[line] = 0
+		}
+	}
+
+	lines := make([]string, 0, len(scores))
+	for line := range scores {
+		lines = append(lines, line)
+	}
+	sort.Slice(lines, func(i, j int) bool {
+		a, b := lines[i], lines[j]
+		if scores[a] != scores[b] {
+			return scores[a] > scores[b]
+		}
+		if !last[a].Equal(last[b]) {
+			return last[a].After(last[b])
+		}
+		return a < b
+	})
+	return lines
+}
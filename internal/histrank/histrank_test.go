@@ -0,0 +1,82 @@
+package histrank
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRank(t *testing.T) {
+	now := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	all := func(string) bool { return true }
+
+	tests := []struct {
+		name   string
+		counts map[string]int
+		uses   []Use
+		match  func(string) bool
+		want   []string
+	}{
+		{
+			name:   "frequency only",
+			counts: map[string]int{"ls": 1, "git status": 5, "pwd": 3},
+			match:  all,
+			want:   []string{"git status", "pwd", "ls"},
+		},
+		{
+			name:   "recent use beats older frequent use",
+			counts: map[string]int{"make": 6, "go test ./...": 1},
+			uses:   []Use{{Line: "go test ./...", At: now.Add(-time.Minute)}},
+			match:  all,
+			want:   []string{"go test ./...", "make"},
+		},
+		{
+			name:   "ties go to the most recent",
+			counts: map[string]int{"a": 1, "b": 1},
+			uses: []Use{
+				{Line: "a", At: now.Add(-2 * time.Minute)},
+				{Line: "b", At: now.Add(-time.Minute)},
+			},
+			match: all,
+			want:  []string{"b", "a"},
+		},
+		{
+			name:   "timed uses are not counted twice",
+			counts: map[string]int{"a": 2, "b": 11},
+			uses: []Use{
+				{Line: "a", At: now},
+				{Line: "a", At: now},
+			},
+			match: all,
+			want:  []string{"a", "b"},
+		},
+		{
+			name:   "old uses weigh like untimed ones",
+			counts: map[string]int{"old": 1, "frequent": 2},
+			uses:   []Use{{Line: "old", At: now.Add(-365 * 24 * time.Hour)}},
+			match:  all,
+			want:   []string{"frequent", "old"},
+		},
+		{
+			name:   "match filters lines",
+			counts: map[string]int{"git push": 1, "git pull": 2, "ls": 9},
+			match:  func(line string) bool { return strings.Contains(line, "git") },
+			want:   []string{"git pull", "git push"},
+		},
+		{
+			name:  "nothing to rank",
+			match: all,
+			want:  []string{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Rank(tt.counts, tt.uses, now, tt.match)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Rank() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
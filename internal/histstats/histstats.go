@@ -0,0 +1,188 @@
+package histstats
+
+import (
+	user "asa/shell/internal/service"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Program aggregates the executions of one program.
+type Program struct {
+	Name        string        `json:"name"`
+	Count       int           `json:"count"`
+	Failures    int           `json:"failures"`
+	FailureRate float64       `json:"failure_rate"`
+	AvgDuration time.Duration `json:"avg_duration"`
+}
+
+// Hour counts the executions started during one hour of the day.
+type Hour struct {
+	Hour  int `json:"hour"`
+	Count int `json:"count"`
+}
+
+// Directory counts the executions run from one directory.
+type Directory struct {
+	Path  string `json:"path"`
+	Count int    `json:"count"`
+}
+
+// Stats summarizes a history.
+type Stats struct {
+	Total       int           `json:"total"`
+	Failures    int           `json:"failures"`
+	FailureRate float64       `json:"failure_rate"`
+	AvgDuration time.Duration `json:"avg_duration"`
+	Programs    []Program     `json:"programs"`
+	Hours       []Hour        `json:"busiest_hours"`
+	Directories []Directory   `json:"directories"`
+}
+
+// Compute summarizes entries, keeping the top entries of each ranking.
+func Compute(entries []user.HistoryEntry, top int) Stats {
+	stats := Stats{Programs: []Program{}, Hours: []Hour{}, Directories: []Directory{}}
+	programs := map[string]*Program{}
+	durations := map[string]time.Duration{}
+	hours := map[int]int{}
+	dirs := map[string]int{}
+	var total time.Duration
+
+	for _, entry := range entries {
+		name := programName(entry.Command)
+		if name == "" {
+			continue
+		}
+		p, ok := programs[name]
+		if !ok {
+			p = &Program{Name: name}
+			programs[name] = p
+		}
+		p.Count++
+		durations[name] += entry.Duration
+		total += entry.Duration
+		stats.Total++
+		if entry.ExitStatus != 0 {
+			p.Failures++
+			stats.Failures++
+		}
+		hours[entry.StartedAt.Local().Hour()]++
+		if entry.Cwd != "" {
+			dirs[entry.Cwd]++
+		}
+	}
+	if stats.Total == 0 {
+		return stats
+	}
+	stats.FailureRate = float64(stats.Failures) / float64(stats.Total)
+	stats.AvgDuration = total / time.Duration(stats.Total)
+
+	for name, p := range programs {
+		p.FailureRate = float64(p.Failures) / float64(p.Count)
+		p.AvgDuration = durations[name] / time.Duration(p.Count)
+		stats.Programs = append(stats.Programs, *p)
+	}
+	sort.Slice(stats.Programs, func(i, j int) bool {
+		a, b := stats.Programs[i], stats.Programs[j]
+		if a.Count != b.Count {
+			return a.Count > b.Count
+		}
+		return a.Name < b.Name
+	})
+
+	for hour, count := range hours {
+		stats.Hours = append(stats.Hours, Hour{Hour: hour, Count: count})
+	}
+	sort.Slice(stats.Hours, func(i, j int) bool {
+		a, b := stats.Hours[i], stats.Hours[j]
+		if a.Count != b.Count {
+			return a.Count > b.Count
+		}
+		return a.Hour < b.Hour
+	})
+
+	for path, count := range dirs {
+		stats.Directories = append(stats.Directories, Directory{Path: path, Count: count})
+	}
+	sort.Slice(stats.Directories, func(i, j int) bool {
+		a, b := stats.Directories[i], stats.Directories[j]
+		if a.Count != b.Count {
+			return a.Count > b.Count
+		}
+		return a.Path < b.Path
+	})
+
+	if top > 0 {
+		stats.Programs = stats.Programs[:min(top, len(stats.Programs))]
+		stats.Hours = stats.Hours[:min(top, len(stats.Hours))]
+		stats.Directories = stats.Directories[:min(top, len(stats.Directories))]
+	}
+	return stats
+}
+
+// programName is the program a command line runs, without its directory.
+func programName(command string) string {
+	fields := strings.Fields(command)
+	if len(fields) == 0 {
+		return ""
+	}
+	return filepath.Base(strings.Trim(fields[0], `'"`))
+}
+
+// WriteJSON writes the stats as indented JSON. Durations are in
+// nanoseconds and rates between 0 and 1.
+func (s Stats) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(s)
+}
+
+// WriteTable writes the stats as tables in the style of the history
+// frequency table.
+func (s Stats) WriteTable(w io.Writer) {
+	fmt.Fprintf(w, "Commands: %d, failed: %d (%.1f%%), average duration: %s\n",
+		s.Total, s.Failures, 100*s.FailureRate, round(s.AvgDuration))
+
+	line := strings.Repeat("-", 59)
+	fmt.Fprintln(w, line)
+	fmt.Fprintln(w, "|      Program       | Count | Failed |  Rate  | Avg time |")
+	fmt.Fprintln(w, line)
+	for _, p := range s.Programs {
+		fmt.Fprintf(w, "| %-18s | %-5d | %-6d | %5.1f%% | %-8s |\n",
+			p.Name, p.Count, p.Failures, 100*p.FailureRate, round(p.AvgDuration))
+	}
+	fmt.Fprintln(w, line)
+
+	line = strings.Repeat("-", 24)
+	fmt.Fprintln(w, line)
+	fmt.Fprintln(w, "|     Hour     | Count |")
+	fmt.Fprintln(w, line)
+	for _, h := range s.Hours {
+		fmt.Fprintf(w, "| %02d:00-%02d:59  | %-5d |\n", h.Hour, h.Hour, h.Count)
+	}
+	fmt.Fprintln(w, line)
+
+	line = strings.Repeat("-", 44)
+	fmt.Fprintln(w, line)
+	fmt.Fprintln(w, "|             Directory            | Count |")
+	fmt.Fprintln(w, line)
+	for _, d := range s.Directories {
+		fmt.Fprintf(w, "| %-32s | %-5d |\n", d.Path, d.Count)
+	}
+	fmt.Fprintln(w, line)
+}
+
+func round(d time.Duration) time.Duration {
+	switch {
+	case d >= time.Second:
+		return d.Round(10 * time.Millisecond)
+	case d >= time.Millisecond:
+		return d.Round(time.Millisecond)
+	default:
+		return d.Round(time.Microsecond)
+	}
+}
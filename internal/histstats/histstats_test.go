@@ -0,0 +1,100 @@
+package histstats
+
+import (
+	user "asa/shell/internal/service"
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+func sampleEntries() []user.HistoryEntry {
+	at := func(hour int) time.Time { return time.Date(2025, 3, 1, hour, 15, 0, 0, time.Local) }
+	return []user.HistoryEntry{
+		{Command: "make test", StartedAt: at(9), Duration: 3 * time.Second, Cwd: "/src", ExitStatus: 2},
+		{Command: "make", StartedAt: at(9), Duration: time.Second, Cwd: "/src"},
+		{Command: "/usr/bin/git status", StartedAt: at(14), Duration: 100 * time.Millisecond, Cwd: "/src"},
+		{Command: "git push", StartedAt: at(9), Duration: 300 * time.Millisecond, Cwd: "/docs", ExitStatus: 1},
+		{Command: "ls", StartedAt: at(14), Cwd: "/docs"},
+		{Command: "  ", StartedAt: at(3)},
+	}
+}
+
+func TestCompute(t *testing.T) {
+	stats := Compute(sampleEntries(), 0)
+
+	if stats.Total != 5 || stats.Failures != 2 || stats.FailureRate != 0.4 {
+		t.Errorf("Compute() totals = %d, %d, %v, want 5, 2, 0.4", stats.Total, stats.Failures, stats.FailureRate)
+	}
+	if stats.AvgDuration != 880*time.Millisecond {
+		t.Errorf("Compute() average duration = %v, want 880ms", stats.AvgDuration)
+	}
+
+	wantPrograms := []Program{
+		{Name: "git", Count: 2, Failures: 1, FailureRate: 0.5, AvgDuration: 200 * time.Millisecond},
+		{Name: "make", Count: 2, Failures: 1, FailureRate: 0.5, AvgDuration: 2 * time.Second},
+		{Name: "ls", Count: 1},
+	}
+	if len(stats.Programs) != len(wantPrograms) {
+		t.Fatalf("Compute() programs = %+v, want %+v", stats.Programs, wantPrograms)
+	}
+	for i, want := range wantPrograms {
+		if stats.Programs[i] != want {
+			t.Errorf("Compute() programs[%d] = %+v, want %+v", i, stats.Programs[i], want)
+		}
+	}
+
+	if len(stats.Hours) != 2 || stats.Hours[0] != (Hour{Hour: 9, Count: 3}) {
+		t.Errorf("Compute() hours = %+v, want 9h first with 3", stats.Hours)
+	}
+	if len(stats.Directories) != 2 || stats.Directories[0] != (Directory{Path: "/src", Count: 3}) {
+		t.Errorf("Compute() directories = %+v, want /src first with 3", stats.Directories)
+	}
+}
+
+func TestCompute_Top(t *testing.T) {
+	stats := Compute(sampleEntries(), 1)
+	if len(stats.Programs) != 1 || len(stats.Hours) != 1 || len(stats.Directories) != 1 {
+		t.Errorf("Compute() with top 1 = %+v", stats)
+	}
+}
+
+func TestCompute_Empty(t *testing.T) {
+	var out bytes.Buffer
+	if err := Compute(nil, 10).WriteJSON(&out); err != nil {
+		t.Fatalf("WriteJSON() unexpected error: %v", err)
+	}
+	if !strings.Contains(out.String(), `"programs": []`) {
+		t.Errorf("WriteJSON() = %s, want empty lists rather than null", out.String())
+	}
+}
+
+func TestStats_Write(t *testing.T) {
+	stats := Compute(sampleEntries(), 10)
+
+	var table bytes.Buffer
+	stats.WriteTable(&table)
+	for _, want := range []string{
+		"Commands: 5, failed: 2 (40.0%), average duration: 880ms",
+		"| make               | 2     | 1      |  50.0% | 2s       |",
+		"| 09:00-09:59  | 3     |",
+		"| /src                             | 3     |",
+	} {
+		if !strings.Contains(table.String(), want) {
+			t.Errorf("WriteTable() output = %s\nwant it to contain %q", table.String(), want)
+		}
+	}
+
+	var out bytes.Buffer
+	if err := stats.WriteJSON(&out); err != nil {
+		t.Fatalf("WriteJSON() unexpected error: %v", err)
+	}
+	var decoded Stats
+	if err := json.Unmarshal(out.Bytes(), &decoded); err != nil {
+		t.Fatalf("WriteJSON() wrote invalid JSON: %v", err)
+	}
+	if decoded.Total != 5 || len(decoded.Programs) != 3 || decoded.Programs[0].Name != "git" {
+		t.Errorf("WriteJSON() decoded = %+v", decoded)
+	}
+}
@@ -0,0 +1,79 @@
+package rcfile
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	envVar   = "SHELLRC"
+	fileName = ".shellrc"
+)
+
+// Path returns the rc file location: $SHELLRC when set, ~/.shellrc otherwise.
+func Path() (string, error) {
+	if path := os.Getenv(envVar); path != "" {
+		return path, nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, fileName), nil
+}
+
+// Read returns the lines of the rc file. A missing file reads as empty.
+func Read(path string) ([]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	defer file.Close()
+
+	lines := []string{}
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		lines = append(lines, scanner.Text())
+	}
+	return lines, scanner.Err()
+}
+
+// Write replaces the rc file with lines, going through a temporary file so
+// a failed write never leaves a truncated rc file behind.
+func Write(path string, lines []string) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), fileName+".tmp*")
+	if err != nil {
+		return fmt.Errorf("failed to write rc file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	content := strings.Join(lines, "\n")
+	if len(lines) > 0 {
+		content += "\n"
+	}
+	if _, err := tmp.WriteString(content); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write rc file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write rc file: %w", err)
+	}
+	if info, err := os.Stat(path); err == nil {
+		os.Chmod(tmp.Name(), info.Mode())
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// IsCommand reports whether line holds a command, as opposed to being blank
+// or a comment.
+func IsCommand(line string) bool {
+	line = strings.TrimSpace(line)
+	return line != "" && !strings.HasPrefix(line, "#")
+}
@@ -0,0 +1,70 @@
+package rcfile
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestPath(t *testing.T) {
+	original, wasSet := os.LookupEnv(envVar)
+	defer func() {
+		if wasSet {
+			os.Setenv(envVar, original)
+		} else {
+			os.Unsetenv(envVar)
+		}
+	}()
+
+	os.Setenv(envVar, "/tmp/custom.rc")
+	if got, err := Path(); err != nil || got != "/tmp/custom.rc" {
+		t.Errorf("Path() = %q, %v, want /tmp/custom.rc", got, err)
+	}
+
+	os.Unsetenv(envVar)
+	home, _ := os.UserHomeDir()
+	if got, err := Path(); err != nil || got != filepath.Join(home, fileName) {
+		t.Errorf("Path() = %q, %v, want %q", got, err, filepath.Join(home, fileName))
+	}
+}
+
+func TestReadWrite(t *testing.T) {
+	path := filepath.Join(t.TempDir(), fileName)
+
+	lines, err := Read(path)
+	if err != nil || len(lines) != 0 {
+		t.Fatalf("Read() of missing file = %v, %v, want empty", lines, err)
+	}
+
+	want := []string{"# completions", "complete -W 'a b' tool", ""}
+	if err := Write(path, want); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+	got, err := Read(path)
+	if err != nil {
+		t.Fatalf("Read() error = %v", err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Read() = %q, want %q", got, want)
+	}
+}
+
+func TestIsCommand(t *testing.T) {
+	tests := []struct {
+		line string
+		want bool
+	}{
+		{line: "", want: false},
+		{line: "   ", want: false},
+		{line: "# comment", want: false},
+		{line: "  # indented comment", want: false},
+		{line: "complete -W 'a' b", want: true},
+	}
+
+	for _, tt := range tests {
+		if got := IsCommand(tt.line); got != tt.want {
+			t.Errorf("IsCommand(%q) = %v, want %v", tt.line, got, tt.want)
+		}
+	}
+}
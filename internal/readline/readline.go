@@ -0,0 +1,482 @@
+package readline
+
+import (
+	"asa/shell/utils"
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/term"
+)
+
+var (
+	ErrInterrupted = errors.New("interrupted")
+)
+
+const (
+	keyCtrlA     = 1
+	keyCtrlB     = 2
+	keyCtrlC     = 3
+	keyCtrlD     = 4
+	keyCtrlE     = 5
+	keyCtrlF     = 6
+	keyCtrlG     = 7
+	keyCtrlH     = 8
+	keyTab       = 9
+	keyLineFeed  = 10
+	keyCtrlK     = 11
+	keyCtrlL     = 12
+	keyEnter     = 13
+	keyCtrlR     = 18
+	keyCtrlU     = 21
+	keyCtrlW     = 23
+	keyEscape    = 27
+	keyBackspace = 127
+)
+
+// Completer returns the candidates for the word that ends at pos in line
+// together with the byte offset where that word starts.
+type Completer interface {
+	Complete(line string, pos int) ([]string, int)
+}
+
+// History returns the past command lines matching query, best match first.
+type History interface {
+	Search(query string) []string
+}
+
+// HistoryFunc adapts an ordinary function to the History interface.
+type HistoryFunc func(query string) []string
+
+func (f HistoryFunc) Search(query string) []string {
+	return f(query)
+}
+
+// Suggester proposes a whole line that starts with the typed line, or ""
+// when it has nothing to offer.
+type Suggester interface {
+	Suggest(line string) string
+}
+
+// SuggesterFunc adapts an ordinary function to the Suggester interface.
+type SuggesterFunc func(line string) string
+
+func (f SuggesterFunc) Suggest(line string) string {
+	return f(line)
+}
+
+// Highlighter decorates a line for display. It may add terminal escape
+// sequences but must not change the visible text.
+type Highlighter interface {
+	Highlight(line string) string
+}
+
+type Editor struct {
+	in          *bufio.Reader
+	out         io.Writer
+	fd          int
+	completer   Completer
+	history     History
+	suggester   Suggester
+	highlighter Highlighter
+	prompt      string
+	buf         []rune
+	pos         int
+	lastQuery   string
+	suggestion  string
+}
+
+// NewEditor creates a line editor reading keys from in and drawing on out.
+// fd is the terminal put in raw mode while a line is read; pass -1 when in
+// is not a terminal.
+func NewEditor(in io.Reader, out io.Writer, fd int, completer Completer) *Editor {
+	return &Editor{
+		in:        bufio.NewReader(in),
+		out:       out,
+		fd:        fd,
+		completer: completer,
+	}
+}
+
+func (e *Editor) SetHistory(history History) {
+	e.history = history
+}
+
+func (e *Editor) SetSuggester(suggester Suggester) {
+	e.suggester = suggester
+}
+
+func (e *Editor) SetHighlighter(highlighter Highlighter) {
+	e.highlighter = highlighter
+}
+
+func IsTerminal(fd int) bool {
+	return term.IsTerminal(fd)
+}
+
+func (e *Editor) ReadLine(prompt string) (string, error) {
+	if e.fd >= 0 {
+		state, err := term.MakeRaw(e.fd)
+		if err != nil {
+			return "", err
+		}
+		defer term.Restore(e.fd, state)
+	}
+	e.prompt = prompt
+	e.buf = e.buf[:0]
+	e.pos = 0
+	e.suggestion = ""
+	e.refresh()
+
+	for {
+		r, _, err := e.in.ReadRune()
+		if err != nil {
+			return "", err
+		}
+		if r == keyCtrlR && e.history != nil {
+			if r, err = e.reverseSearch(); err != nil {
+				return "", err
+			}
+		}
+		if line, done, err := e.handleKey(r); done {
+			return line, err
+		}
+		e.refresh()
+	}
+}
+
+// handleKey applies one key to the line and reports whether reading the
+// line is over.
+func (e *Editor) handleKey(r rune) (string, bool, error) {
+	switch r {
+	case keyEnter, keyLineFeed:
+		e.suggestion = ""
+		e.draw()
+		io.WriteString(e.out, "\r\n")
+		return string(e.buf), true, nil
+	case keyCtrlC:
+		e.suggestion = ""
+		e.draw()
+		io.WriteString(e.out, "^C\r\n")
+		return "", true, ErrInterrupted
+	case keyCtrlD:
+		if len(e.buf) == 0 {
+			io.WriteString(e.out, "\r\n")
+			return "", true, io.EOF
+		}
+		e.delete()
+	case keyTab:
+		e.complete()
+	case keyBackspace, keyCtrlH:
+		e.backspace()
+	case keyCtrlA:
+		e.pos = 0
+	case keyCtrlE:
+		e.pos = len(e.buf)
+	case keyCtrlB:
+		e.moveLeft()
+	case keyCtrlF:
+		e.moveRight()
+	case keyCtrlK:
+		e.buf = e.buf[:e.pos]
+	case keyCtrlU:
+		e.buf = append(e.buf[:0], e.buf[e.pos:]...)
+		e.pos = 0
+	case keyCtrlW:
+		e.deleteWord()
+	case keyCtrlL:
+		io.WriteString(e.out, "\033[H\033[2J")
+	case keyEscape:
+		e.applySequence(e.readSequence())
+	default:
+		if r >= ' ' {
+			e.insert([]rune{r})
+		}
+	}
+	return "", false, nil
+}
+
+// reverseSearch runs a readline style incremental search backwards through
+// the history. It returns the key that ended the search, 0 when the search
+// was left with Escape or aborted with Ctrl-G, so that ReadLine can apply
+// it: Enter runs the found line, editing keys edit it.
+func (e *Editor) reverseSearch() (rune, error) {
+	e.suggestion = ""
+	original := append([]rune{}, e.buf...)
+	originalPos := e.pos
+	query := []rune{}
+	var matches []string
+	index := 0
+	failed := false
+
+	search := func() {
+		matches = nil
+		index = 0
+		failed = false
+		if len(query) > 0 {
+			matches = e.history.Search(string(query))
+			failed = len(matches) == 0
+		}
+		e.showMatch(matches, index, query)
+	}
+
+	for {
+		e.refreshSearch(query, failed)
+		r, _, err := e.in.ReadRune()
+		if err != nil {
+			return 0, err
+		}
+		switch {
+		case r == keyCtrlR:
+			if len(query) == 0 && e.lastQuery != "" {
+				query = []rune(e.lastQuery)
+				search()
+			} else if index+1 < len(matches) {
+				index++
+				e.showMatch(matches, index, query)
+			} else if len(query) > 0 {
+				failed = true
+				io.WriteString(e.out, "\a")
+			}
+		case r == keyBackspace || r == keyCtrlH:
+			if len(query) > 0 {
+				query = query[:len(query)-1]
+				search()
+			}
+		case r == keyCtrlG:
+			e.buf = original
+			e.pos = originalPos
+			e.lastQuery = string(query)
+			return 0, nil
+		case r == keyEscape:
+			e.lastQuery = string(query)
+			e.applySequence(e.readSequence())
+			return 0, nil
+		case r >= ' ':
+			query = append(query, r)
+			search()
+		default:
+			e.lastQuery = string(query)
+			return r, nil
+		}
+	}
+}
+
+// showMatch puts matches[index] in the buffer with the cursor on the
+// matched text.
+func (e *Editor) showMatch(matches []string, index int, query []rune) {
+	if index >= len(matches) {
+		return
+	}
+	e.buf = []rune(matches[index])
+	e.pos = 0
+	if at := strings.Index(matches[index], string(query)); at >= 0 {
+		e.pos = len([]rune(matches[index][:at]))
+	}
+}
+
+func (e *Editor) refreshSearch(query []rune, failed bool) {
+	label := "reverse-i-search"
+	if failed {
+		label = "failed " + label
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "\r(%s)`%s': %s\033[K", label, string(query), string(e.buf))
+	if n := len(e.buf) - e.pos; n > 0 {
+		fmt.Fprintf(&b, "\033[%dD", n)
+	}
+	io.WriteString(e.out, b.String())
+}
+
+// readSequence reads the rest of an escape sequence after ESC. A lone
+// Escape key press, with nothing queued behind it, reads as "".
+func (e *Editor) readSequence() string {
+	if e.in.Buffered() == 0 {
+		return ""
+	}
+	next, err := e.in.Peek(1)
+	if err != nil || (next[0] != '[' && next[0] != 'O') {
+		return ""
+	}
+	e.in.ReadByte()
+	seq := ""
+	for {
+		b, err := e.in.ReadByte()
+		if err != nil {
+			return seq
+		}
+		seq += string(b)
+		if b >= 0x40 && b <= 0x7e {
+			return seq
+		}
+	}
+}
+
+func (e *Editor) applySequence(seq string) {
+	switch seq {
+	case "C":
+		e.moveRight()
+	case "D":
+		e.moveLeft()
+	case "H", "1~", "7~":
+		e.pos = 0
+	case "F", "4~", "8~":
+		e.pos = len(e.buf)
+	case "3~":
+		e.delete()
+	}
+}
+
+func (e *Editor) insert(runes []rune) {
+	tail := append([]rune{}, e.buf[e.pos:]...)
+	e.buf = append(append(e.buf[:e.pos], runes...), tail...)
+	e.pos += len(runes)
+}
+
+func (e *Editor) backspace() {
+	if e.pos == 0 {
+		return
+	}
+	e.buf = append(e.buf[:e.pos-1], e.buf[e.pos:]...)
+	e.pos--
+}
+
+func (e *Editor) delete() {
+	if e.pos >= len(e.buf) {
+		return
+	}
+	e.buf = append(e.buf[:e.pos], e.buf[e.pos+1:]...)
+}
+
+func (e *Editor) deleteWord() {
+	start := e.pos
+	for start > 0 && e.buf[start-1] == ' ' {
+		start--
+	}
+	for start > 0 && e.buf[start-1] != ' ' {
+		start--
+	}
+	e.buf = append(e.buf[:start], e.buf[e.pos:]...)
+	e.pos = start
+}
+
+func (e *Editor) moveLeft() {
+	if e.pos > 0 {
+		e.pos--
+	}
+}
+
+// moveRight moves the cursor one place right; at the end of the line it
+// accepts the pending suggestion instead.
+func (e *Editor) moveRight() {
+	if e.pos < len(e.buf) {
+		e.pos++
+		return
+	}
+	if e.suggestion != "" {
+		e.buf = []rune(e.suggestion)
+		e.pos = len(e.buf)
+	}
+}
+
+func (e *Editor) complete() {
+	if e.completer == nil {
+		return
+	}
+	line := string(e.buf)
+	pos := len(string(e.buf[:e.pos]))
+	candidates, start := e.completer.Complete(line, pos)
+	if len(candidates) == 0 || start < 0 || start > pos {
+		io.WriteString(e.out, "\a")
+		return
+	}
+	word := line[start:pos]
+	if len(candidates) == 1 {
+		replacement := candidates[0]
+		if !strings.HasSuffix(replacement, "/") {
+			replacement += " "
+		}
+		e.replace(start, pos, replacement)
+		return
+	}
+	prefix := commonPrefix(candidates)
+	if len(prefix) > len(word) && strings.HasPrefix(prefix, word) {
+		e.replace(start, pos, prefix)
+		return
+	}
+	fmt.Fprintf(e.out, "\r\n%s\r\n", strings.Join(candidates, "  "))
+}
+
+// replace swaps the bytes [start, end) of the current line for text and
+// leaves the cursor after it.
+func (e *Editor) replace(start, end int, text string) {
+	line := string(e.buf)
+	head := []rune(line[:start] + text)
+	tail := []rune(line[end:])
+	e.buf = append(head, tail...)
+	e.pos = len(head)
+}
+
+func (e *Editor) refresh() {
+	e.suggestion = ""
+	if e.suggester != nil && len(e.buf) > 0 && e.pos == len(e.buf) {
+		line := string(e.buf)
+		if suggestion := e.suggester.Suggest(line); len(suggestion) > len(line) && strings.HasPrefix(suggestion, line) {
+			e.suggestion = suggestion
+		}
+	}
+	e.draw()
+}
+
+// draw repaints the prompt and line, with the rest of the pending
+// suggestion dimmed after the cursor.
+func (e *Editor) draw() {
+	var b strings.Builder
+	b.WriteString("\r")
+	b.WriteString(e.prompt)
+	if e.highlighter != nil {
+		b.WriteString(e.highlighter.Highlight(string(e.buf)))
+	} else {
+		b.WriteString(string(e.buf))
+	}
+	back := len(e.buf) - e.pos
+	if e.suggestion != "" {
+		rest := e.suggestion[len(string(e.buf)):]
+		b.WriteString(utils.ColorText(rest, utils.Dim))
+		back += utf8.RuneCountInString(rest)
+	}
+	b.WriteString("\033[K")
+	if back > 0 {
+		fmt.Fprintf(&b, "\033[%dD", back)
+	}
+	io.WriteString(e.out, b.String())
+}
+
+func commonPrefix(words []string) string {
+	if len(words) == 0 {
+		return ""
+	}
+	prefix := words[0]
+	for _, w := range words[1:] {
+		for !strings.HasPrefix(w, prefix) {
+			_, size := utf8.DecodeLastRuneInString(prefix)
+			prefix = prefix[:len(prefix)-size]
+		}
+	}
+	return prefix
+}
+
+// ReadPassword writes prompt to out and reads a line from the terminal fd
+// with echo disabled.
+func ReadPassword(fd int, out io.Writer, prompt string) (string, error) {
+	fmt.Fprint(out, prompt)
+	password, err := term.ReadPassword(fd)
+	fmt.Fprint(out, "\r\n")
+	if err != nil {
+		return "", err
+	}
+	return string(password), nil
+}
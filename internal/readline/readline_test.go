@@ -0,0 +1,354 @@
+package readline
+
+import (
+	"asa/shell/utils"
+	"bytes"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+type mockCompleter struct {
+	candidates []string
+}
+
+func (m *mockCompleter) Complete(line string, pos int) ([]string, int) {
+	start := strings.LastIndex(line[:pos], " ") + 1
+	matches := []string{}
+	for _, c := range m.candidates {
+		if strings.HasPrefix(c, line[start:pos]) {
+			matches = append(matches, c)
+		}
+	}
+	return matches, start
+}
+
+func TestEditor_ReadLine(t *testing.T) {
+	tests := []struct {
+		name       string
+		input      string
+		candidates []string
+		want       string
+		wantErr    error
+	}{
+		{
+			name:  "plain line",
+			input: "echo hello\r",
+			want:  "echo hello",
+		},
+		{
+			name:  "line feed ends the line",
+			input: "pwd\n",
+			want:  "pwd",
+		},
+		{
+			name:  "backspace",
+			input: "lss\x7f -l\r",
+			want:  "ls -l",
+		},
+		{
+			name:  "cursor movement and insert",
+			input: "cho hi\x01e\r",
+			want:  "echo hi",
+		},
+		{
+			name:  "arrow keys",
+			input: "ech hi\x1b[D\x1b[D\x1b[Do\r",
+			want:  "echo hi",
+		},
+		{
+			name:  "kill to end of line",
+			input: "echo hi there\x1b[D\x1b[D\x1b[D\x1b[D\x1b[D\x1b[D\x0b\r",
+			want:  "echo hi",
+		},
+		{
+			name:  "delete previous word",
+			input: "echo hi there\x17\r",
+			want:  "echo hi ",
+		},
+		{
+			name:  "kill to start of line",
+			input: "garbage\x15ls\r",
+			want:  "ls",
+		},
+		{
+			name:       "single completion adds a space",
+			input:      "hist\tclean\r",
+			candidates: []string{"history"},
+			want:       "history clean",
+		},
+		{
+			name:       "directory completion adds no space",
+			input:      "cd sr\tx\r",
+			candidates: []string{"src/"},
+			want:       "cd src/x",
+		},
+		{
+			name:       "common prefix completion",
+			input:      "lo\t\r",
+			candidates: []string{"login", "logout"},
+			want:       "log",
+		},
+		{
+			name:    "ctrl-c interrupts",
+			input:   "echo\x03",
+			wantErr: ErrInterrupted,
+		},
+		{
+			name:    "ctrl-d on empty line",
+			input:   "\x04",
+			wantErr: io.EOF,
+		},
+		{
+			name:  "ctrl-d deletes under cursor",
+			input: "lsx\x1b[D\x04\r",
+			want:  "ls",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			e := NewEditor(strings.NewReader(tt.input), &out, -1, &mockCompleter{candidates: tt.candidates})
+
+			got, err := e.ReadLine("$ ")
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("ReadLine() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("ReadLine() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEditor_CompletionListsCandidates(t *testing.T) {
+	var out bytes.Buffer
+	e := NewEditor(strings.NewReader("log\t\r"), &out, -1, &mockCompleter{candidates: []string{"login", "logout"}})
+
+	if _, err := e.ReadLine("$ "); err != nil {
+		t.Fatalf("ReadLine() unexpected error: %v", err)
+	}
+	if !strings.Contains(out.String(), "login  logout") {
+		t.Errorf("ReadLine() output = %q, want candidates listed", out.String())
+	}
+}
+
+func TestCommonPrefix(t *testing.T) {
+	tests := []struct {
+		words []string
+		want  string
+	}{
+		{words: nil, want: ""},
+		{words: []string{"login"}, want: "login"},
+		{words: []string{"login", "logout"}, want: "log"},
+		{words: []string{"abc", "xyz"}, want: ""},
+		{words: []string{"añb", "añc"}, want: "añ"},
+	}
+
+	for _, tt := range tests {
+		if got := commonPrefix(tt.words); got != tt.want {
+			t.Errorf("commonPrefix(%v) = %q, want %q", tt.words, got, tt.want)
+		}
+	}
+}
+
+func TestEditor_ReverseSearch(t *testing.T) {
+	history := HistoryFunc(func(query string) []string {
+		lines := []string{"git push", "go test ./...", "git pull"}
+		matches := []string{}
+		for _, line := range lines {
+			if strings.Contains(line, query) {
+				matches = append(matches, line)
+			}
+		}
+		return matches
+	})
+
+	tests := []struct {
+		name    string
+		input   string
+		want    string
+		wantErr error
+	}{
+		{
+			name:  "enter runs the best match",
+			input: "\x12gi\r",
+			want:  "git push",
+		},
+		{
+			name:  "repeated ctrl-r cycles to older matches",
+			input: "\x12gi\x12\r",
+			want:  "git pull",
+		},
+		{
+			name:  "ctrl-r past the last match keeps it",
+			input: "\x12gi\x12\x12\x12\r",
+			want:  "git pull",
+		},
+		{
+			name:  "escape keeps the match for editing",
+			input: "\x12test\x1bX\r",
+			want:  "go Xtest ./...",
+		},
+		{
+			name:  "editing key leaves the search and applies",
+			input: "\x12test\x05 -v\r",
+			want:  "go test ./... -v",
+		},
+		{
+			name:  "arrow key leaves the search",
+			input: "\x12push\x1b[Fx\r",
+			want:  "git pushx",
+		},
+		{
+			name:  "ctrl-g restores the original line",
+			input: "ls\x12git\x07 -l\r",
+			want:  "ls -l",
+		},
+		{
+			name:  "backspace widens the search",
+			input: "\x12gitx\x7f\r",
+			want:  "git push",
+		},
+		{
+			name:  "no match keeps the original line",
+			input: "ls\x12zzz\x07\r",
+			want:  "ls",
+		},
+		{
+			name:    "ctrl-c interrupts",
+			input:   "\x12git\x03",
+			wantErr: ErrInterrupted,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			e := NewEditor(strings.NewReader(tt.input), &out, -1, nil)
+			e.SetHistory(history)
+
+			got, err := e.ReadLine("$ ")
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("ReadLine() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("ReadLine() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEditor_ReverseSearchReusesLastQuery(t *testing.T) {
+	history := HistoryFunc(func(query string) []string {
+		if query == "pull" {
+			return []string{"git pull"}
+		}
+		return nil
+	})
+	var out bytes.Buffer
+	e := NewEditor(strings.NewReader("\x12pull\x07\r\x12\x12\r"), &out, -1, nil)
+	e.SetHistory(history)
+
+	if got, _ := e.ReadLine("$ "); got != "" {
+		t.Fatalf("first ReadLine() = %q, want empty line", got)
+	}
+	if got, _ := e.ReadLine("$ "); got != "git pull" {
+		t.Errorf("second ReadLine() = %q, want %q", got, "git pull")
+	}
+}
+
+func TestEditor_Suggestions(t *testing.T) {
+	suggester := SuggesterFunc(func(line string) string {
+		if strings.HasPrefix("git status --short", line) {
+			return "git status --short"
+		}
+		return ""
+	})
+
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{
+			name:  "right arrow accepts the suggestion",
+			input: "git s\x1b[C\r",
+			want:  "git status --short",
+		},
+		{
+			name:  "ctrl-f accepts the suggestion",
+			input: "gi\x06\r",
+			want:  "git status --short",
+		},
+		{
+			name:  "enter ignores the suggestion",
+			input: "git s\r",
+			want:  "git s",
+		},
+		{
+			name:  "right arrow inside the line only moves",
+			input: "git s\x1b[D\x1b[C!\r",
+			want:  "git s!",
+		},
+		{
+			name:  "diverging input drops the suggestion",
+			input: "git x\x1b[C\r",
+			want:  "git x",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			e := NewEditor(strings.NewReader(tt.input), &out, -1, nil)
+			e.SetSuggester(suggester)
+
+			got, err := e.ReadLine("$ ")
+			if err != nil {
+				t.Fatalf("ReadLine() unexpected error: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("ReadLine() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEditor_SuggestionIsDimmed(t *testing.T) {
+	var out bytes.Buffer
+	e := NewEditor(strings.NewReader("ls\r"), &out, -1, nil)
+	e.SetSuggester(SuggesterFunc(func(line string) string { return "ls -la" }))
+
+	if _, err := e.ReadLine("$ "); err != nil {
+		t.Fatalf("ReadLine() unexpected error: %v", err)
+	}
+	if !strings.Contains(out.String(), utils.ColorText(" -la", utils.Dim)) {
+		t.Errorf("ReadLine() output = %q, want dimmed suggestion", out.String())
+	}
+}
+
+type upperHighlighter struct{}
+
+func (upperHighlighter) Highlight(line string) string {
+	return "<" + line + ">"
+}
+
+func TestEditor_Highlighter(t *testing.T) {
+	var out bytes.Buffer
+	e := NewEditor(strings.NewReader("ls\r"), &out, -1, nil)
+	e.SetHighlighter(upperHighlighter{})
+
+	got, err := e.ReadLine("$ ")
+	if err != nil {
+		t.Fatalf("ReadLine() unexpected error: %v", err)
+	}
+	if got != "ls" {
+		t.Errorf("ReadLine() = %q, want %q", got, "ls")
+	}
+	if !strings.Contains(out.String(), "$ <ls>") {
+		t.Errorf("ReadLine() output = %q, want highlighted line", out.String())
+	}
+}
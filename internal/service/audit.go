@@ -0,0 +1,124 @@
+package user
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+const (
+	// freeAttempts is how many wrong passwords in a row go unpunished.
+	freeAttempts = 3
+	// lockoutBase is the first lockout, doubled by every further failure
+	// up to maxLockout.
+	lockoutBase = 30 * time.Second
+	maxLockout  = time.Hour
+)
+
+var ErrAccountLocked = errors.New("account temporarily locked")
+
+// now is replaced in tests.
+var now = time.Now
+
+// lockout returns how long an account stays locked after its failures-th
+// wrong password in a row.
+func lockout(failures int) time.Duration {
+	if failures < freeAttempts {
+		return 0
+	}
+	d := lockoutBase
+	for i := freeAttempts; i < failures && d < maxLockout; i++ {
+		d *= 2
+	}
+	return min(d, maxLockout)
+}
+
+func checkLocked(user *User) error {
+	if left := user.LockedUntil.Sub(now()); left > 0 {
+		return fmt.Errorf("%w: try again in %s", ErrAccountLocked, left.Round(time.Second))
+	}
+	return nil
+}
+
+// recordFailure counts a wrong password for user and locks the account once
+// there were too many.
+func recordFailure(store UserStore, user *User) error {
+	// The stored count is read again under lock so that concurrent
+	// attempts are all counted.
+	return store.Transaction(func(tx UserStore) error {
+		stored, err := tx.FindUser(user.Username)
+		if err != nil {
+			return fmt.Errorf("failed to record login failure: %w", err)
+		}
+		stored.FailedLogins++
+		if d := lockout(stored.FailedLogins); d > 0 {
+			stored.LockedUntil = now().Add(d)
+		}
+		if err := tx.UpdateUser(&stored, "FailedLogins", "LockedUntil"); err != nil {
+			return fmt.Errorf("failed to record login failure: %w", err)
+		}
+		user.FailedLogins = stored.FailedLogins
+		user.LockedUntil = stored.LockedUntil
+		return nil
+	})
+}
+
+func resetFailures(store UserStore, user *User) error {
+	if user.FailedLogins == 0 {
+		return nil
+	}
+	user.FailedLogins = 0
+	user.LockedUntil = time.Time{}
+	if err := store.UpdateUser(user, "FailedLogins", "LockedUntil"); err != nil {
+		return fmt.Errorf("failed to reset login failures: %w", err)
+	}
+	return nil
+}
+
+// Authenticate logs username in like GetUser, also checking the one-time
+// password code when the account has a second factor, and records the
+// attempt in the login audit under sessionID. A missing password or code
+// is not an attempt yet.
+func Authenticate(store UserStore, username, password, code, sessionID string) (User, error) {
+	user, err := GetUser(store, username, password)
+	if err == nil && MFAEnabled(&user) {
+		err = checkCode(store, &user, code)
+	}
+	if errors.Is(err, ErrPassRequired) || errors.Is(err, ErrCodeRequired) {
+		return user, err
+	}
+	audit := LoginAudit{
+		Username:  username,
+		UserID:    user.ID,
+		Success:   err == nil,
+		SessionID: sessionID,
+		At:        now(),
+	}
+	if err != nil {
+		audit.Reason = err.Error()
+	}
+	if auditErr := store.AddLoginAudit(&audit); auditErr != nil && err == nil {
+		return user, auditErr
+	}
+	return user, err
+}
+
+// LoginHistory returns the last limit login attempts on username, newest
+// first.
+func LoginHistory(store UserStore, username string, limit int) ([]LoginAudit, error) {
+	return store.LoginAudits(username, limit)
+}
+
+// LastLogin summarizes the logins of one account.
+type LastLogin struct {
+	Username string
+	// Last is the latest successful login, zero when there was none.
+	Last LoginAudit
+	// Failures counts the failed attempts since Last.
+	Failures int64
+}
+
+// LastLogins returns the latest successful login of every account.
+func LastLogins(store UserStore) ([]LastLogin, error) {
+	return store.LastLogins()
+}
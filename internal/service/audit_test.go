@@ -0,0 +1,76 @@
+package user
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestLockout(t *testing.T) {
+	tests := []struct {
+		failures int
+		want     time.Duration
+	}{
+		{failures: 1, want: 0},
+		{failures: 2, want: 0},
+		{failures: 3, want: 30 * time.Second},
+		{failures: 4, want: time.Minute},
+		{failures: 6, want: 4 * time.Minute},
+		{failures: 20, want: time.Hour},
+	}
+	for _, tt := range tests {
+		if got := lockout(tt.failures); got != tt.want {
+			t.Errorf("lockout(%d) = %s, want %s", tt.failures, got, tt.want)
+		}
+	}
+}
+
+func TestAuthenticate_Lockout(t *testing.T) {
+	store := NewMemoryStore()
+	setCost(t, "4")
+	clock := time.Unix(1700000000, 0)
+	now = func() time.Time { return clock }
+	t.Cleanup(func() { now = time.Now })
+
+	if err := RegisterUser(store, &User{Username: "locked", Password: "s3cret"}); err != nil {
+		t.Fatalf("RegisterUser() unexpected error: %v", err)
+	}
+
+	for i := 0; i < freeAttempts; i++ {
+		if _, err := Authenticate(store, "locked", "wrong", "", "s1"); !errors.Is(err, ErrWrongPassword) {
+			t.Fatalf("Authenticate() attempt %d error = %v, want %v", i+1, err, ErrWrongPassword)
+		}
+	}
+	if _, err := Authenticate(store, "locked", "s3cret", "", "s1"); !errors.Is(err, ErrAccountLocked) {
+		t.Fatalf("Authenticate() while locked error = %v, want %v", err, ErrAccountLocked)
+	}
+
+	clock = clock.Add(lockoutBase)
+	if _, err := Authenticate(store, "locked", "s3cret", "", "s2"); err != nil {
+		t.Fatalf("Authenticate() after the lockout unexpected error: %v", err)
+	}
+	stored, _ := FindUser(store, "locked")
+	if stored.FailedLogins != 0 || !stored.LockedUntil.IsZero() {
+		t.Errorf("FindUser() = %d failures until %s, want the counter reset", stored.FailedLogins, stored.LockedUntil)
+	}
+
+	if _, err := Authenticate(store, "nobody", "x", "", "s2"); !errors.Is(err, ErrUserNotFound) {
+		t.Errorf("Authenticate() of a missing user error = %v, want %v", err, ErrUserNotFound)
+	}
+
+	audits, err := LoginHistory(store, "locked", 10)
+	if err != nil {
+		t.Fatalf("LoginHistory() unexpected error: %v", err)
+	}
+	if len(audits) != 5 || !audits[0].Success || audits[0].SessionID != "s2" || audits[1].Success {
+		t.Errorf("LoginHistory() = %+v, want the success after four failures", audits)
+	}
+
+	logins, err := LastLogins(store)
+	if err != nil {
+		t.Fatalf("LastLogins() unexpected error: %v", err)
+	}
+	if len(logins) != 1 || logins[0].Last.SessionID != "s2" || logins[0].Failures != 0 {
+		t.Errorf("LastLogins() = %+v, want the latest login of the account", logins)
+	}
+}
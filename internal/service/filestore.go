@@ -0,0 +1,136 @@
+package user
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+)
+
+// defaultEntryLimit is the default of HISTFILESIZE, as for the history
+// file of anonymous sessions.
+const defaultEntryLimit = 500
+
+// FileStore keeps the accounts in a JSON file, read and rewritten by every
+// operation, such as for offline use on a laptop. Shells sharing the file
+// take turns through a lock file beside it, and each user keeps the last
+// HISTFILESIZE history entries only.
+type FileStore struct {
+	lockedStore
+	path string
+}
+
+func NewFileStore(path string) *FileStore {
+	s := &FileStore{path: path}
+	s.load = s.loadFile
+	s.save = s.saveFile
+	s.lock = s.lockFile
+	return s
+}
+
+// lockFile locks the file that serializes the shells sharing the accounts.
+// The accounts file itself is replaced on every save, so it cannot hold the
+// lock.
+func (s *FileStore) lockFile(exclusive bool) (func(), error) {
+	if err := os.MkdirAll(filepath.Dir(s.path), 0700); err != nil {
+		return nil, fmt.Errorf("failed to lock accounts: %w", err)
+	}
+	file, err := os.OpenFile(s.path+".lock", os.O_RDWR|os.O_CREATE, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to lock accounts: %w", err)
+	}
+	if err := lock(file, exclusive); err != nil {
+		file.Close()
+		return nil, fmt.Errorf("failed to lock accounts: %w", err)
+	}
+	return func() {
+		unlock(file)
+		file.Close()
+	}, nil
+}
+
+// entryLimit returns how many history entries the file keeps per user:
+// HISTFILESIZE, no limit when negative and defaultEntryLimit when unset or
+// invalid.
+func entryLimit() int {
+	n, err := strconv.Atoi(os.Getenv("HISTFILESIZE"))
+	if err != nil {
+		return defaultEntryLimit
+	}
+	return n
+}
+
+func (s *FileStore) loadFile() (*memoryData, error) {
+	data := &memoryData{}
+	content, err := os.ReadFile(s.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return data, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read accounts: %w", err)
+	}
+	if err := json.Unmarshal(content, data); err != nil {
+		return nil, fmt.Errorf("failed to decode %s: %w", s.path, err)
+	}
+	if err := moveLegacyHistory(content, data); err != nil {
+		return nil, fmt.Errorf("failed to decode %s: %w", s.path, err)
+	}
+	return data, nil
+}
+
+// moveLegacyHistory adds to data the history counts that older files kept
+// as JSON in the History field of each user. They are dropped from the file
+// the next time it is saved.
+func moveLegacyHistory(content []byte, data *memoryData) error {
+	var legacy struct {
+		Users []struct {
+			ID      int64
+			History string
+		}
+	}
+	if err := json.Unmarshal(content, &legacy); err != nil {
+		return err
+	}
+	for _, user := range legacy.Users {
+		if user.History == "" {
+			continue
+		}
+		if err := data.AddHistory(user.ID, decodeCounts(user.History)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// saveFile replaces the file through a temporary one so that a failed write
+// never leaves it truncated.
+func (s *FileStore) saveFile(data *memoryData) error {
+	if limit := entryLimit(); limit >= 0 {
+		data.trimEntries(limit)
+	}
+	content, err := json.MarshalIndent(data, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode accounts: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(s.path), 0700); err != nil {
+		return fmt.Errorf("failed to write accounts: %w", err)
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), filepath.Base(s.path)+".tmp*")
+	if err != nil {
+		return fmt.Errorf("failed to write accounts: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(content); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write accounts: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write accounts: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), s.path); err != nil {
+		return fmt.Errorf("failed to write accounts: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,365 @@
+package user
+
+import (
+	"asa/shell/internal/database"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// GormStore keeps the accounts in a SQL database.
+type GormStore struct {
+	db *gorm.DB
+	tx bool
+}
+
+func NewGormStore(db *gorm.DB) *GormStore {
+	return &GormStore{db: db}
+}
+
+// Migrate applies the pending schema migrations.
+func (s *GormStore) Migrate() error {
+	_, err := database.MigrateUp(s.db, 0)
+	return err
+}
+
+func (s *GormStore) Transaction(fn func(store UserStore) error) error {
+	if s.tx {
+		return fn(s)
+	}
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		return fn(&GormStore{db: tx, tx: true})
+	})
+}
+
+func (s *GormStore) CreateUser(user *User) error {
+	if err := s.db.Create(user).Error; err != nil {
+		return fmt.Errorf("failed to insert user into database: %w", err)
+	}
+	return nil
+}
+
+func (s *GormStore) FindUser(username string) (User, error) {
+	query := s.db
+	if s.tx {
+		query = query.Clauses(clause.Locking{Strength: "UPDATE"})
+	}
+	var user User
+	if err := query.Where("user_name = ?", username).First(&user).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return user, ErrUserNotFound
+		}
+		return user, err
+	}
+	return user, nil
+}
+
+func (s *GormStore) UpdateUser(user *User, fields ...string) error {
+	if err := s.db.Model(user).Select(fields).Updates(user).Error; err != nil {
+		return fmt.Errorf("failed to update user in database: %w", err)
+	}
+	return nil
+}
+
+func (s *GormStore) DeleteUser(user *User) error {
+	return s.Transaction(func(store UserStore) error {
+		tx := store.(*GormStore).db
+		if err := tx.Where("user_id = ?", user.ID).Delete(&HistoryEntry{}).Error; err != nil {
+			return fmt.Errorf("failed to delete history entries: %w", err)
+		}
+		if err := tx.Where("user_id = ?", user.ID).Delete(&HistoryCount{}).Error; err != nil {
+			return fmt.Errorf("failed to delete history: %w", err)
+		}
+		if err := tx.Where("user_id = ?", user.ID).Delete(&Setting{}).Error; err != nil {
+			return fmt.Errorf("failed to delete settings: %w", err)
+		}
+		if err := tx.Delete(user).Error; err != nil {
+			return fmt.Errorf("failed to delete user from database: %w", err)
+		}
+		return nil
+	})
+}
+
+func (s *GormStore) ListUsers() ([]User, error) {
+	var users []User
+	if err := s.db.Order("user_name").Find(&users).Error; err != nil {
+		return nil, fmt.Errorf("failed to list users: %w", err)
+	}
+	return users, nil
+}
+
+func (s *GormStore) CountRole(role string) (int64, error) {
+	var count int64
+	if err := s.db.Model(&User{}).Where("role = ?", role).Count(&count).Error; err != nil {
+		return 0, fmt.Errorf("failed to look up %s accounts: %w", role, err)
+	}
+	return count, nil
+}
+
+func (s *GormStore) AddHistory(userID int64, delta map[string]int) error {
+	if len(delta) == 0 {
+		return nil
+	}
+	// Rows are written in line order so that concurrent sessions lock them
+	// in the same order.
+	counts := make([]HistoryCount, 0, len(delta))
+	for line, d := range delta {
+		counts = append(counts, HistoryCount{UserID: userID, Line: line, Count: d})
+	}
+	sort.Slice(counts, func(i, j int) bool { return counts[i].Line < counts[j].Line })
+	return s.Transaction(func(store UserStore) error {
+		tx := store.(*GormStore).db
+		err := tx.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "user_id"}, {Name: "line"}},
+			DoUpdates: clause.Assignments(map[string]interface{}{"count": gorm.Expr("history_counts.count + excluded.count")}),
+		}).CreateInBatches(counts, 500).Error
+		if err != nil {
+			return fmt.Errorf("failed to update history in database: %w", err)
+		}
+		if err := tx.Where("user_id = ? AND count <= 0", userID).Delete(&HistoryCount{}).Error; err != nil {
+			return fmt.Errorf("failed to update history in database: %w", err)
+		}
+		return nil
+	})
+}
+
+func (s *GormStore) HistoryCounts(userID int64) (map[string]int, error) {
+	var rows []HistoryCount
+	if err := s.db.Where("user_id = ?", userID).Find(&rows).Error; err != nil {
+		return nil, fmt.Errorf("failed to read history from database: %w", err)
+	}
+	counts := make(map[string]int, len(rows))
+	for _, row := range rows {
+		counts[row.Line] = row.Count
+	}
+	return counts, nil
+}
+
+func (s *GormStore) TopHistory(userID int64, limit int) ([]HistoryCount, error) {
+	return s.topHistory(s.db.Where("user_id = ?", userID), limit)
+}
+
+func (s *GormStore) HistoryWithPrefix(userID int64, prefix string, limit int) ([]HistoryCount, error) {
+	query := s.db.Where("user_id = ?", userID)
+	switch {
+	case prefix == "":
+	case s.db.Dialector.Name() == database.Postgres:
+		// LIKE is case sensitive in PostgreSQL and uses the
+		// text_pattern_ops index of the lines.
+		query = query.Where(`line LIKE ? ESCAPE '\'`, likeEscaper.Replace(prefix)+"%")
+	default:
+		// SQLite compares lines bytewise, so those starting with prefix
+		// are a range of the primary key.
+		query = query.Where("line >= ?", prefix)
+		if end, ok := prefixEnd(prefix); ok {
+			query = query.Where("line < ?", end)
+		}
+	}
+	return s.topHistory(query, limit)
+}
+
+func (s *GormStore) topHistory(query *gorm.DB, limit int) ([]HistoryCount, error) {
+	query = query.Order("count DESC, line")
+	if limit > 0 {
+		query = query.Limit(limit)
+	}
+	counts := []HistoryCount{}
+	if err := query.Find(&counts).Error; err != nil {
+		return nil, fmt.Errorf("failed to read history from database: %w", err)
+	}
+	return counts, nil
+}
+
+var likeEscaper = strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`)
+
+// prefixEnd returns the smallest string greater than every string starting
+// with prefix, if there is one.
+func prefixEnd(prefix string) (string, bool) {
+	end := []byte(prefix)
+	for i := len(end) - 1; i >= 0; i-- {
+		if end[i] < 0xff {
+			end[i]++
+			return string(end[:i+1]), true
+		}
+	}
+	return "", false
+}
+
+func (s *GormStore) ClearHistory(userID int64) error {
+	if err := s.db.Where("user_id = ?", userID).Delete(&HistoryCount{}).Error; err != nil {
+		return fmt.Errorf("failed to clear history: %w", err)
+	}
+	return nil
+}
+
+func (s *GormStore) AddHistoryEntries(entries []HistoryEntry) error {
+	if len(entries) == 0 {
+		return nil
+	}
+	if err := s.db.CreateInBatches(entries, 500).Error; err != nil {
+		return fmt.Errorf("failed to insert history entries into database: %w", err)
+	}
+	return nil
+}
+
+func (s *GormStore) HistoryEntries(userID int64) ([]HistoryEntry, error) {
+	var entries []HistoryEntry
+	err := s.db.Where("user_id = ?", userID).Order("started_at, id").Find(&entries).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to read history entries: %w", err)
+	}
+	return entries, nil
+}
+
+func (s *GormStore) RecentHistoryEntries(userID, afterID int64, limit int) ([]HistoryEntry, error) {
+	query := s.db.Where("user_id = ? AND id > ?", userID, afterID).Order("started_at DESC, id DESC")
+	if limit > 0 {
+		query = query.Limit(limit)
+	}
+	var entries []HistoryEntry
+	if err := query.Find(&entries).Error; err != nil {
+		return nil, fmt.Errorf("failed to read history entries: %w", err)
+	}
+	for i, j := 0, len(entries)-1; i < j; i, j = i+1, j-1 {
+		entries[i], entries[j] = entries[j], entries[i]
+	}
+	return entries, nil
+}
+
+func (s *GormStore) ClearHistoryEntries(userID int64) error {
+	err := s.db.Where("user_id = ?", userID).Delete(&HistoryEntry{}).Error
+	if err != nil {
+		return fmt.Errorf("failed to clear history entries: %w", err)
+	}
+	return nil
+}
+
+func (s *GormStore) AddLoginAudit(audit *LoginAudit) error {
+	if err := s.db.Create(audit).Error; err != nil {
+		return fmt.Errorf("failed to record login: %w", err)
+	}
+	return nil
+}
+
+func (s *GormStore) LoginAudits(username string, limit int) ([]LoginAudit, error) {
+	query := s.db.Where("username = ?", username).Order("at DESC, id DESC")
+	if limit > 0 {
+		query = query.Limit(limit)
+	}
+	var audits []LoginAudit
+	if err := query.Find(&audits).Error; err != nil {
+		return nil, fmt.Errorf("failed to read login audit: %w", err)
+	}
+	return audits, nil
+}
+
+// lastLoginsQuery finds the latest success of each account with a window
+// over its audits, then counts the failures that came after it.
+const lastLoginsQuery = `
+WITH last AS (
+    SELECT id, user_id, at FROM (
+        SELECT id, user_id, at, ROW_NUMBER() OVER (PARTITION BY user_id ORDER BY at DESC, id DESC) AS position
+        FROM login_audit WHERE success
+    ) ranked WHERE position = 1
+), failures AS (
+    SELECT failed.user_id, COUNT(*) AS failures FROM login_audit failed
+    LEFT JOIN last ON last.user_id = failed.user_id
+    WHERE NOT failed.success
+        AND (last.id IS NULL OR failed.at > last.at OR (failed.at = last.at AND failed.id > last.id))
+    GROUP BY failed.user_id
+)
+SELECT users.user_name AS username, last.id AS last_id, COALESCE(failures.failures, 0) AS failures
+FROM users
+LEFT JOIN last ON last.user_id = users.id
+LEFT JOIN failures ON failures.user_id = users.id
+ORDER BY users.user_name`
+
+func (s *GormStore) LastLogins() ([]LastLogin, error) {
+	var rows []struct {
+		Username string
+		LastID   *int64
+		Failures int64
+	}
+	if err := s.db.Raw(lastLoginsQuery).Scan(&rows).Error; err != nil {
+		return nil, fmt.Errorf("failed to read login audit: %w", err)
+	}
+	ids := []int64{}
+	for _, row := range rows {
+		if row.LastID != nil {
+			ids = append(ids, *row.LastID)
+		}
+	}
+	last := map[int64]LoginAudit{}
+	if len(ids) > 0 {
+		var audits []LoginAudit
+		if err := s.db.Where("id IN ?", ids).Find(&audits).Error; err != nil {
+			return nil, fmt.Errorf("failed to read login audit: %w", err)
+		}
+		for _, audit := range audits {
+			last[audit.ID] = audit
+		}
+	}
+	logins := make([]LastLogin, len(rows))
+	for i, row := range rows {
+		logins[i] = LastLogin{Username: row.Username, Failures: row.Failures}
+		if row.LastID != nil {
+			logins[i].Last = last[*row.LastID]
+		}
+	}
+	return logins, nil
+}
+
+func (s *GormStore) Permissions() ([]Permission, error) {
+	var rules []Permission
+	if err := s.db.Find(&rules).Error; err != nil {
+		return nil, fmt.Errorf("failed to read permissions: %w", err)
+	}
+	return rules, nil
+}
+
+func (s *GormStore) SavePermission(rule Permission) error {
+	err := s.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "role"}, {Name: "command"}},
+		DoUpdates: clause.AssignmentColumns([]string{"allowed"}),
+	}).Create(&rule).Error
+	if err != nil {
+		return fmt.Errorf("failed to save permission: %w", err)
+	}
+	return nil
+}
+
+func (s *GormStore) DeletePermission(role, command string) error {
+	err := s.db.Where("role = ? AND command = ?", role, command).Delete(&Permission{}).Error
+	if err != nil {
+		return fmt.Errorf("failed to delete permission: %w", err)
+	}
+	return nil
+}
+
+func (s *GormStore) Settings(userID int64) ([]Setting, error) {
+	var settings []Setting
+	if err := s.db.Where("user_id = ?", userID).Order("kind, name").Find(&settings).Error; err != nil {
+		return nil, fmt.Errorf("failed to read settings: %w", err)
+	}
+	return settings, nil
+}
+
+func (s *GormStore) ReplaceSettings(userID int64, settings []Setting) error {
+	return s.Transaction(func(store UserStore) error {
+		tx := store.(*GormStore).db
+		if err := tx.Where("user_id = ?", userID).Delete(&Setting{}).Error; err != nil {
+			return fmt.Errorf("failed to delete settings: %w", err)
+		}
+		if len(settings) == 0 {
+			return nil
+		}
+		if err := tx.Create(&settings).Error; err != nil {
+			return fmt.Errorf("failed to save settings: %w", err)
+		}
+		return nil
+	})
+}
@@ -0,0 +1,56 @@
+package user
+
+import (
+	"errors"
+)
+
+var (
+	ErrEntryShouldntNill = errors.New("history entry cannot be nil")
+)
+
+func AddHistoryEntry(store UserStore, entry *HistoryEntry) error {
+	if entry == nil {
+		return ErrEntryShouldntNill
+	}
+	entries := []HistoryEntry{*entry}
+	if err := store.AddHistoryEntries(entries); err != nil {
+		return err
+	}
+	*entry = entries[0]
+	return nil
+}
+
+// AddHistoryEntries inserts entries in one batch, as history imports do.
+func AddHistoryEntries(store UserStore, entries []HistoryEntry) error {
+	if len(entries) == 0 {
+		return nil
+	}
+	return store.AddHistoryEntries(entries)
+}
+
+// GetHistoryEntries returns the user's history entries, oldest first.
+func GetHistoryEntries(store UserStore, userID int64) ([]HistoryEntry, error) {
+	return store.HistoryEntries(userID)
+}
+
+// RecentHistoryEntries returns the last limit history entries of the user
+// recorded after the entry afterID, oldest first.
+func RecentHistoryEntries(store UserStore, userID, afterID int64, limit int) ([]HistoryEntry, error) {
+	return store.RecentHistoryEntries(userID, afterID, limit)
+}
+
+func ClearHistoryEntries(store UserStore, userID int64) error {
+	return store.ClearHistoryEntries(userID)
+}
+
+// TopHistory returns the limit command lines the user ran most, most run
+// first.
+func TopHistory(store UserStore, userID int64, limit int) ([]HistoryCount, error) {
+	return store.TopHistory(userID, limit)
+}
+
+// HistoryWithPrefix returns the limit command lines starting with prefix
+// that the user ran most, most run first.
+func HistoryWithPrefix(store UserStore, userID int64, prefix string, limit int) ([]HistoryCount, error) {
+	return store.HistoryWithPrefix(userID, prefix, limit)
+}
@@ -0,0 +1,135 @@
+package user
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func setupHistoryDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open("file:history_test?mode=memory&cache=shared"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("failed to connect to test database: %v", err)
+	}
+	if err := NewGormStore(db).Migrate(); err != nil {
+		t.Fatalf("failed to migrate database: %v", err)
+	}
+	t.Cleanup(func() {
+		sqlDB, _ := db.DB()
+		sqlDB.Close()
+	})
+	return db
+}
+
+func TestHistoryEntries(t *testing.T) {
+	store := NewMemoryStore()
+	start := time.Date(2025, 3, 1, 9, 0, 0, 0, time.UTC)
+
+	if err := AddHistoryEntry(store, nil); !errors.Is(err, ErrEntryShouldntNill) {
+		t.Errorf("AddHistoryEntry(nil) error = %v, want %v", err, ErrEntryShouldntNill)
+	}
+
+	entries := []HistoryEntry{
+		{UserID: 1, Command: "pwd", StartedAt: start.Add(time.Minute), ExitStatus: 0},
+		{UserID: 1, Command: "ls", StartedAt: start, Cwd: "/tmp", ExitStatus: 2},
+		{UserID: 2, Command: "whoami", StartedAt: start},
+	}
+	for i := range entries {
+		if err := AddHistoryEntry(store, &entries[i]); err != nil {
+			t.Fatalf("AddHistoryEntry() unexpected error: %v", err)
+		}
+	}
+
+	if err := AddHistoryEntries(store, nil); err != nil {
+		t.Errorf("AddHistoryEntries(nil) unexpected error: %v", err)
+	}
+	imported := []HistoryEntry{
+		{UserID: 2, Command: "make", StartedAt: start.Add(-time.Hour)},
+		{UserID: 2, Command: "make test", StartedAt: start.Add(-time.Minute)},
+	}
+	if err := AddHistoryEntries(store, imported); err != nil {
+		t.Fatalf("AddHistoryEntries() unexpected error: %v", err)
+	}
+
+	got, err := GetHistoryEntries(store, 1)
+	if err != nil {
+		t.Fatalf("GetHistoryEntries() unexpected error: %v", err)
+	}
+	if len(got) != 2 || got[0].Command != "ls" || got[1].Command != "pwd" {
+		t.Fatalf("GetHistoryEntries() = %+v, want ls then pwd", got)
+	}
+	if got[0].Cwd != "/tmp" || got[0].ExitStatus != 2 {
+		t.Errorf("GetHistoryEntries()[0] = %+v, want cwd /tmp and status 2", got[0])
+	}
+
+	if err := ClearHistoryEntries(store, 1); err != nil {
+		t.Fatalf("ClearHistoryEntries() unexpected error: %v", err)
+	}
+	if got, _ := GetHistoryEntries(store, 1); len(got) != 0 {
+		t.Errorf("GetHistoryEntries() after clear = %+v, want none", got)
+	}
+	if got, _ := GetHistoryEntries(store, 2); len(got) != 3 || got[0].Command != "make" {
+		t.Errorf("GetHistoryEntries() for the other user = %+v, want its three entries oldest first", got)
+	}
+}
+
+func TestSyncHistory_ConcurrentSessions(t *testing.T) {
+	store := NewGormStore(setupHistoryDB(t))
+	if err := RegisterUser(store, &User{Username: "sync_user"}); err != nil {
+		t.Fatalf("RegisterUser() unexpected error: %v", err)
+	}
+	first, _ := GetUser(store, "sync_user", "")
+	second, _ := GetUser(store, "sync_user", "")
+
+	first.HistoryMap["ls"] += 2
+	if err := SyncHistory(store, &first, false); err != nil {
+		t.Fatalf("SyncHistory() unexpected error: %v", err)
+	}
+	second.HistoryMap["pwd"]++
+	second.HistoryMap["ls"]++
+	if err := SyncHistory(store, &second, false); err != nil {
+		t.Fatalf("SyncHistory() unexpected error: %v", err)
+	}
+	if len(second.HistoryMap) != 2 || second.HistoryMap["ls"] != 1 {
+		t.Errorf("SyncHistory() without sharing changed the session counts to %v", second.HistoryMap)
+	}
+
+	// Saving on logout must not overwrite what the other session recorded.
+	first.HistoryMap["make"]++
+	if err := Update(store, &first); err != nil {
+		t.Fatalf("Update() unexpected error: %v", err)
+	}
+	if err := Update(store, &second); err != nil {
+		t.Fatalf("Update() unexpected error: %v", err)
+	}
+
+	want := map[string]int{"ls": 3, "pwd": 1, "make": 1}
+	stored, _ := GetUser(store, "sync_user", "")
+	if !reflect.DeepEqual(stored.HistoryMap, want) {
+		t.Errorf("stored history = %v, want %v", stored.HistoryMap, want)
+	}
+
+	if err := SyncHistory(store, &second, true); err != nil {
+		t.Fatalf("SyncHistory() unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(second.HistoryMap, want) {
+		t.Errorf("SyncHistory() with sharing = %v, want %v", second.HistoryMap, want)
+	}
+
+	if err := ClearHistory(store, &first); err != nil {
+		t.Fatalf("ClearHistory() unexpected error: %v", err)
+	}
+	// The other session's next sync only removes what it had seen.
+	second.HistoryMap["cd"]++
+	if err := SyncHistory(store, &second, true); err != nil {
+		t.Fatalf("SyncHistory() unexpected error: %v", err)
+	}
+	if want := map[string]int{"cd": 1}; !reflect.DeepEqual(second.HistoryMap, want) {
+		t.Errorf("SyncHistory() after clear = %v, want %v", second.HistoryMap, want)
+	}
+}
@@ -0,0 +1,59 @@
+package user
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// homeBaseEnv sets the directory the homes of users are created in,
+// ~/.shell/home by default.
+const homeBaseEnv = "SHELL_HOME_BASE"
+
+var ErrInvalidHome = errors.New("username cannot name a home directory")
+
+func homeBase() (string, error) {
+	if base := os.Getenv(homeBaseEnv); base != "" {
+		return base, nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".shell", "home"), nil
+}
+
+// validHomeName reports whether name can be used as a directory name under
+// the home base without escaping it.
+func validHomeName(name string) bool {
+	return name != "" && name != "." && name != ".." && !strings.ContainsRune(name, filepath.Separator)
+}
+
+// EnsureHome creates the home directory of user, first assigning it one
+// named after the username when it has none yet.
+func EnsureHome(store UserStore, user *User) error {
+	if user == nil {
+		return ErrUserShouldntNill
+	}
+	if user.HomeDir == "" {
+		if !validHomeName(user.Username) {
+			return ErrInvalidHome
+		}
+		base, err := homeBase()
+		if err != nil {
+			return fmt.Errorf("failed to locate home directories: %w", err)
+		}
+		assigned := *user
+		assigned.HomeDir = filepath.Join(base, user.Username)
+		if err := store.UpdateUser(&assigned, "HomeDir"); err != nil {
+			return fmt.Errorf("failed to update home directory in database: %w", err)
+		}
+		user.HomeDir = assigned.HomeDir
+	}
+	if err := os.MkdirAll(user.HomeDir, 0700); err != nil {
+		return fmt.Errorf("failed to create home directory: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,15 @@
+//go:build !unix
+
+package user
+
+import "os"
+
+// Other platforms go without locking; concurrent shells sharing an accounts
+// file may then lose each other's changes.
+func lock(file *os.File, exclusive bool) error {
+	return nil
+}
+
+func unlock(file *os.File) error {
+	return nil
+}
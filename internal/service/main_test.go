@@ -0,0 +1,29 @@
+package user
+
+import (
+	db "asa/shell/internal/database"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// TestMain runs the tests against a temporary SQLite database unless
+// SHELL_DB_DRIVER picks one.
+func TestMain(m *testing.M) {
+	dir, err := os.MkdirTemp("", "service-test")
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	if _, ok := os.LookupEnv("SHELL_DB_DRIVER"); !ok {
+		os.Setenv("SHELL_DB_DRIVER", db.SQLite)
+		os.Setenv("SHELL_DB_PATH", filepath.Join(dir, "test.db"))
+	}
+	if database, err := db.Connect(); err == nil {
+		NewGormStore(database).Migrate()
+	}
+	code := m.Run()
+	os.RemoveAll(dir)
+	os.Exit(code)
+}
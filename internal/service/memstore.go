@@ -0,0 +1,557 @@
+package user
+
+import (
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// memoryData is what the in-memory and file stores hold. Its methods
+// implement UserStore without locking; lockedStore serializes them.
+type memoryData struct {
+	LastID      int64
+	Users       []User
+	Counts      []HistoryCount
+	Entries     []HistoryEntry
+	Audits      []LoginAudit
+	Rules       []Permission
+	Preferences []Setting
+}
+
+func (d *memoryData) nextID() int64 {
+	d.LastID++
+	return d.LastID
+}
+
+func (d *memoryData) copy() *memoryData {
+	return &memoryData{
+		LastID:      d.LastID,
+		Users:       append([]User(nil), d.Users...),
+		Counts:      append([]HistoryCount(nil), d.Counts...),
+		Entries:     append([]HistoryEntry(nil), d.Entries...),
+		Audits:      append([]LoginAudit(nil), d.Audits...),
+		Rules:       append([]Permission(nil), d.Rules...),
+		Preferences: append([]Setting(nil), d.Preferences...),
+	}
+}
+
+func (d *memoryData) Transaction(fn func(store UserStore) error) error {
+	return fn(d)
+}
+
+func (d *memoryData) CreateUser(user *User) error {
+	if _, err := d.FindUser(user.Username); err == nil {
+		return ErrDuplicateUser
+	}
+	user.ID = d.nextID()
+	if user.Role == "" {
+		user.Role = RoleUser
+	}
+	d.Users = append(d.Users, stored(user))
+	return nil
+}
+
+// stored returns the copy of user kept by the store, without the session
+// state.
+func stored(user *User) User {
+	return User{
+		ID:           user.ID,
+		Username:     user.Username,
+		Password:     user.Password,
+		Role:         user.Role,
+		HomeDir:      user.HomeDir,
+		FailedLogins: user.FailedLogins,
+		LockedUntil:  user.LockedUntil,
+		TOTPSecret:   user.TOTPSecret,
+		TOTPStep:     user.TOTPStep,
+	}
+}
+
+func (d *memoryData) FindUser(username string) (User, error) {
+	for _, user := range d.Users {
+		if user.Username == username {
+			return user, nil
+		}
+	}
+	return User{}, ErrUserNotFound
+}
+
+func (d *memoryData) UpdateUser(user *User, fields ...string) error {
+	for i := range d.Users {
+		if d.Users[i].ID != user.ID {
+			continue
+		}
+		target := reflect.ValueOf(&d.Users[i]).Elem()
+		source := reflect.ValueOf(user).Elem()
+		for _, field := range fields {
+			target.FieldByName(field).Set(source.FieldByName(field))
+		}
+	}
+	return nil
+}
+
+func (d *memoryData) DeleteUser(user *User) error {
+	d.Users = deleteWhere(d.Users, func(u User) bool { return u.ID == user.ID })
+	d.Counts = deleteWhere(d.Counts, func(c HistoryCount) bool { return c.UserID == user.ID })
+	d.Entries = deleteWhere(d.Entries, func(e HistoryEntry) bool { return e.UserID == user.ID })
+	d.Preferences = deleteWhere(d.Preferences, func(s Setting) bool { return s.UserID == user.ID })
+	return nil
+}
+
+func (d *memoryData) ListUsers() ([]User, error) {
+	users := append([]User(nil), d.Users...)
+	sort.Slice(users, func(i, j int) bool { return users[i].Username < users[j].Username })
+	return users, nil
+}
+
+func (d *memoryData) CountRole(role string) (int64, error) {
+	var count int64
+	for _, user := range d.Users {
+		if user.Role == role {
+			count++
+		}
+	}
+	return count, nil
+}
+
+func (d *memoryData) AddHistory(userID int64, delta map[string]int) error {
+	counts, err := d.HistoryCounts(userID)
+	if err != nil {
+		return err
+	}
+	counts = addCounts(counts, delta)
+	d.Counts = deleteWhere(d.Counts, func(c HistoryCount) bool { return c.UserID == userID })
+	for line, count := range counts {
+		d.Counts = append(d.Counts, HistoryCount{UserID: userID, Line: line, Count: count})
+	}
+	return nil
+}
+
+func (d *memoryData) HistoryCounts(userID int64) (map[string]int, error) {
+	counts := map[string]int{}
+	for _, count := range d.Counts {
+		if count.UserID == userID {
+			counts[count.Line] = count.Count
+		}
+	}
+	return counts, nil
+}
+
+func (d *memoryData) TopHistory(userID int64, limit int) ([]HistoryCount, error) {
+	return d.HistoryWithPrefix(userID, "", limit)
+}
+
+func (d *memoryData) HistoryWithPrefix(userID int64, prefix string, limit int) ([]HistoryCount, error) {
+	counts := []HistoryCount{}
+	for _, count := range d.Counts {
+		if count.UserID == userID && strings.HasPrefix(count.Line, prefix) {
+			counts = append(counts, count)
+		}
+	}
+	sort.Slice(counts, func(i, j int) bool {
+		if counts[i].Count != counts[j].Count {
+			return counts[i].Count > counts[j].Count
+		}
+		return counts[i].Line < counts[j].Line
+	})
+	if limit > 0 && len(counts) > limit {
+		counts = counts[:limit]
+	}
+	return counts, nil
+}
+
+func (d *memoryData) ClearHistory(userID int64) error {
+	d.Counts = deleteWhere(d.Counts, func(c HistoryCount) bool { return c.UserID == userID })
+	return nil
+}
+
+func (d *memoryData) AddHistoryEntries(entries []HistoryEntry) error {
+	for i := range entries {
+		entries[i].ID = d.nextID()
+		d.Entries = append(d.Entries, entries[i])
+	}
+	return nil
+}
+
+func (d *memoryData) HistoryEntries(userID int64) ([]HistoryEntry, error) {
+	entries := []HistoryEntry{}
+	for _, entry := range d.Entries {
+		if entry.UserID == userID {
+			entries = append(entries, entry)
+		}
+	}
+	sort.SliceStable(entries, func(i, j int) bool {
+		if !entries[i].StartedAt.Equal(entries[j].StartedAt) {
+			return entries[i].StartedAt.Before(entries[j].StartedAt)
+		}
+		return entries[i].ID < entries[j].ID
+	})
+	return entries, nil
+}
+
+func (d *memoryData) RecentHistoryEntries(userID, afterID int64, limit int) ([]HistoryEntry, error) {
+	entries, err := d.HistoryEntries(userID)
+	if err != nil {
+		return nil, err
+	}
+	recent := entries[:0]
+	for _, entry := range entries {
+		if entry.ID > afterID {
+			recent = append(recent, entry)
+		}
+	}
+	if limit > 0 && len(recent) > limit {
+		recent = recent[len(recent)-limit:]
+	}
+	return recent, nil
+}
+
+func (d *memoryData) ClearHistoryEntries(userID int64) error {
+	d.Entries = deleteWhere(d.Entries, func(e HistoryEntry) bool { return e.UserID == userID })
+	return nil
+}
+
+func (d *memoryData) AddLoginAudit(audit *LoginAudit) error {
+	audit.ID = d.nextID()
+	d.Audits = append(d.Audits, *audit)
+	return nil
+}
+
+func (d *memoryData) LoginAudits(username string, limit int) ([]LoginAudit, error) {
+	audits := []LoginAudit{}
+	for _, audit := range d.Audits {
+		if audit.Username == username {
+			audits = append(audits, audit)
+		}
+	}
+	sort.SliceStable(audits, func(i, j int) bool {
+		if !audits[i].At.Equal(audits[j].At) {
+			return audits[i].At.After(audits[j].At)
+		}
+		return audits[i].ID > audits[j].ID
+	})
+	if limit > 0 && len(audits) > limit {
+		audits = audits[:limit]
+	}
+	return audits, nil
+}
+
+func (d *memoryData) LastLogins() ([]LastLogin, error) {
+	last := map[int64]LoginAudit{}
+	for _, audit := range d.Audits {
+		if audit.Success && newer(audit, last[audit.UserID]) {
+			last[audit.UserID] = audit
+		}
+	}
+	failures := map[int64]int64{}
+	for _, audit := range d.Audits {
+		if !audit.Success && newer(audit, last[audit.UserID]) {
+			failures[audit.UserID]++
+		}
+	}
+	users, _ := d.ListUsers()
+	logins := make([]LastLogin, len(users))
+	for i, user := range users {
+		logins[i] = LastLogin{Username: user.Username, Last: last[user.ID], Failures: failures[user.ID]}
+	}
+	return logins, nil
+}
+
+// newer reports whether audit was recorded after other.
+func newer(audit, other LoginAudit) bool {
+	if !audit.At.Equal(other.At) {
+		return audit.At.After(other.At)
+	}
+	return audit.ID > other.ID
+}
+
+func (d *memoryData) Permissions() ([]Permission, error) {
+	return append([]Permission(nil), d.Rules...), nil
+}
+
+func (d *memoryData) SavePermission(rule Permission) error {
+	for i := range d.Rules {
+		if d.Rules[i].Role == rule.Role && d.Rules[i].Command == rule.Command {
+			d.Rules[i].Allowed = rule.Allowed
+			return nil
+		}
+	}
+	rule.ID = d.nextID()
+	d.Rules = append(d.Rules, rule)
+	return nil
+}
+
+func (d *memoryData) DeletePermission(role, command string) error {
+	d.Rules = deleteWhere(d.Rules, func(p Permission) bool {
+		return p.Role == role && p.Command == command
+	})
+	return nil
+}
+
+func (d *memoryData) Settings(userID int64) ([]Setting, error) {
+	settings := []Setting{}
+	for _, setting := range d.Preferences {
+		if setting.UserID == userID {
+			settings = append(settings, setting)
+		}
+	}
+	sort.Slice(settings, func(i, j int) bool {
+		if settings[i].Kind != settings[j].Kind {
+			return settings[i].Kind < settings[j].Kind
+		}
+		return settings[i].Name < settings[j].Name
+	})
+	return settings, nil
+}
+
+func (d *memoryData) ReplaceSettings(userID int64, settings []Setting) error {
+	d.Preferences = deleteWhere(d.Preferences, func(s Setting) bool { return s.UserID == userID })
+	for i := range settings {
+		settings[i].ID = d.nextID()
+		d.Preferences = append(d.Preferences, settings[i])
+	}
+	return nil
+}
+
+// trimEntries drops the oldest history entries of each user beyond limit.
+func (d *memoryData) trimEntries(limit int) {
+	left := map[int64]int{}
+	for i := len(d.Entries) - 1; i >= 0; i-- {
+		left[d.Entries[i].UserID]++
+	}
+	d.Entries = deleteWhere(d.Entries, func(e HistoryEntry) bool {
+		if left[e.UserID] > limit {
+			left[e.UserID]--
+			return true
+		}
+		return false
+	})
+}
+
+func deleteWhere[T any](items []T, match func(T) bool) []T {
+	kept := items[:0]
+	for _, item := range items {
+		if !match(item) {
+			kept = append(kept, item)
+		}
+	}
+	return kept
+}
+
+// lockedStore runs every operation on the data returned by load, one at a
+// time, and hands the data to save after the operations that succeeded.
+// When set, lock also keeps other processes out from load to save, shared
+// for reads and exclusive for writes, until the returned function is
+// called.
+type lockedStore struct {
+	mu   sync.Mutex
+	load func() (*memoryData, error)
+	save func(*memoryData) error
+	lock func(exclusive bool) (func(), error)
+}
+
+func (s *lockedStore) Transaction(fn func(store UserStore) error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	unlock, err := s.acquire(true)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	data, err := s.load()
+	if err != nil {
+		return err
+	}
+	if err := fn(data); err != nil {
+		return err
+	}
+	return s.save(data)
+}
+
+func (s *lockedStore) read(fn func(data *memoryData) error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	unlock, err := s.acquire(false)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	data, err := s.load()
+	if err != nil {
+		return err
+	}
+	return fn(data)
+}
+
+func (s *lockedStore) acquire(exclusive bool) (func(), error) {
+	if s.lock == nil {
+		return func() {}, nil
+	}
+	return s.lock(exclusive)
+}
+
+func (s *lockedStore) write(fn func(data *memoryData) error) error {
+	return s.Transaction(func(store UserStore) error {
+		return fn(store.(*memoryData))
+	})
+}
+
+func (s *lockedStore) CreateUser(user *User) error {
+	return s.write(func(d *memoryData) error { return d.CreateUser(user) })
+}
+
+func (s *lockedStore) FindUser(username string) (user User, err error) {
+	err = s.read(func(d *memoryData) error {
+		user, err = d.FindUser(username)
+		return err
+	})
+	return user, err
+}
+
+func (s *lockedStore) UpdateUser(user *User, fields ...string) error {
+	return s.write(func(d *memoryData) error { return d.UpdateUser(user, fields...) })
+}
+
+func (s *lockedStore) DeleteUser(user *User) error {
+	return s.write(func(d *memoryData) error { return d.DeleteUser(user) })
+}
+
+func (s *lockedStore) ListUsers() (users []User, err error) {
+	err = s.read(func(d *memoryData) error {
+		users, err = d.ListUsers()
+		return err
+	})
+	return users, err
+}
+
+func (s *lockedStore) CountRole(role string) (count int64, err error) {
+	err = s.read(func(d *memoryData) error {
+		count, err = d.CountRole(role)
+		return err
+	})
+	return count, err
+}
+
+func (s *lockedStore) AddHistory(userID int64, delta map[string]int) error {
+	return s.write(func(d *memoryData) error { return d.AddHistory(userID, delta) })
+}
+
+func (s *lockedStore) HistoryCounts(userID int64) (counts map[string]int, err error) {
+	err = s.read(func(d *memoryData) error {
+		counts, err = d.HistoryCounts(userID)
+		return err
+	})
+	return counts, err
+}
+
+func (s *lockedStore) TopHistory(userID int64, limit int) (counts []HistoryCount, err error) {
+	err = s.read(func(d *memoryData) error {
+		counts, err = d.TopHistory(userID, limit)
+		return err
+	})
+	return counts, err
+}
+
+func (s *lockedStore) HistoryWithPrefix(userID int64, prefix string, limit int) (counts []HistoryCount, err error) {
+	err = s.read(func(d *memoryData) error {
+		counts, err = d.HistoryWithPrefix(userID, prefix, limit)
+		return err
+	})
+	return counts, err
+}
+
+func (s *lockedStore) ClearHistory(userID int64) error {
+	return s.write(func(d *memoryData) error { return d.ClearHistory(userID) })
+}
+
+func (s *lockedStore) AddHistoryEntries(entries []HistoryEntry) error {
+	return s.write(func(d *memoryData) error { return d.AddHistoryEntries(entries) })
+}
+
+func (s *lockedStore) HistoryEntries(userID int64) (entries []HistoryEntry, err error) {
+	err = s.read(func(d *memoryData) error {
+		entries, err = d.HistoryEntries(userID)
+		return err
+	})
+	return entries, err
+}
+
+func (s *lockedStore) RecentHistoryEntries(userID, afterID int64, limit int) (entries []HistoryEntry, err error) {
+	err = s.read(func(d *memoryData) error {
+		entries, err = d.RecentHistoryEntries(userID, afterID, limit)
+		return err
+	})
+	return entries, err
+}
+
+func (s *lockedStore) ClearHistoryEntries(userID int64) error {
+	return s.write(func(d *memoryData) error { return d.ClearHistoryEntries(userID) })
+}
+
+func (s *lockedStore) AddLoginAudit(audit *LoginAudit) error {
+	return s.write(func(d *memoryData) error { return d.AddLoginAudit(audit) })
+}
+
+func (s *lockedStore) LoginAudits(username string, limit int) (audits []LoginAudit, err error) {
+	err = s.read(func(d *memoryData) error {
+		audits, err = d.LoginAudits(username, limit)
+		return err
+	})
+	return audits, err
+}
+
+func (s *lockedStore) LastLogins() (logins []LastLogin, err error) {
+	err = s.read(func(d *memoryData) error {
+		logins, err = d.LastLogins()
+		return err
+	})
+	return logins, err
+}
+
+func (s *lockedStore) Permissions() (rules []Permission, err error) {
+	err = s.read(func(d *memoryData) error {
+		rules, err = d.Permissions()
+		return err
+	})
+	return rules, err
+}
+
+func (s *lockedStore) SavePermission(rule Permission) error {
+	return s.write(func(d *memoryData) error { return d.SavePermission(rule) })
+}
+
+func (s *lockedStore) DeletePermission(role, command string) error {
+	return s.write(func(d *memoryData) error { return d.DeletePermission(role, command) })
+}
+
+func (s *lockedStore) Settings(userID int64) (settings []Setting, err error) {
+	err = s.read(func(d *memoryData) error {
+		settings, err = d.Settings(userID)
+		return err
+	})
+	return settings, err
+}
+
+func (s *lockedStore) ReplaceSettings(userID int64, settings []Setting) error {
+	return s.write(func(d *memoryData) error { return d.ReplaceSettings(userID, settings) })
+}
+
+// MemoryStore keeps the accounts in memory, for tests and sessions that
+// need no persistence.
+type MemoryStore struct {
+	lockedStore
+}
+
+func NewMemoryStore() *MemoryStore {
+	s := &MemoryStore{}
+	data := &memoryData{}
+	// Operations work on a copy so that a failed transaction leaves the
+	// data untouched.
+	s.load = func() (*memoryData, error) { return data.copy(), nil }
+	s.save = func(changed *memoryData) error {
+		data = changed
+		return nil
+	}
+	return s
+}
@@ -0,0 +1,78 @@
+package user
+
+import (
+	"asa/shell/internal/totp"
+	"errors"
+	"fmt"
+)
+
+var (
+	ErrCodeRequired = errors.New("verification code required")
+	ErrWrongCode    = errors.New("wrong verification code")
+	ErrMFAEnabled   = errors.New("two-factor authentication is already enabled")
+	ErrMFADisabled  = errors.New("two-factor authentication is not enabled")
+)
+
+// MFAEnabled reports whether user logs in with a one-time password.
+func MFAEnabled(user *User) bool {
+	return user.TOTPSecret != ""
+}
+
+// checkCode verifies a one-time password of user, counting a wrong one
+// like a wrong password and refusing a code that was already used.
+func checkCode(store UserStore, user *User, code string) error {
+	if code == "" {
+		return ErrCodeRequired
+	}
+	if err := checkLocked(user); err != nil {
+		return err
+	}
+	step, ok := totp.Validate(user.TOTPSecret, code, now())
+	if !ok || step <= user.TOTPStep {
+		if err := recordFailure(store, user); err != nil {
+			return err
+		}
+		return ErrWrongCode
+	}
+	user.TOTPStep = step
+	if err := store.UpdateUser(user, "TOTPStep"); err != nil {
+		return fmt.Errorf("failed to record verification code: %w", err)
+	}
+	return resetFailures(store, user)
+}
+
+// EnableMFA turns on the second factor of user with secret once code
+// proves the authenticator was set up with it.
+func EnableMFA(store UserStore, user *User, secret, code string) error {
+	if MFAEnabled(user) {
+		return ErrMFAEnabled
+	}
+	step, ok := totp.Validate(secret, code, now())
+	if !ok {
+		return ErrWrongCode
+	}
+	enabled := *user
+	enabled.TOTPSecret, enabled.TOTPStep = secret, step
+	if err := store.UpdateUser(&enabled, "TOTPSecret", "TOTPStep"); err != nil {
+		return fmt.Errorf("failed to enable two-factor authentication: %w", err)
+	}
+	user.TOTPSecret, user.TOTPStep = secret, step
+	return nil
+}
+
+// DisableMFA turns off the second factor of user given a current code.
+func DisableMFA(store UserStore, user *User, code string) error {
+	if !MFAEnabled(user) {
+		return ErrMFADisabled
+	}
+	if err := checkCode(store, user, code); err != nil {
+		return err
+	}
+	disabled := *user
+	disabled.TOTPSecret, disabled.TOTPStep = "", 0
+	if err := store.UpdateUser(&disabled, "TOTPSecret", "TOTPStep"); err != nil {
+		return fmt.Errorf("failed to disable two-factor authentication: %w", err)
+	}
+	user.TOTPSecret, user.TOTPStep = "", 0
+	return nil
+}
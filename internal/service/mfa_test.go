@@ -0,0 +1,102 @@
+package user
+
+import (
+	"asa/shell/internal/totp"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestAuthenticate_MFA(t *testing.T) {
+	store := NewMemoryStore()
+	setCost(t, "4")
+	clock := time.Unix(1700000000, 0)
+	now = func() time.Time { return clock }
+	t.Cleanup(func() { now = time.Now })
+
+	u := &User{Username: "mfa", Password: "s3cret"}
+	if err := RegisterUser(store, u); err != nil {
+		t.Fatalf("RegisterUser() unexpected error: %v", err)
+	}
+	secret, _ := totp.GenerateSecret()
+	code := func() string {
+		c, _ := totp.Code(secret, clock)
+		return c
+	}
+
+	if err := EnableMFA(store, u, secret, "000000"); !errors.Is(err, ErrWrongCode) {
+		t.Fatalf("EnableMFA() with a wrong code error = %v, want %v", err, ErrWrongCode)
+	}
+	if err := EnableMFA(store, u, secret, code()); err != nil {
+		t.Fatalf("EnableMFA() unexpected error: %v", err)
+	}
+	if err := EnableMFA(store, u, secret, code()); !errors.Is(err, ErrMFAEnabled) {
+		t.Errorf("EnableMFA() twice error = %v, want %v", err, ErrMFAEnabled)
+	}
+
+	clock = clock.Add(totp.Period)
+	if _, err := Authenticate(store, "mfa", "s3cret", "", "s1"); !errors.Is(err, ErrCodeRequired) {
+		t.Fatalf("Authenticate() without a code error = %v, want %v", err, ErrCodeRequired)
+	}
+	if _, err := Authenticate(store, "mfa", "s3cret", "000000", "s1"); !errors.Is(err, ErrWrongCode) {
+		t.Fatalf("Authenticate() with a wrong code error = %v, want %v", err, ErrWrongCode)
+	}
+	valid := code()
+	if _, err := Authenticate(store, "mfa", "s3cret", valid, "s1"); err != nil {
+		t.Fatalf("Authenticate() unexpected error: %v", err)
+	}
+	if _, err := Authenticate(store, "mfa", "s3cret", valid, "s2"); !errors.Is(err, ErrWrongCode) {
+		t.Errorf("Authenticate() replaying a code error = %v, want %v", err, ErrWrongCode)
+	}
+
+	stored, _ := FindUser(store, "mfa")
+	clock = clock.Add(totp.Period)
+	if err := DisableMFA(store, &stored, code()); err != nil {
+		t.Fatalf("DisableMFA() unexpected error: %v", err)
+	}
+	if _, err := Authenticate(store, "mfa", "s3cret", "", "s3"); err != nil {
+		t.Errorf("Authenticate() after DisableMFA() unexpected error: %v", err)
+	}
+}
+
+func TestAuthenticate_MFALockout(t *testing.T) {
+	store := NewMemoryStore()
+	setCost(t, "4")
+	clock := time.Unix(1700000000, 0)
+	now = func() time.Time { return clock }
+	t.Cleanup(func() { now = time.Now })
+
+	u := &User{Username: "guessed", Password: "s3cret"}
+	if err := RegisterUser(store, u); err != nil {
+		t.Fatalf("RegisterUser() unexpected error: %v", err)
+	}
+	secret, _ := totp.GenerateSecret()
+	code, _ := totp.Code(secret, clock)
+	if err := EnableMFA(store, u, secret, code); err != nil {
+		t.Fatalf("EnableMFA() unexpected error: %v", err)
+	}
+
+	// Knowing the password must not reset the failed codes.
+	for i := 0; i < freeAttempts; i++ {
+		if _, err := Authenticate(store, "guessed", "s3cret", "000000", "s1"); !errors.Is(err, ErrWrongCode) {
+			t.Fatalf("Authenticate() attempt %d error = %v, want %v", i+1, err, ErrWrongCode)
+		}
+	}
+	if _, err := Authenticate(store, "guessed", "s3cret", "000000", "s1"); !errors.Is(err, ErrAccountLocked) {
+		t.Fatalf("Authenticate() after %d wrong codes error = %v, want %v", freeAttempts, err, ErrAccountLocked)
+	}
+	stored, _ := FindUser(store, "guessed")
+	if stored.FailedLogins != freeAttempts || !stored.LockedUntil.After(clock) {
+		t.Errorf("FindUser() = %d failures until %s, want the account locked", stored.FailedLogins, stored.LockedUntil)
+	}
+
+	clock = clock.Add(lockoutBase + totp.Period)
+	code, _ = totp.Code(secret, clock)
+	if _, err := Authenticate(store, "guessed", "s3cret", code, "s2"); err != nil {
+		t.Fatalf("Authenticate() after the lockout unexpected error: %v", err)
+	}
+	stored, _ = FindUser(store, "guessed")
+	if stored.FailedLogins != 0 || !stored.LockedUntil.IsZero() {
+		t.Errorf("FindUser() = %d failures until %s, want the counter reset", stored.FailedLogins, stored.LockedUntil)
+	}
+}
@@ -1,9 +1,89 @@
 package user
 
+import "time"
+
 type User struct {
+	ID       int64  `gorm:"primaryKey"`
+	Username string `gorm:"column:user_name;unique"`
+	Password string
+	Role     string `gorm:"default:user"`
+	HomeDir  string
+	// HistoryMap counts the command lines of the user, read from its
+	// HistoryCount rows.
+	HistoryMap map[string]int `gorm:"-" json:"-"`
+
+	// FailedLogins counts the wrong passwords given since the last
+	// successful login; past a threshold the account is locked until
+	// LockedUntil.
+	FailedLogins int
+	LockedUntil  time.Time
+
+	// TOTPSecret enables the one-time password second factor when set;
+	// TOTPStep is the step of the last code accepted, which cannot be
+	// used again.
+	TOTPSecret string
+	TOTPStep   int64
+
+	// synced holds the counts of HistoryMap already merged into the stored
+	// history, so that only this session's increments are merged next time.
+	synced map[string]int
+}
+
+// HistoryEntry is one execution of a command line. Anonymous sessions keep
+// their entries in memory with UserID 0.
+type HistoryEntry struct {
 	ID         int64  `gorm:"primaryKey"`
-	Username   string `gorm:"column:user_name;unique"`
-	Password   string
-	History    string         `gorm:"type:text"`
-	HistoryMap map[string]int `gorm:"-"`
+	UserID     int64  `gorm:"index"`
+	Command    string `gorm:"type:text"`
+	StartedAt  time.Time
+	Duration   time.Duration
+	Cwd        string
+	ExitStatus int
+	SessionID  string `gorm:"index"`
+}
+
+// HistoryCount is how many times a user ran a command line.
+type HistoryCount struct {
+	UserID int64  `gorm:"primaryKey;autoIncrement:false"`
+	Line   string `gorm:"primaryKey"`
+	Count  int
+}
+
+// Permission overrides whether members of Role may run Command, a builtin
+// or program name, or External for every program that is not a builtin.
+type Permission struct {
+	ID      int64  `gorm:"primaryKey"`
+	Role    string `gorm:"uniqueIndex:idx_permission_role_command"`
+	Command string `gorm:"uniqueIndex:idx_permission_role_command"`
+	Allowed bool
+}
+
+// LoginAudit records one login attempt. UserID is 0 when the username did
+// not match any account.
+type LoginAudit struct {
+	ID        int64  `gorm:"primaryKey"`
+	Username  string `gorm:"index"`
+	UserID    int64
+	Success   bool
+	Reason    string
+	SessionID string
+	At        time.Time `gorm:"index"`
+}
+
+func (LoginAudit) TableName() string {
+	return "login_audit"
+}
+
+// Setting is one persisted preference of a user: an exported variable, an
+// alias or a shell option depending on Kind.
+type Setting struct {
+	ID     int64  `gorm:"primaryKey"`
+	UserID int64  `gorm:"uniqueIndex:idx_setting_user_kind_name"`
+	Kind   string `gorm:"uniqueIndex:idx_setting_user_kind_name"`
+	Name   string `gorm:"uniqueIndex:idx_setting_user_kind_name"`
+	Value  string `gorm:"type:text"`
+}
+
+func (Setting) TableName() string {
+	return "user_settings"
 }
@@ -0,0 +1,80 @@
+package user
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"os"
+	"strconv"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// costEnv sets the bcrypt cost of newly hashed passwords. Raising it makes
+// existing hashes get rehashed on their next successful login.
+const costEnv = "SHELL_BCRYPT_COST"
+
+func passwordCost() int {
+	cost, err := strconv.Atoi(os.Getenv(costEnv))
+	if err != nil || cost < bcrypt.MinCost || cost > bcrypt.MaxCost {
+		return bcrypt.DefaultCost
+	}
+	return cost
+}
+
+// HashPassword hashes password with bcrypt at the configured cost.
+func HashPassword(password string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), passwordCost())
+	if err != nil {
+		return "", fmt.Errorf("failed to hash password: %w", err)
+	}
+	return string(hash), nil
+}
+
+// CheckPassword reports whether password matches the stored one, which is
+// a bcrypt hash or, for rows saved before hashing, the plaintext itself.
+// Both comparisons take constant time.
+func CheckPassword(stored, password string) bool {
+	if isHash(stored) {
+		return bcrypt.CompareHashAndPassword([]byte(stored), []byte(password)) == nil
+	}
+	return subtle.ConstantTimeCompare([]byte(stored), []byte(password)) == 1
+}
+
+func isHash(stored string) bool {
+	_, err := bcrypt.Cost([]byte(stored))
+	return err == nil
+}
+
+func needsRehash(stored string) bool {
+	cost, err := bcrypt.Cost([]byte(stored))
+	return err != nil || cost != passwordCost()
+}
+
+// hashIfPlain hashes the password of user unless it already is a hash.
+func hashIfPlain(user *User) error {
+	if user.Password == "" || isHash(user.Password) {
+		return nil
+	}
+	hash, err := HashPassword(user.Password)
+	if err != nil {
+		return err
+	}
+	user.Password = hash
+	return nil
+}
+
+// rehash replaces the stored password of user with a fresh hash of the
+// password it was just verified against.
+func rehash(store UserStore, user *User, password string) error {
+	hash, err := HashPassword(password)
+	if err != nil {
+		return err
+	}
+	rehashed := *user
+	rehashed.Password = hash
+	if err := store.UpdateUser(&rehashed, "Password"); err != nil {
+		return err
+	}
+	user.Password = hash
+	return nil
+}
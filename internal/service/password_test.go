@@ -0,0 +1,103 @@
+package user
+
+import (
+	"errors"
+	"os"
+	"testing"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+func setCost(t *testing.T, cost string) {
+	t.Helper()
+	original, wasSet := os.LookupEnv(costEnv)
+	t.Cleanup(func() {
+		if wasSet {
+			os.Setenv(costEnv, original)
+		} else {
+			os.Unsetenv(costEnv)
+		}
+	})
+	os.Setenv(costEnv, cost)
+}
+
+func TestCheckPassword(t *testing.T) {
+	setCost(t, "4")
+	hash, err := HashPassword("secret")
+	if err != nil {
+		t.Fatalf("HashPassword() unexpected error: %v", err)
+	}
+	if hash == "secret" || !isHash(hash) {
+		t.Fatalf("HashPassword() = %q, want a bcrypt hash", hash)
+	}
+
+	tests := []struct {
+		name     string
+		stored   string
+		password string
+		want     bool
+	}{
+		{name: "hash matches", stored: hash, password: "secret", want: true},
+		{name: "hash mismatch", stored: hash, password: "Secret", want: false},
+		{name: "legacy plaintext matches", stored: "secret", password: "secret", want: true},
+		{name: "legacy plaintext mismatch", stored: "secret", password: "secre", want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := CheckPassword(tt.stored, tt.password); got != tt.want {
+				t.Errorf("CheckPassword() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGetUser_Rehash(t *testing.T) {
+	db := setupHistoryDB(t)
+	store := NewGormStore(db)
+	setCost(t, "4")
+
+	// Rows written before hashing hold the plaintext.
+	legacy := User{Username: "legacy_rehash", Password: "plain"}
+	if err := db.Create(&legacy).Error; err != nil {
+		t.Fatalf("failed to create legacy user: %v", err)
+	}
+
+	if _, err := GetUser(store, "legacy_rehash", "wrong"); !errors.Is(err, ErrWrongPassword) {
+		t.Fatalf("GetUser() with a wrong password error = %v, want %v", err, ErrWrongPassword)
+	}
+	got, err := GetUser(store, "legacy_rehash", "plain")
+	if err != nil {
+		t.Fatalf("GetUser() unexpected error: %v", err)
+	}
+	var stored User
+	db.First(&stored, legacy.ID)
+	if stored.Password == "plain" || !CheckPassword(stored.Password, "plain") || got.Password != stored.Password {
+		t.Fatalf("GetUser() stored password = %q, want a hash of the plaintext", stored.Password)
+	}
+
+	setCost(t, "5")
+	if _, err := GetUser(store, "legacy_rehash", "plain"); err != nil {
+		t.Fatalf("GetUser() unexpected error: %v", err)
+	}
+	db.First(&stored, legacy.ID)
+	if cost, _ := bcrypt.Cost([]byte(stored.Password)); cost != 5 {
+		t.Errorf("GetUser() stored cost = %d, want the hash upgraded to 5", cost)
+	}
+}
+
+func TestRegisterUser_HashesPassword(t *testing.T) {
+	db := setupHistoryDB(t)
+	store := NewGormStore(db)
+	setCost(t, "4")
+
+	u := &User{Username: "hashed_register", Password: "secret"}
+	if err := RegisterUser(store, u); err != nil {
+		t.Fatalf("RegisterUser() unexpected error: %v", err)
+	}
+	var stored User
+	db.Where("user_name = ?", "hashed_register").First(&stored)
+	if !isHash(stored.Password) || !CheckPassword(stored.Password, "secret") {
+		t.Errorf("RegisterUser() stored password = %q, want a hash of it", stored.Password)
+	}
+}
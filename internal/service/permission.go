@@ -0,0 +1,205 @@
+package user
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+const (
+	RoleAdmin = "admin"
+	RoleUser  = "user"
+	RoleGuest = "guest"
+
+	// External stands for every program run from PATH in permission rules.
+	External = "@external"
+
+	// AdminName is the account created when no admin exists yet.
+	AdminName = "admin"
+	// adminPasswordEnv sets the password of the bootstrap admin; a random
+	// one is generated when it is unset.
+	adminPasswordEnv = "SHELL_ADMIN_PASSWORD"
+)
+
+var (
+	ErrPermissionDenied = errors.New("permission denied")
+	ErrUnknownRole      = errors.New("unknown role")
+	ErrLastAdmin        = errors.New("cannot remove the last admin")
+)
+
+// defaultDenied lists what each role may not run unless a Permission row
+// says otherwise. Admins are never restricted.
+var defaultDenied = map[string]map[string]bool{
+	RoleGuest: {"adduser": true},
+}
+
+// ValidRole reports whether role is one of the known roles.
+func ValidRole(role string) bool {
+	return role == RoleAdmin || role == RoleUser || role == RoleGuest
+}
+
+// RoleOf returns the role of user; anonymous sessions are guests.
+func RoleOf(user *User) string {
+	switch {
+	case user == nil || user.Username == "":
+		return RoleGuest
+	case user.Role == "":
+		return RoleUser
+	default:
+		return user.Role
+	}
+}
+
+// IsAdmin reports whether user has the admin role.
+func IsAdmin(user *User) bool {
+	return RoleOf(user) == RoleAdmin
+}
+
+// Allowed reports whether role may run command. builtin tells whether
+// command is a builtin; programs are matched by the base name of their
+// path, so that running one by path does not bypass its rule, and fall
+// back on the External rule.
+func Allowed(store UserStore, role, command string, builtin bool) (bool, error) {
+	if role == RoleAdmin {
+		return true, nil
+	}
+	names := []string{command}
+	if !builtin {
+		names = []string{filepath.Base(command), External}
+	}
+	rules, err := store.Permissions()
+	if err != nil {
+		return false, err
+	}
+	for _, name := range names {
+		for _, rule := range rules {
+			if rule.Role == role && rule.Command == name {
+				return rule.Allowed, nil
+			}
+		}
+	}
+	for _, name := range names {
+		if defaultDenied[role][name] {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// SetPermission records whether role may run command.
+func SetPermission(store UserStore, role, command string, allowed bool) error {
+	if !ValidRole(role) || role == RoleAdmin {
+		return ErrUnknownRole
+	}
+	return store.SavePermission(Permission{Role: role, Command: command, Allowed: allowed})
+}
+
+// ResetPermission drops the rule of role for command, restoring the default.
+func ResetPermission(store UserStore, role, command string) error {
+	return store.DeletePermission(role, command)
+}
+
+// ListPermissions returns the stored rules followed by the defaults they do
+// not override, ordered by role and command.
+func ListPermissions(store UserStore) ([]Permission, error) {
+	rules, err := store.Permissions()
+	if err != nil {
+		return nil, err
+	}
+	stored := map[[2]string]bool{}
+	for _, rule := range rules {
+		stored[[2]string{rule.Role, rule.Command}] = true
+	}
+	for role, commands := range defaultDenied {
+		for command := range commands {
+			if !stored[[2]string{role, command}] {
+				rules = append(rules, Permission{Role: role, Command: command})
+			}
+		}
+	}
+	sort.Slice(rules, func(i, j int) bool {
+		if rules[i].Role != rules[j].Role {
+			return rules[i].Role < rules[j].Role
+		}
+		return rules[i].Command < rules[j].Command
+	})
+	return rules, nil
+}
+
+// SetRole changes the role of the account with the given username. The
+// last admin cannot be demoted.
+func SetRole(store UserStore, username, role string) error {
+	if !ValidRole(role) {
+		return ErrUnknownRole
+	}
+	return store.Transaction(func(tx UserStore) error {
+		account, err := tx.FindUser(username)
+		if err != nil {
+			return err
+		}
+		if role != RoleAdmin {
+			if err := keepAdmin(tx, &account); err != nil {
+				return err
+			}
+		}
+		account.Role = role
+		return tx.UpdateUser(&account, "Role")
+	})
+}
+
+// keepAdmin returns ErrLastAdmin when user is the only admin left.
+func keepAdmin(store UserStore, user *User) error {
+	if user.Role != RoleAdmin {
+		return nil
+	}
+	admins, err := store.CountRole(RoleAdmin)
+	if err != nil {
+		return err
+	}
+	if admins <= 1 {
+		return ErrLastAdmin
+	}
+	return nil
+}
+
+// EnsureAdmin creates the admin account when no user has the admin role.
+// Its password comes from SHELL_ADMIN_PASSWORD or is generated; a
+// generated password is returned so it can be shown once.
+func EnsureAdmin(store UserStore) (generated string, err error) {
+	count, err := store.CountRole(RoleAdmin)
+	if err != nil {
+		return "", err
+	}
+	if count > 0 {
+		return "", nil
+	}
+
+	password := os.Getenv(adminPasswordEnv)
+	if password == "" {
+		b := make([]byte, 12)
+		if _, err := rand.Read(b); err != nil {
+			return "", fmt.Errorf("failed to generate admin password: %w", err)
+		}
+		password = base64.RawURLEncoding.EncodeToString(b)
+		generated = password
+	}
+
+	admin, err := store.FindUser(AdminName)
+	if errors.Is(err, ErrUserNotFound) {
+		admin = User{Username: AdminName, Password: password, Role: RoleAdmin}
+		return generated, RegisterUser(store, &admin)
+	}
+	if err != nil {
+		return "", err
+	}
+	// An existing account named admin may have been registered by anyone,
+	// so it is promoted only along with a new password.
+	if err := SetPassword(store, &admin, password); err != nil {
+		return "", err
+	}
+	return generated, SetRole(store, AdminName, RoleAdmin)
+}
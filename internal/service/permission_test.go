@@ -0,0 +1,116 @@
+package user
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestAllowed(t *testing.T) {
+	store := NewMemoryStore()
+
+	for _, rule := range []Permission{
+		{Role: RoleUser, Command: External, Allowed: false},
+		{Role: RoleUser, Command: "git", Allowed: true},
+		{Role: RoleGuest, Command: "cat", Allowed: false},
+		{Role: RoleGuest, Command: "curl", Allowed: false},
+	} {
+		if err := SetPermission(store, rule.Role, rule.Command, rule.Allowed); err != nil {
+			t.Fatalf("SetPermission() unexpected error: %v", err)
+		}
+	}
+	// Saving a rule again replaces it.
+	if err := SetPermission(store, RoleGuest, "cat", true); err != nil {
+		t.Fatalf("SetPermission() unexpected error: %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		role    string
+		command string
+		builtin bool
+		want    bool
+	}{
+		{name: "admin is never restricted", role: RoleAdmin, command: "make", want: true},
+		{name: "program denied through external", role: RoleUser, command: "make", want: false},
+		{name: "program rule beats external", role: RoleUser, command: "git", want: true},
+		{name: "builtins ignore external", role: RoleUser, command: "ls", builtin: true, want: true},
+		{name: "default denial", role: RoleGuest, command: "adduser", builtin: true, want: false},
+		{name: "replaced rule", role: RoleGuest, command: "cat", builtin: true, want: true},
+		{name: "unrestricted program", role: RoleGuest, command: "make", want: true},
+		{name: "program denied by full path", role: RoleGuest, command: "/usr/bin/curl", want: false},
+		{name: "program denied by relative path", role: RoleGuest, command: "./curl", want: false},
+		{name: "program rule by full path", role: RoleUser, command: "/usr/bin/git", want: true},
+		{name: "builtins match literally", role: RoleGuest, command: "bin/adduser", builtin: true, want: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Allowed(store, tt.role, tt.command, tt.builtin)
+			if err != nil {
+				t.Fatalf("Allowed() unexpected error: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("Allowed(%q, %q) = %v, want %v", tt.role, tt.command, got, tt.want)
+			}
+		})
+	}
+
+	if err := ResetPermission(store, RoleUser, External); err != nil {
+		t.Fatalf("ResetPermission() unexpected error: %v", err)
+	}
+	if got, _ := Allowed(store, RoleUser, "make", false); !got {
+		t.Errorf("Allowed() after ResetPermission() = false, want the default")
+	}
+	if err := SetPermission(store, RoleAdmin, "ls", false); !errors.Is(err, ErrUnknownRole) {
+		t.Errorf("SetPermission() for admins error = %v, want %v", err, ErrUnknownRole)
+	}
+}
+
+func TestEnsureAdmin(t *testing.T) {
+	store := NewMemoryStore()
+	setCost(t, "4")
+	t.Setenv(adminPasswordEnv, "")
+
+	// A squatted admin account must not keep its password.
+	if err := RegisterUser(store, &User{Username: AdminName, Password: "squatter"}); err != nil {
+		t.Fatalf("RegisterUser() unexpected error: %v", err)
+	}
+
+	generated, err := EnsureAdmin(store)
+	if err != nil || generated == "" {
+		t.Fatalf("EnsureAdmin() = %q, %v, want a generated password", generated, err)
+	}
+	admin, err := GetUser(store, AdminName, generated)
+	if err != nil || !IsAdmin(&admin) {
+		t.Fatalf("GetUser() = %+v, %v, want the admin with the generated password", admin, err)
+	}
+
+	if again, err := EnsureAdmin(store); err != nil || again != "" {
+		t.Errorf("EnsureAdmin() with an admin = %q, %v, want nothing done", again, err)
+	}
+	if err := SetRole(store, AdminName, RoleUser); !errors.Is(err, ErrLastAdmin) {
+		t.Errorf("SetRole() of the last admin error = %v, want %v", err, ErrLastAdmin)
+	}
+	if err := DeleteUser(store, AdminName); !errors.Is(err, ErrLastAdmin) {
+		t.Errorf("DeleteUser() of the last admin error = %v, want %v", err, ErrLastAdmin)
+	}
+	if err := SetRole(store, "nobody", RoleGuest); !errors.Is(err, ErrUserNotFound) {
+		t.Errorf("SetRole() of a missing user error = %v, want %v", err, ErrUserNotFound)
+	}
+}
+
+func TestRoleOf(t *testing.T) {
+	tests := []struct {
+		user *User
+		want string
+	}{
+		{user: &User{}, want: RoleGuest},
+		{user: &User{Username: "alice"}, want: RoleUser},
+		{user: &User{Username: "root", Role: RoleAdmin}, want: RoleAdmin},
+	}
+	for _, tt := range tests {
+		if got := RoleOf(tt.user); got != tt.want {
+			t.Errorf("RoleOf(%+v) = %q, want %q", tt.user, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,21 @@
+package user
+
+// LoadSettings returns the stored settings of user.
+func LoadSettings(store UserStore, user *User) ([]Setting, error) {
+	if user == nil {
+		return nil, ErrUserShouldntNill
+	}
+	return store.Settings(user.ID)
+}
+
+// SaveSettings replaces the stored settings of user with settings.
+func SaveSettings(store UserStore, user *User, settings []Setting) error {
+	if user == nil {
+		return ErrUserShouldntNill
+	}
+	rows := make([]Setting, len(settings))
+	for i, setting := range settings {
+		rows[i] = Setting{UserID: user.ID, Kind: setting.Kind, Name: setting.Name, Value: setting.Value}
+	}
+	return store.ReplaceSettings(user.ID, rows)
+}
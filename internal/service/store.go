@@ -0,0 +1,60 @@
+package user
+
+// UserStore persists the accounts of the shell and what is recorded about
+// them. FindUser returns ErrUserNotFound for unknown usernames.
+type UserStore interface {
+	// Transaction runs fn against a store whose changes are kept only if
+	// fn succeeds.
+	Transaction(fn func(store UserStore) error) error
+
+	// CreateUser inserts user and sets its ID.
+	CreateUser(user *User) error
+	// FindUser returns the account named username. Within a transaction
+	// the account stays locked until the transaction ends.
+	FindUser(username string) (User, error)
+	// UpdateUser saves the given fields of user, by their Go names.
+	UpdateUser(user *User, fields ...string) error
+	// DeleteUser removes user with its history and settings.
+	DeleteUser(user *User) error
+	// ListUsers returns every account ordered by username.
+	ListUsers() ([]User, error)
+	CountRole(role string) (int64, error)
+
+	// AddHistory adds delta to the history counts stored for the user and
+	// drops the counts that are not positive anymore.
+	AddHistory(userID int64, delta map[string]int) error
+	HistoryCounts(userID int64) (map[string]int, error)
+	// TopHistory returns the limit lines the user ran most, most run
+	// first, or all of them when limit is not positive.
+	TopHistory(userID int64, limit int) ([]HistoryCount, error)
+	// HistoryWithPrefix is TopHistory restricted to the lines starting
+	// with prefix.
+	HistoryWithPrefix(userID int64, prefix string, limit int) ([]HistoryCount, error)
+	ClearHistory(userID int64) error
+	AddHistoryEntries(entries []HistoryEntry) error
+	// HistoryEntries returns the entries of the user, oldest first.
+	HistoryEntries(userID int64) ([]HistoryEntry, error)
+	// RecentHistoryEntries returns the last limit entries of the user with
+	// an ID above afterID, oldest first, or all of them when limit is not
+	// positive.
+	RecentHistoryEntries(userID, afterID int64, limit int) ([]HistoryEntry, error)
+	ClearHistoryEntries(userID int64) error
+
+	AddLoginAudit(audit *LoginAudit) error
+	// LoginAudits returns the last limit attempts on username, newest
+	// first, or all of them when limit is not positive.
+	LoginAudits(username string, limit int) ([]LoginAudit, error)
+	// LastLogins returns the latest successful login of every account and
+	// the failed attempts since, ordered by username.
+	LastLogins() ([]LastLogin, error)
+
+	Permissions() ([]Permission, error)
+	// SavePermission inserts rule or replaces the rule of its role and
+	// command.
+	SavePermission(rule Permission) error
+	DeletePermission(role, command string) error
+
+	// Settings returns the settings of the user ordered by kind and name.
+	Settings(userID int64) ([]Setting, error)
+	ReplaceSettings(userID int64, settings []Setting) error
+}
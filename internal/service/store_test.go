@@ -0,0 +1,332 @@
+package user
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func TestUserStores(t *testing.T) {
+	stores := map[string]func(t *testing.T) UserStore{
+		"memory": func(t *testing.T) UserStore { return NewMemoryStore() },
+		"file": func(t *testing.T) UserStore {
+			return NewFileStore(filepath.Join(t.TempDir(), "accounts.json"))
+		},
+		"gorm": func(t *testing.T) UserStore { return NewGormStore(setupHistoryDB(t)) },
+	}
+	for name, open := range stores {
+		t.Run(name, func(t *testing.T) {
+			testUserStore(t, open(t))
+		})
+	}
+}
+
+func testUserStore(t *testing.T, store UserStore) {
+	alice := &User{Username: "alice", Password: "hash"}
+	if err := store.CreateUser(alice); err != nil || alice.ID == 0 {
+		t.Fatalf("CreateUser() = %v with ID %d, want an ID", err, alice.ID)
+	}
+	if err := store.CreateUser(&User{Username: "bob", Role: RoleAdmin}); err != nil {
+		t.Fatalf("CreateUser() unexpected error: %v", err)
+	}
+	if _, err := store.FindUser("nobody"); !errors.Is(err, ErrUserNotFound) {
+		t.Errorf("FindUser() of an unknown user error = %v, want %v", err, ErrUserNotFound)
+	}
+
+	alice.Password, alice.Role, alice.HomeDir = "changed", RoleAdmin, "/home/alice"
+	if err := store.UpdateUser(alice, "Password", "HomeDir"); err != nil {
+		t.Fatalf("UpdateUser() unexpected error: %v", err)
+	}
+	got, err := store.FindUser("alice")
+	if err != nil || got.Password != "changed" || got.HomeDir != "/home/alice" || got.Role != RoleUser {
+		t.Errorf("FindUser() after UpdateUser() = %+v, %v, want only the given fields changed", got, err)
+	}
+	if admins, err := store.CountRole(RoleAdmin); err != nil || admins != 1 {
+		t.Errorf("CountRole() = %d, %v, want 1", admins, err)
+	}
+	users, err := store.ListUsers()
+	if err != nil || len(users) != 2 || users[0].Username != "alice" || users[1].Username != "bob" {
+		t.Errorf("ListUsers() = %+v, %v, want alice and bob", users, err)
+	}
+
+	if err := store.AddHistory(alice.ID, map[string]int{"ls": 2, "pwd": 1, "git log": 1, "git status": 4}); err != nil {
+		t.Fatalf("AddHistory() unexpected error: %v", err)
+	}
+	if err := store.AddHistory(alice.ID, map[string]int{"ls": 1, "pwd": -1, "git log": 1}); err != nil {
+		t.Fatalf("AddHistory() unexpected error: %v", err)
+	}
+	counts, err := store.HistoryCounts(alice.ID)
+	if want := map[string]int{"ls": 3, "git log": 2, "git status": 4}; err != nil || !reflect.DeepEqual(counts, want) {
+		t.Errorf("HistoryCounts() = %v, %v, want %v", counts, err, want)
+	}
+	top, err := store.TopHistory(alice.ID, 2)
+	if want := []HistoryCount{{alice.ID, "git status", 4}, {alice.ID, "ls", 3}}; err != nil || !reflect.DeepEqual(top, want) {
+		t.Errorf("TopHistory() = %v, %v, want %v", top, err, want)
+	}
+	prefixed, err := store.HistoryWithPrefix(alice.ID, "git ", 0)
+	if want := []HistoryCount{{alice.ID, "git status", 4}, {alice.ID, "git log", 2}}; err != nil || !reflect.DeepEqual(prefixed, want) {
+		t.Errorf("HistoryWithPrefix() = %v, %v, want %v", prefixed, err, want)
+	}
+	if prefixed, _ := store.HistoryWithPrefix(alice.ID, "Git", 0); len(prefixed) != 0 {
+		t.Errorf("HistoryWithPrefix() = %v, want a case sensitive match", prefixed)
+	}
+	if prefixed, _ := store.HistoryWithPrefix(alice.ID, "l_", 0); len(prefixed) != 0 {
+		t.Errorf("HistoryWithPrefix() = %v, want no wildcard", prefixed)
+	}
+
+	// A failed transaction leaves nothing behind.
+	failed := errors.New("failed")
+	err = store.Transaction(func(tx UserStore) error {
+		if err := tx.CreateUser(&User{Username: "carol"}); err != nil {
+			return err
+		}
+		return failed
+	})
+	if !errors.Is(err, failed) {
+		t.Fatalf("Transaction() error = %v, want %v", err, failed)
+	}
+	if _, err := store.FindUser("carol"); !errors.Is(err, ErrUserNotFound) {
+		t.Errorf("FindUser() after a failed transaction error = %v, want %v", err, ErrUserNotFound)
+	}
+
+	start := time.Unix(1700000000, 0)
+	entries := []HistoryEntry{
+		{UserID: alice.ID, Command: "make", StartedAt: start.Add(time.Second)},
+		{UserID: alice.ID, Command: "ls", StartedAt: start},
+	}
+	if err := store.AddHistoryEntries(entries); err != nil || entries[0].ID == 0 {
+		t.Fatalf("AddHistoryEntries() = %v, want IDs set", err)
+	}
+	stored, err := store.HistoryEntries(alice.ID)
+	if err != nil || len(stored) != 2 || stored[0].Command != "ls" {
+		t.Errorf("HistoryEntries() = %+v, %v, want oldest first", stored, err)
+	}
+	recent, err := store.RecentHistoryEntries(alice.ID, 0, 1)
+	if err != nil || len(recent) != 1 || recent[0].Command != "make" {
+		t.Errorf("RecentHistoryEntries() = %+v, %v, want the latest entry", recent, err)
+	}
+	if recent, _ := store.RecentHistoryEntries(alice.ID, entries[0].ID, 0); len(recent) != 1 || recent[0].Command != "ls" {
+		t.Errorf("RecentHistoryEntries() after an ID = %+v, want the entries recorded since", recent)
+	}
+
+	for i, success := range []bool{true, false} {
+		audit := &LoginAudit{Username: "alice", UserID: alice.ID, Success: success, At: start.Add(time.Duration(i) * time.Minute)}
+		if err := store.AddLoginAudit(audit); err != nil {
+			t.Fatalf("AddLoginAudit() unexpected error: %v", err)
+		}
+	}
+	audits, err := store.LoginAudits("alice", 1)
+	if err != nil || len(audits) != 1 || audits[0].Success {
+		t.Errorf("LoginAudits() = %+v, %v, want the failure last", audits, err)
+	}
+	if audits, _ := store.LoginAudits("alice", 0); len(audits) != 2 {
+		t.Errorf("LoginAudits() without limit returned %d audits, want 2", len(audits))
+	}
+	logins, err := store.LastLogins()
+	if err != nil || len(logins) != 2 || logins[0].Last.UserID != alice.ID || !logins[0].Last.At.Equal(start) || logins[0].Failures != 1 {
+		t.Fatalf("LastLogins() = %+v, %v, want the success of alice and the failure since", logins, err)
+	}
+	if logins[1].Username != "bob" || logins[1].Last.ID != 0 || logins[1].Failures != 0 {
+		t.Errorf("LastLogins() = %+v, want bob without logins", logins)
+	}
+
+	if err := store.SavePermission(Permission{Role: RoleUser, Command: "git"}); err != nil {
+		t.Fatalf("SavePermission() unexpected error: %v", err)
+	}
+	if err := store.SavePermission(Permission{Role: RoleUser, Command: "git", Allowed: true}); err != nil {
+		t.Fatalf("SavePermission() unexpected error: %v", err)
+	}
+	rules, err := store.Permissions()
+	if err != nil || len(rules) != 1 || !rules[0].Allowed {
+		t.Errorf("Permissions() = %+v, %v, want the rule replaced", rules, err)
+	}
+	if err := store.DeletePermission(RoleUser, "git"); err != nil {
+		t.Fatalf("DeletePermission() unexpected error: %v", err)
+	}
+	if rules, _ := store.Permissions(); len(rules) != 0 {
+		t.Errorf("Permissions() after DeletePermission() = %+v, want none", rules)
+	}
+
+	settings := []Setting{
+		{UserID: alice.ID, Kind: "option", Name: "color", Value: "off"},
+		{UserID: alice.ID, Kind: "alias", Name: "ll", Value: "ls -l"},
+	}
+	if err := store.ReplaceSettings(alice.ID, settings); err != nil {
+		t.Fatalf("ReplaceSettings() unexpected error: %v", err)
+	}
+	saved, err := store.Settings(alice.ID)
+	if err != nil || len(saved) != 2 || saved[0].Name != "ll" {
+		t.Errorf("Settings() = %+v, %v, want them ordered by kind", saved, err)
+	}
+
+	if err := store.DeleteUser(alice); err != nil {
+		t.Fatalf("DeleteUser() unexpected error: %v", err)
+	}
+	if _, err := store.FindUser("alice"); !errors.Is(err, ErrUserNotFound) {
+		t.Errorf("FindUser() after DeleteUser() error = %v, want %v", err, ErrUserNotFound)
+	}
+	if entries, _ := store.HistoryEntries(alice.ID); len(entries) != 0 {
+		t.Errorf("HistoryEntries() after DeleteUser() = %+v, want none", entries)
+	}
+	if counts, _ := store.HistoryCounts(alice.ID); len(counts) != 0 {
+		t.Errorf("HistoryCounts() after DeleteUser() = %v, want none", counts)
+	}
+	if settings, _ := store.Settings(alice.ID); len(settings) != 0 {
+		t.Errorf("Settings() after DeleteUser() = %+v, want none", settings)
+	}
+}
+
+func TestPrefixEnd(t *testing.T) {
+	tests := []struct {
+		prefix string
+		want   string
+		ok     bool
+	}{
+		{prefix: "git", want: "giu", ok: true},
+		{prefix: "a\xff", want: "b", ok: true},
+		{prefix: "\xff\xff", ok: false},
+	}
+	for _, tt := range tests {
+		if got, ok := prefixEnd(tt.prefix); got != tt.want || ok != tt.ok {
+			t.Errorf("prefixEnd(%q) = %q, %v, want %q, %v", tt.prefix, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestFileStore_LegacyHistory(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "accounts.json")
+	legacy := `{"LastID": 1, "Users": [{"ID": 1, "Username": "alice", "History": "{\"ls\":2}"}]}`
+	if err := os.WriteFile(path, []byte(legacy), 0600); err != nil {
+		t.Fatalf("failed to write accounts: %v", err)
+	}
+	store := NewFileStore(path)
+	if counts, err := store.HistoryCounts(1); err != nil || counts["ls"] != 2 {
+		t.Fatalf("HistoryCounts() = %v, %v, want the legacy counts", counts, err)
+	}
+	if err := store.AddHistory(1, map[string]int{"ls": 1}); err != nil {
+		t.Fatalf("AddHistory() unexpected error: %v", err)
+	}
+	if counts, _ := NewFileStore(path).HistoryCounts(1); counts["ls"] != 3 {
+		t.Errorf("HistoryCounts() after a save = %v, want the legacy counts moved once", counts)
+	}
+}
+
+func TestFileStore_Persists(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "shell", "accounts.json")
+	if err := NewFileStore(path).CreateUser(&User{Username: "alice"}); err != nil {
+		t.Fatalf("CreateUser() unexpected error: %v", err)
+	}
+	if _, err := NewFileStore(path).FindUser("alice"); err != nil {
+		t.Errorf("FindUser() from a new store error = %v, want the account read back", err)
+	}
+}
+
+func TestFileStore_ConcurrentShells(t *testing.T) {
+	t.Setenv("HISTFILESIZE", "-1")
+	path := filepath.Join(t.TempDir(), "accounts.json")
+	const shells, commands = 4, 25
+
+	// Each shell has its own store on the same file, as separate processes
+	// would.
+	var wg sync.WaitGroup
+	errs := make(chan error, shells*commands)
+	for i := 0; i < shells; i++ {
+		store := NewFileStore(path)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < commands; j++ {
+				if err := store.AddHistoryEntries([]HistoryEntry{{UserID: 1, Command: "ls"}}); err != nil {
+					errs <- err
+				}
+				if err := store.AddHistory(1, map[string]int{"ls": 1}); err != nil {
+					errs <- err
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	store := NewFileStore(path)
+	if entries, _ := store.HistoryEntries(1); len(entries) != shells*commands {
+		t.Errorf("HistoryEntries() kept %d entries, want %d", len(entries), shells*commands)
+	}
+	if counts, _ := store.HistoryCounts(1); counts["ls"] != shells*commands {
+		t.Errorf("HistoryCounts() = %v, want %d runs of ls", counts, shells*commands)
+	}
+}
+
+func TestFileStore_TrimsEntries(t *testing.T) {
+	t.Setenv("HISTFILESIZE", "2")
+	store := NewFileStore(filepath.Join(t.TempDir(), "accounts.json"))
+	for _, entry := range []HistoryEntry{
+		{UserID: 1, Command: "one"},
+		{UserID: 2, Command: "other"},
+		{UserID: 1, Command: "two"},
+		{UserID: 1, Command: "three"},
+	} {
+		if err := store.AddHistoryEntries([]HistoryEntry{entry}); err != nil {
+			t.Fatalf("AddHistoryEntries() unexpected error: %v", err)
+		}
+	}
+
+	var got []string
+	entries, _ := store.HistoryEntries(1)
+	for _, entry := range entries {
+		got = append(got, entry.Command)
+	}
+	if want := []string{"two", "three"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("HistoryEntries() = %v, want the last %d: %v", got, len(want), want)
+	}
+	if entries, _ := store.HistoryEntries(2); len(entries) != 1 {
+		t.Errorf("HistoryEntries() of another user = %v, want it kept", entries)
+	}
+}
+
+func TestGormStore_UpgradesBaseline(t *testing.T) {
+	setCost(t, "4")
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "baseline.db")), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("failed to connect to test database: %v", err)
+	}
+	t.Cleanup(func() {
+		sqlDB, _ := db.DB()
+		sqlDB.Close()
+	})
+	// The schema and rows of a shell from before accounts had roles.
+	baseline := []string{
+		"CREATE TABLE users (id integer PRIMARY KEY AUTOINCREMENT, user_name text, password text, history text, CONSTRAINT uni_users_user_name UNIQUE (user_name))",
+		`INSERT INTO users (user_name, password, history) VALUES ('alice', 's3cret', '{"ls":2}')`,
+	}
+	for _, statement := range baseline {
+		if err := db.Exec(statement).Error; err != nil {
+			t.Fatalf("failed to set up baseline database: %v", err)
+		}
+	}
+
+	store := NewGormStore(db)
+	if err := store.Migrate(); err != nil {
+		t.Fatalf("Migrate() unexpected error: %v", err)
+	}
+	if _, err := EnsureAdmin(store); err != nil {
+		t.Fatalf("EnsureAdmin() unexpected error: %v", err)
+	}
+	alice, err := GetUser(store, "alice", "s3cret")
+	if err != nil {
+		t.Fatalf("GetUser() unexpected error: %v", err)
+	}
+	if alice.Role != RoleUser || alice.HistoryMap["ls"] != 2 {
+		t.Errorf("GetUser() = %+v, want a user with the legacy history", alice)
+	}
+}
@@ -4,8 +4,6 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
-
-	"gorm.io/gorm"
 )
 
 var (
@@ -18,7 +16,7 @@ var (
 	ErrPassRequired     = errors.New("password required")
 )
 
-func RegisterUser(db *gorm.DB, user *User) error {
+func RegisterUser(store UserStore, user *User) error {
 	if user == nil {
 		return errors.New("user cannot be nil")
 	}
@@ -26,82 +24,246 @@ func RegisterUser(db *gorm.DB, user *User) error {
 	if err := validate(user); err != nil {
 		return fmt.Errorf("validation error: %w", err)
 	}
-
-	var existingUser User
-	err := db.Where("user_name = ?", user.Username).First(&existingUser).Error
-	if err != nil {
-		if !errors.Is(err, gorm.ErrRecordNotFound) {
-			return err
-		}
-	} else {
-		return ErrDuplicateUser
+	if !validHomeName(user.Username) {
+		return ErrInvalidHome
 	}
 
-	historyMap := map[string]int{}
-
-	historyJSON, err := json.Marshal(historyMap)
-	if err != nil {
-		return fmt.Errorf("failed to encode history to JSON: %w", err)
-	}
-	user.History = string(historyJSON)
-
-	if err := db.Create(user).Error; err != nil {
-		return fmt.Errorf("failed to insert user into database: %w", err)
+	if err := hashIfPlain(user); err != nil {
+		return err
 	}
 
-	return nil
+	return store.Transaction(func(tx UserStore) error {
+		_, err := tx.FindUser(user.Username)
+		if err == nil {
+			return ErrDuplicateUser
+		}
+		if !errors.Is(err, ErrUserNotFound) {
+			return err
+		}
+		return tx.CreateUser(user)
+	})
 }
 
-func GetUser(db *gorm.DB, username string, password string) (User, error) {
-	var user User
-	if err := db.Where("user_name = ? ", username).First(&user).Error; err != nil {
-		if errors.Is(err, gorm.ErrRecordNotFound) {
-			return user, ErrUserNotFound
-		}
+func GetUser(store UserStore, username string, password string) (User, error) {
+	user, err := store.FindUser(username)
+	if err != nil {
 		return user, err
 	}
-	if user.Password != "" && user.Password != password {
+	if user.Password != "" {
 		if password == "" {
 			return user, ErrPassRequired
 		}
-		return user, ErrWrongPassword
+		if err := checkLocked(&user); err != nil {
+			return user, err
+		}
+		if !CheckPassword(user.Password, password) {
+			if err := recordFailure(store, &user); err != nil {
+				return user, err
+			}
+			return user, ErrWrongPassword
+		}
+		// With a second factor the failures are only forgotten once the
+		// code is right too, so that codes cannot be guessed forever.
+		if !MFAEnabled(&user) {
+			if err := resetFailures(store, &user); err != nil {
+				return user, err
+			}
+		}
+		// Legacy plaintext rows and hashes of an outdated cost are
+		// upgraded transparently; a failure keeps the old value.
+		if needsRehash(user.Password) {
+			_ = rehash(store, &user, password)
+		}
 	}
 
-	var historyMap map[string]int
-	err := json.Unmarshal([]byte(user.History), &historyMap)
+	return user, loadHistory(store, &user)
+}
+
+// LoadUser returns the account with the given username and its history
+// without checking its password, for sessions that were already
+// authenticated.
+func LoadUser(store UserStore, username string) (User, error) {
+	user, err := store.FindUser(username)
 	if err != nil {
-		historyMap = map[string]int{}
-		user.HistoryMap = historyMap
 		return user, err
 	}
+	return user, loadHistory(store, &user)
+}
+
+// loadHistory reads the stored history counts of user into its HistoryMap.
+func loadHistory(store UserStore, user *User) error {
+	historyMap, err := store.HistoryCounts(user.ID)
+	if err != nil {
+		user.HistoryMap = map[string]int{}
+		return err
+	}
 	user.HistoryMap = historyMap
+	user.synced = copyCounts(historyMap)
+	return nil
+}
 
-	return user, nil
+// FindUser returns the account with the given username without checking
+// its password.
+func FindUser(store UserStore, username string) (User, error) {
+	return store.FindUser(username)
 }
 
-func Update(db *gorm.DB, user *User) (err error) {
+// Update saves the history counts the session added to user.
+func Update(store UserStore, user *User) (err error) {
 	if user == nil {
 		return ErrUserShouldntNill
 	}
 	if err := validate(user); err != nil {
 		return fmt.Errorf("validation error: %w", err)
 	}
-	historyJSON, err := json.Marshal(user.HistoryMap)
-	if err != nil {
-		return fmt.Errorf("failed to encode history to JSON: %w", err)
+	// Passwords, roles and lockout state are only changed through their
+	// own functions, so a long-running session never writes back the
+	// values it loaded over those another session changed since.
+	return store.Transaction(func(tx UserStore) error {
+		if err := mergeHistory(tx, user); err != nil {
+			return err
+		}
+		user.synced = copyCounts(user.HistoryMap)
+		return nil
+	})
+}
+
+// SetPassword replaces the stored password of user; an empty password
+// removes it.
+func SetPassword(store UserStore, user *User, password string) error {
+	if user == nil {
+		return ErrUserShouldntNill
 	}
-	user.History = string(historyJSON)
-	err = db.Save(user).Error
-	if err != nil {
-		return fmt.Errorf("failed to update user in database: %w", err)
+	hash := ""
+	if password != "" {
+		var err error
+		if hash, err = HashPassword(password); err != nil {
+			return err
+		}
 	}
-
+	changed := *user
+	changed.Password = hash
+	if err := store.UpdateUser(&changed, "Password"); err != nil {
+		return err
+	}
+	user.Password = hash
 	return nil
 }
 
+// DeleteUser removes the account with the given username together with its
+// history entries and settings.
+func DeleteUser(store UserStore, username string) error {
+	return store.Transaction(func(tx UserStore) error {
+		user, err := tx.FindUser(username)
+		if err != nil {
+			return err
+		}
+		if err := keepAdmin(tx, &user); err != nil {
+			return err
+		}
+		return tx.DeleteUser(&user)
+	})
+}
+
+// ListUsers returns every account ordered by username.
+func ListUsers(store UserStore) ([]User, error) {
+	return store.ListUsers()
+}
+
 func validate(user *User) (err error) {
 	if user.Username == "" {
 		return ErrUserNameRequired
 	}
 	return nil
 }
+
+// SyncHistory merges the counts this session added to user's history into
+// the stored ones, so that concurrent sessions of the same user never
+// overwrite each other. With share set, user also picks up the counts
+// stored by the other sessions. Anonymous sessions keep their counts in
+// memory only.
+func SyncHistory(store UserStore, user *User, share bool) error {
+	if user == nil {
+		return ErrUserShouldntNill
+	}
+	if user.ID == 0 {
+		return nil
+	}
+	if err := mergeHistory(store, user); err != nil {
+		return err
+	}
+	if share {
+		counts, err := store.HistoryCounts(user.ID)
+		if err != nil {
+			return err
+		}
+		user.HistoryMap = counts
+	}
+	user.synced = copyCounts(user.HistoryMap)
+	return nil
+}
+
+// ClearHistory empties the stored history counts of user, including those
+// merged in by other sessions.
+func ClearHistory(store UserStore, user *User) error {
+	if user == nil {
+		return ErrUserShouldntNill
+	}
+	if err := store.ClearHistory(user.ID); err != nil {
+		return err
+	}
+	user.HistoryMap = map[string]int{}
+	user.synced = map[string]int{}
+	return nil
+}
+
+// mergeHistory adds the session's changes to the history of user since the
+// last sync to the stored counts.
+func mergeHistory(store UserStore, user *User) error {
+	delta := map[string]int{}
+	for line, count := range user.HistoryMap {
+		if d := count - user.synced[line]; d != 0 {
+			delta[line] = d
+		}
+	}
+	for line, count := range user.synced {
+		if _, ok := user.HistoryMap[line]; !ok {
+			delta[line] = -count
+		}
+	}
+	return store.AddHistory(user.ID, delta)
+}
+
+// decodeCounts reads history counts kept as JSON by older account files;
+// unreadable ones are empty.
+func decodeCounts(history string) map[string]int {
+	counts := map[string]int{}
+	if history != "" {
+		if err := json.Unmarshal([]byte(history), &counts); err != nil || counts == nil {
+			counts = map[string]int{}
+		}
+	}
+	return counts
+}
+
+// addCounts adds delta to counts and drops the counts that are not
+// positive anymore.
+func addCounts(counts, delta map[string]int) map[string]int {
+	for line, d := range delta {
+		counts[line] += d
+	}
+	for line, count := range counts {
+		if count <= 0 {
+			delete(counts, line)
+		}
+	}
+	return counts
+}
+
+func copyCounts(counts map[string]int) map[string]int {
+	copied := make(map[string]int, len(counts))
+	for line, count := range counts {
+		copied[line] = count
+	}
+	return copied
+}
@@ -1,15 +0,0 @@
-DROP TABLE users;
-CREATE TABLE users (
-    id BIGINT GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,
-    user_name VARCHAR(50) NOT NULL UNIQUE,
-    password TEXT, 
-    history '' -- Stores command history as JSON (map structure)
-);
-
-
-CREATE TABLE users (
-    id BIGINT GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,
-    user_name VARCHAR(50) NOT NULL UNIQUE,
-    password TEXT, 
-    history TEXT
-);
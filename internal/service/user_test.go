@@ -9,12 +9,10 @@ import (
 	"errors"
 	"fmt"
 	"strings"
-
-	"gorm.io/gorm"
 )
 
 func TestRegisterUser(t *testing.T) {
-	db := db.GetDB()
+	store := NewGormStore(db.GetDB())
 
 	uniqueUsernamePrefix := "testuser_register_" + generateTestSuffix()
 
@@ -46,13 +44,13 @@ func TestRegisterUser(t *testing.T) {
 	}
 
 	duplicateUser := &User{Username: uniqueUsernamePrefix + "duplicate", Password: "password123"}
-	if err := RegisterUser(db, duplicateUser); err != nil && !errors.Is(err, ErrDuplicateUser) { 
+	if err := RegisterUser(store, duplicateUser); err != nil && !errors.Is(err, ErrDuplicateUser) { 
 		t.Fatalf("Setup failed: Could not create duplicate user for testing: %v", err)
 	}
 
 	for _, tc := range testCases {
 		t.Run(tc.name, func(t *testing.T) {
-			err := RegisterUser(db, tc.user)
+			err := RegisterUser(store, tc.user)
 
 			if tc.wantErr != nil {
 				if err == nil {
@@ -64,16 +62,16 @@ func TestRegisterUser(t *testing.T) {
 				t.Fatalf("Test case '%s': Unexpected error: %v", tc.name, err)
 			} else {
 				if tc.user != nil && tc.user.Username != "" { 
-					defer cleanupUser(db, tc.user.Username)
+					defer cleanupUser(store, tc.user.Username)
 				}
 			}
 		})
 	}
-	cleanupUser(db, duplicateUser.Username)
+	cleanupUser(store, duplicateUser.Username)
 }
 
 func TestGetUser(t *testing.T) {
-	db := db.GetDB()
+	store := NewGormStore(db.GetDB())
 
 	uniqueUsernamePrefix := "testuser_get_" + generateTestSuffix()
 
@@ -81,15 +79,15 @@ func TestGetUser(t *testing.T) {
 	existingUserWrongPass := &User{Username: uniqueUsernamePrefix + "wrongpass", Password: "correctpassword"}
 	nonExistingUser := &User{Username: uniqueUsernamePrefix + "nonexistent"}
 
-	if err := RegisterUser(db, existingUserCorrectPass); err != nil {
+	if err := RegisterUser(store, existingUserCorrectPass); err != nil {
 		t.Fatalf("Setup failed: Could not register user for testing: %v", err)
 	}
-	defer cleanupUser(db, existingUserCorrectPass.Username) 
+	defer cleanupUser(store, existingUserCorrectPass.Username) 
 
-	if err := RegisterUser(db, existingUserWrongPass); err != nil {
+	if err := RegisterUser(store, existingUserWrongPass); err != nil {
 		t.Fatalf("Setup failed: Could not register user for testing: %v", err)
 	}
-	defer cleanupUser(db, existingUserWrongPass.Username) 
+	defer cleanupUser(store, existingUserWrongPass.Username) 
 
 	testCases := []struct {
 		name       string
@@ -102,7 +100,7 @@ func TestGetUser(t *testing.T) {
 		{
 			name:       "Successful get user - correct password",
 			username:   existingUserCorrectPass.Username,
-			password:   existingUserCorrectPass.Password,
+			password:   "correctpassword",
 			wantErr:    nil,
 			expectUser: true,
 			checkUser: func(user User) bool {
@@ -140,7 +138,7 @@ func TestGetUser(t *testing.T) {
 
 	for _, tc := range testCases {
 		t.Run(tc.name, func(t *testing.T) {
-			user, err := GetUser(db, tc.username, tc.password)
+			user, err := GetUser(store, tc.username, tc.password)
 
 			if tc.wantErr != nil {
 				if err == nil {
@@ -169,16 +167,16 @@ func TestGetUser(t *testing.T) {
 }
 
 func TestUpdate(t *testing.T) {
-	db := db.GetDB()
+	store := NewGormStore(db.GetDB())
 	uniqueUsernamePrefix := "testuser_update_" + generateTestSuffix()
 
 	baseUser := &User{Username: uniqueUsernamePrefix + "baseuser", Password: "initialpassword", HistoryMap: map[string]int{"cmd1": 1}}
-	if err := RegisterUser(db, baseUser); err != nil {
+	if err := RegisterUser(store, baseUser); err != nil {
 		t.Fatalf("Setup failed: Could not register base user for update tests: %v", err)
 	}
-	defer cleanupUser(db, baseUser.Username) // Cleanup after tests
+	defer cleanupUser(store, baseUser.Username) // Cleanup after tests
 
-	userForUpdate, err := GetUser(db, baseUser.Username, "initialpassword")
+	userForUpdate, err := GetUser(store, baseUser.Username, "initialpassword")
 	if err != nil {
 		t.Fatalf("Setup failed: Could not get user for update tests: %v", err)
 	}
@@ -190,17 +188,17 @@ func TestUpdate(t *testing.T) {
 		checkUser func(username string, expectedHistory map[string]int) bool 
 	}{
 		{
-			name:    "Successful update - password and history",
+			name:    "Successful update - history, not the password",
 			user:    &User{ID: userForUpdate.ID, Username: userForUpdate.Username, Password: "newpassword", HistoryMap: map[string]int{"cmd1": 5, "cmd2": 1}},
 			wantErr: nil,
 			checkUser: func(username string, expectedHistory map[string]int) bool {
-				updatedUser, err := GetUser(db, username, "newpassword")
+				updatedUser, err := GetUser(store, username, "initialpassword")
 				if err != nil {
 					t.Fatalf("CheckUser failed to GetUser after update: %v", err)
 					return false
 				}
-				if updatedUser.Password != "newpassword" {
-					t.Errorf("CheckUser: Password not updated correctly, got: %s, expected: newpassword", updatedUser.Password)
+				if CheckPassword(updatedUser.Password, "newpassword") {
+					t.Errorf("CheckUser: Password changed by Update, got a hash of newpassword, expected initialpassword")
 					return false
 				}
 				if !historyMapsEqual(updatedUser.HistoryMap, expectedHistory) {
@@ -221,38 +219,39 @@ func TestUpdate(t *testing.T) {
 			wantErr: ErrUserNameRequired,
 		},
 		{
-			name:    "Update with empty HistoryMap - should clear history",
+			name:    "Update with empty HistoryMap - keeps the stored history",
 			user:    &User{ID: userForUpdate.ID, Username: userForUpdate.Username, Password: "password", HistoryMap: map[string]int{}},
 			wantErr: nil,
 			checkUser: func(username string, expectedHistory map[string]int) bool {
-				updatedUser, err := GetUser(db, username, "password")
+				updatedUser, err := GetUser(store, username, "initialpassword")
 				if err != nil {
 					t.Fatalf("CheckUser failed to GetUser after update with empty HistoryMap: %v", err)
 					return false
 				}
-				if len(updatedUser.HistoryMap) != 0 {
-					t.Errorf("CheckUser: HistoryMap not cleared (empty), got: %v, expected empty map", updatedUser.HistoryMap)
+				// Only the counts a session added are merged into the stored ones.
+				if !historyMapsEqual(updatedUser.HistoryMap, map[string]int{"cmd1": 5, "cmd2": 1}) {
+					t.Errorf("CheckUser: HistoryMap changed, got: %v, expected: %v", updatedUser.HistoryMap, map[string]int{"cmd1": 5, "cmd2": 1})
 					return false
 				}
 				return true
 			},
 		},
 		{
-			name:    "Update without HistoryMap in struct - should modify history (it's assumption)",
+			name:    "Update without HistoryMap in struct - keeps the stored history",
 			user:    &User{ID: userForUpdate.ID, Username: userForUpdate.Username, Password: "password_only_update"}, 
 			wantErr: nil,
 			checkUser: func(username string, expectedHistory map[string]int) bool { 
-				updatedUser, err := GetUser(db, username, "password_only_update")
+				updatedUser, err := GetUser(store, username, "initialpassword")
 				if err != nil {
 					t.Fatalf("CheckUser failed to GetUser after update without HistoryMap: %v", err)
 					return false
 				}
-				if !historyMapsEqual(updatedUser.HistoryMap, map[string]int{}) { 
-					t.Errorf("CheckUser: HistoryMap not modified when not expected, got: %v, expected original: %v", updatedUser.HistoryMap, map[string]int{})
+				if !historyMapsEqual(updatedUser.HistoryMap, map[string]int{"cmd1": 5, "cmd2": 1}) {
+					t.Errorf("CheckUser: HistoryMap modified when not expected, got: %v, expected original: %v", updatedUser.HistoryMap, map[string]int{"cmd1": 5, "cmd2": 1})
 					return false
 				}
-				if updatedUser.Password != "password_only_update" {
-					t.Errorf("CheckUser: Password not updated, got: %s, expected: password_only_update", updatedUser.Password)
+				if CheckPassword(updatedUser.Password, "password_only_update") {
+					t.Errorf("CheckUser: Password changed by Update, got a hash of password_only_update, expected initialpassword")
 					return false
 				}
 
@@ -263,7 +262,7 @@ func TestUpdate(t *testing.T) {
 
 	for _, tc := range testCases {
 		t.Run(tc.name, func(t *testing.T) {
-			err := Update(db, tc.user)
+			err := Update(store, tc.user)
 
 			if tc.wantErr != nil {
 				if err == nil {
@@ -285,9 +284,10 @@ func TestUpdate(t *testing.T) {
 }
 
 
-func cleanupUser(db *gorm.DB, username string) {
-	var user User
-	db.Where("user_name = ?", username).Delete(&user) 
+func cleanupUser(store UserStore, username string) {
+	if user, err := store.FindUser(username); err == nil {
+		store.DeleteUser(&user)
+	}
 }
 
 func generateTestSuffix() string {
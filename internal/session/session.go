@@ -0,0 +1,91 @@
+package session
+
+import (
+	user "asa/shell/internal/service"
+	"asa/shell/internal/settings"
+	"asa/shell/utils"
+	"errors"
+	"os"
+)
+
+var ErrNoSession = errors.New("no session to return to")
+
+// Frame is a suspended session: who was logged in, where, and with which
+// variables, aliases and options. The account itself is read again from
+// the store when the session resumes, as it may have changed meanwhile.
+type Frame struct {
+	UserID   int64
+	Username string
+	Dir      string
+	Settings settings.Snapshot
+}
+
+// Stack holds the suspended sessions of a shell, the most recent last.
+type Stack struct {
+	store    user.UserStore
+	user     *user.User
+	settings *settings.Session
+	frames   []Frame
+}
+
+func NewStack(store user.UserStore, user *user.User, settings *settings.Session) *Stack {
+	return &Stack{
+		store:    store,
+		user:     user,
+		settings: settings,
+	}
+}
+
+// Depth returns the number of suspended sessions.
+func (s *Stack) Depth() int {
+	return len(s.frames)
+}
+
+// Save captures the current session. The logged-in user is saved to the
+// store first so the frame does not hold unsaved history.
+func (s *Stack) Save() (Frame, error) {
+	if s.user.Username != "" {
+		if err := user.Update(s.store, s.user); err != nil {
+			return Frame{}, err
+		}
+	}
+	dir, err := utils.CurrentPwd()
+	if err != nil {
+		return Frame{}, err
+	}
+	return Frame{UserID: s.user.ID, Username: s.user.Username, Dir: dir, Settings: s.settings.Snapshot()}, nil
+}
+
+// Push suspends the session saved in frame.
+func (s *Stack) Push(frame Frame) {
+	s.frames = append(s.frames, frame)
+}
+
+// Pop saves the current user and returns to the last suspended session,
+// with its user as stored now. A user deleted meanwhile is not logged in
+// again.
+func (s *Stack) Pop() error {
+	if len(s.frames) == 0 {
+		return ErrNoSession
+	}
+	if s.user.Username != "" {
+		if err := user.Update(s.store, s.user); err != nil {
+			return err
+		}
+	}
+	frame := s.frames[len(s.frames)-1]
+	resumed := user.User{}
+	if frame.Username != "" {
+		loaded, err := user.LoadUser(s.store, frame.Username)
+		if err != nil && !errors.Is(err, user.ErrUserNotFound) {
+			return err
+		}
+		if err == nil && loaded.ID == frame.UserID {
+			resumed = loaded
+		}
+	}
+	s.frames = s.frames[:len(s.frames)-1]
+	*s.user = resumed
+	s.settings.Restore(frame.Settings)
+	return os.Chdir(frame.Dir)
+}
@@ -0,0 +1,328 @@
+package settings
+
+import (
+	user "asa/shell/internal/service"
+	"errors"
+	"os"
+	"sort"
+	"strings"
+)
+
+// Kinds of user settings.
+const (
+	KindEnv    = "env"
+	KindAlias  = "alias"
+	KindOption = "option"
+)
+
+// Options users may set.
+const (
+	// OptionColor turns colored output "on" or "off".
+	OptionColor = "color"
+	// OptionPrompt is the prompt format: \u is the user, \w the working
+	// directory.
+	OptionPrompt = "prompt"
+
+	colorEnv = "SHELLCOLOR"
+)
+
+var (
+	ErrUnknownOption = errors.New("unknown option")
+	ErrInvalidName   = errors.New("invalid name")
+	ErrNoAlias       = errors.New("no such alias")
+	ErrInvalidValue  = errors.New("invalid option value")
+)
+
+type original struct {
+	value string
+	set   bool
+}
+
+// Session holds the aliases, options and exported variables of the shell.
+// Changes are tracked from a baseline so that Reset can undo everything a
+// user set up, leaving the next user a clean shell.
+type Session struct {
+	exported map[string]bool
+	aliases  map[string]string
+	options  map[string]string
+
+	originals   map[string]original
+	baseAliases map[string]string
+	baseOptions map[string]string
+}
+
+func New() *Session {
+	s := &Session{}
+	s.Commit()
+	return s
+}
+
+// Commit makes the current state the baseline Reset returns to.
+func (s *Session) Commit() {
+	s.exported = map[string]bool{}
+	s.originals = map[string]original{}
+	if s.aliases == nil {
+		s.aliases = map[string]string{}
+	}
+	if s.options == nil {
+		s.options = map[string]string{}
+	}
+	s.baseAliases = copyMap(s.aliases)
+	s.baseOptions = copyMap(s.options)
+	// The color command changes the variable directly.
+	s.remember(colorEnv)
+}
+
+// Reset undoes every change since the last Commit.
+func (s *Session) Reset() {
+	for name, orig := range s.originals {
+		if orig.set {
+			os.Setenv(name, orig.value)
+		} else {
+			os.Unsetenv(name)
+		}
+	}
+	s.aliases = copyMap(s.baseAliases)
+	s.options = copyMap(s.baseOptions)
+	s.Commit()
+}
+
+// Snapshot is the state of a Session saved by Snapshot and brought back
+// by Restore.
+type Snapshot struct {
+	exported map[string]bool
+	aliases  map[string]string
+	options  map[string]string
+	env      map[string]original
+}
+
+// Snapshot saves the variables, aliases and options changed since the last
+// Commit.
+func (s *Session) Snapshot() Snapshot {
+	snap := Snapshot{
+		exported: make(map[string]bool, len(s.exported)),
+		aliases:  copyMap(s.aliases),
+		options:  copyMap(s.options),
+		env:      make(map[string]original, len(s.originals)),
+	}
+	for name := range s.exported {
+		snap.exported[name] = true
+	}
+	for name := range s.originals {
+		value, set := os.LookupEnv(name)
+		snap.env[name] = original{value: value, set: set}
+	}
+	return snap
+}
+
+// Restore undoes every change since the last Commit and applies those
+// saved in snap instead.
+func (s *Session) Restore(snap Snapshot) {
+	s.Reset()
+	for name, saved := range snap.env {
+		s.remember(name)
+		if saved.set {
+			os.Setenv(name, saved.value)
+		} else {
+			os.Unsetenv(name)
+		}
+	}
+	for name := range snap.exported {
+		s.exported[name] = true
+	}
+	s.aliases = copyMap(snap.aliases)
+	s.options = copyMap(snap.options)
+}
+
+func (s *Session) remember(name string) {
+	if _, ok := s.originals[name]; ok {
+		return
+	}
+	value, set := os.LookupEnv(name)
+	s.originals[name] = original{value: value, set: set}
+}
+
+// Export sets the environment variable name for the shell and the programs
+// it runs.
+func (s *Session) Export(name, value string) error {
+	if !validName(name) {
+		return ErrInvalidName
+	}
+	s.remember(name)
+	s.exported[name] = true
+	return os.Setenv(name, value)
+}
+
+// Unexport removes the environment variable name.
+func (s *Session) Unexport(name string) error {
+	if !validName(name) {
+		return ErrInvalidName
+	}
+	s.remember(name)
+	delete(s.exported, name)
+	return os.Unsetenv(name)
+}
+
+// Exported returns the variables exported since the last Commit, sorted
+// by name.
+func (s *Session) Exported() []user.Setting {
+	vars := []user.Setting{}
+	for name := range s.exported {
+		if value, ok := os.LookupEnv(name); ok {
+			vars = append(vars, user.Setting{Kind: KindEnv, Name: name, Value: value})
+		}
+	}
+	sortSettings(vars)
+	return vars
+}
+
+// SetAlias makes name expand to value as the first word of a command.
+func (s *Session) SetAlias(name, value string) error {
+	if name == "" || strings.ContainsAny(name, " \t=/'\"") {
+		return ErrInvalidName
+	}
+	s.aliases[name] = value
+	return nil
+}
+
+// RemoveAlias removes the alias name.
+func (s *Session) RemoveAlias(name string) error {
+	if _, ok := s.aliases[name]; !ok {
+		return ErrNoAlias
+	}
+	delete(s.aliases, name)
+	return nil
+}
+
+// Alias returns the expansion of name.
+func (s *Session) Alias(name string) (string, bool) {
+	value, ok := s.aliases[name]
+	return value, ok
+}
+
+// Aliases returns every alias, sorted by name.
+func (s *Session) Aliases() []user.Setting {
+	aliases := []user.Setting{}
+	for name, value := range s.aliases {
+		aliases = append(aliases, user.Setting{Kind: KindAlias, Name: name, Value: value})
+	}
+	sortSettings(aliases)
+	return aliases
+}
+
+// ExpandAlias replaces the first word of line by its alias, once, keeping
+// the leading blanks and the rest of the line.
+func (s *Session) ExpandAlias(line string) string {
+	trimmed := strings.TrimLeft(line, " \t")
+	end := strings.IndexAny(trimmed, " \t")
+	if end < 0 {
+		end = len(trimmed)
+	}
+	value, ok := s.aliases[trimmed[:end]]
+	if !ok {
+		return line
+	}
+	return line[:len(line)-len(trimmed)] + value + trimmed[end:]
+}
+
+// SetOption sets one of the known options.
+func (s *Session) SetOption(name, value string) error {
+	switch name {
+	case OptionColor:
+		value = strings.ToLower(value)
+		s.remember(colorEnv)
+		switch value {
+		case "on":
+			os.Setenv(colorEnv, "1")
+		case "off":
+			os.Unsetenv(colorEnv)
+		default:
+			return ErrInvalidValue
+		}
+	case OptionPrompt:
+	default:
+		return ErrUnknownOption
+	}
+	s.options[name] = value
+	return nil
+}
+
+// Option returns the value of an option set in this session.
+func (s *Session) Option(name string) (string, bool) {
+	value, ok := s.options[name]
+	return value, ok
+}
+
+// Settings returns what a user would want restored next time: the
+// exported variables, the aliases and the options, color included.
+func (s *Session) Settings() []user.Setting {
+	settings := s.Exported()
+	settings = append(settings, s.Aliases()...)
+	options := copyMap(s.options)
+	options[OptionColor] = "off"
+	if _, ok := os.LookupEnv(colorEnv); ok {
+		options[OptionColor] = "on"
+	}
+	for name, value := range options {
+		settings = append(settings, user.Setting{Kind: KindOption, Name: name, Value: value})
+	}
+	sortSettings(settings)
+	return settings
+}
+
+// Apply restores stored settings. Invalid ones are skipped and the first
+// error is returned.
+func (s *Session) Apply(settings []user.Setting) error {
+	var first error
+	for _, setting := range settings {
+		var err error
+		switch setting.Kind {
+		case KindEnv:
+			err = s.Export(setting.Name, setting.Value)
+		case KindAlias:
+			err = s.SetAlias(setting.Name, setting.Value)
+		case KindOption:
+			err = s.SetOption(setting.Name, setting.Value)
+		default:
+			err = ErrUnknownOption
+		}
+		if err != nil && first == nil {
+			first = err
+		}
+	}
+	return first
+}
+
+// FormatPrompt expands the \u and \w escapes of a prompt format.
+func FormatPrompt(format, username, dir string) string {
+	return strings.NewReplacer(`\u`, username, `\w`, dir).Replace(format)
+}
+
+func validName(name string) bool {
+	if name == "" || (name[0] >= '0' && name[0] <= '9') {
+		return false
+	}
+	for _, r := range name {
+		if r != '_' && (r < 'a' || r > 'z') && (r < 'A' || r > 'Z') && (r < '0' || r > '9') {
+			return false
+		}
+	}
+	return true
+}
+
+func sortSettings(settings []user.Setting) {
+	sort.Slice(settings, func(i, j int) bool {
+		if settings[i].Kind != settings[j].Kind {
+			return settings[i].Kind < settings[j].Kind
+		}
+		return settings[i].Name < settings[j].Name
+	})
+}
+
+func copyMap(m map[string]string) map[string]string {
+	c := make(map[string]string, len(m))
+	for k, v := range m {
+		c[k] = v
+	}
+	return c
+}
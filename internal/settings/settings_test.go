@@ -0,0 +1,169 @@
+package settings
+
+import (
+	user "asa/shell/internal/service"
+	"errors"
+	"os"
+	"reflect"
+	"testing"
+)
+
+func TestSession_Reset(t *testing.T) {
+	t.Setenv("SETTINGS_KEPT", "before")
+	t.Setenv(colorEnv, "")
+	os.Unsetenv("SETTINGS_NEW")
+	os.Unsetenv(colorEnv)
+
+	s := New()
+	s.SetAlias("base", "echo base")
+	s.Commit()
+
+	s.Export("SETTINGS_KEPT", "after")
+	s.Export("SETTINGS_NEW", "value")
+	s.SetAlias("ll", "ls -l")
+	s.SetOption(OptionColor, "on")
+	s.SetOption(OptionPrompt, `\u> `)
+	s.Reset()
+
+	if got := os.Getenv("SETTINGS_KEPT"); got != "before" {
+		t.Errorf("SETTINGS_KEPT = %q, want %q", got, "before")
+	}
+	if _, set := os.LookupEnv("SETTINGS_NEW"); set {
+		t.Error("SETTINGS_NEW should be unset after Reset()")
+	}
+	if _, set := os.LookupEnv(colorEnv); set {
+		t.Errorf("%s should be unset after Reset()", colorEnv)
+	}
+	if _, ok := s.Alias("ll"); ok {
+		t.Error("alias ll should be removed by Reset()")
+	}
+	if _, ok := s.Alias("base"); !ok {
+		t.Error("alias base should survive Reset()")
+	}
+	if _, ok := s.Option(OptionPrompt); ok {
+		t.Error("prompt option should be removed by Reset()")
+	}
+}
+
+func TestSession_ExpandAlias(t *testing.T) {
+	s := New()
+	s.SetAlias("ll", "ls -l")
+	s.SetAlias("ls", "ls -a")
+
+	tests := []struct {
+		line string
+		want string
+	}{
+		{line: "ll", want: "ls -l"},
+		{line: "ll /tmp", want: "ls -l /tmp"},
+		{line: "  ll\t/tmp", want: "  ls -l\t/tmp"},
+		{line: "ls", want: "ls -a"},
+		{line: "echo ll", want: "echo ll"},
+		{line: "lll", want: "lll"},
+		{line: "", want: ""},
+	}
+
+	for _, tt := range tests {
+		if got := s.ExpandAlias(tt.line); got != tt.want {
+			t.Errorf("ExpandAlias(%q) = %q, want %q", tt.line, got, tt.want)
+		}
+	}
+}
+
+func TestSession_Invalid(t *testing.T) {
+	s := New()
+	tests := []struct {
+		name string
+		err  error
+		want error
+	}{
+		{name: "export digit", err: s.Export("1ABC", "x"), want: ErrInvalidName},
+		{name: "export dash", err: s.Export("A-B", "x"), want: ErrInvalidName},
+		{name: "alias with space", err: s.SetAlias("a b", "x"), want: ErrInvalidName},
+		{name: "unknown alias", err: s.RemoveAlias("missing"), want: ErrNoAlias},
+		{name: "unknown option", err: s.SetOption("bell", "on"), want: ErrUnknownOption},
+		{name: "invalid color", err: s.SetOption(OptionColor, "maybe"), want: ErrInvalidValue},
+	}
+
+	for _, tt := range tests {
+		if !errors.Is(tt.err, tt.want) {
+			t.Errorf("%s: error = %v, want %v", tt.name, tt.err, tt.want)
+		}
+	}
+}
+
+func TestSession_SettingsRoundTrip(t *testing.T) {
+	t.Setenv("SETTINGS_EDITOR", "")
+	t.Setenv(colorEnv, "")
+	os.Unsetenv(colorEnv)
+
+	s := New()
+	s.Export("SETTINGS_EDITOR", "vi")
+	s.SetAlias("ll", "ls -l")
+	s.SetOption(OptionColor, "on")
+	s.SetOption(OptionPrompt, `\u:\w$ `)
+
+	want := []user.Setting{
+		{Kind: KindAlias, Name: "ll", Value: "ls -l"},
+		{Kind: KindEnv, Name: "SETTINGS_EDITOR", Value: "vi"},
+		{Kind: KindOption, Name: OptionColor, Value: "on"},
+		{Kind: KindOption, Name: OptionPrompt, Value: `\u:\w$ `},
+	}
+	saved := s.Settings()
+	if !reflect.DeepEqual(saved, want) {
+		t.Fatalf("Settings() = %+v, want %+v", saved, want)
+	}
+
+	s.Reset()
+	if got := s.Settings(); len(got) != 1 || got[0].Value != "off" {
+		t.Fatalf("Settings() after Reset() = %+v, want only color off", got)
+	}
+
+	if err := s.Apply(append(saved, user.Setting{Kind: "bogus"})); !errors.Is(err, ErrUnknownOption) {
+		t.Errorf("Apply() error = %v, want %v", err, ErrUnknownOption)
+	}
+	if got := s.Settings(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Settings() after Apply() = %+v, want %+v", got, want)
+	}
+	if got := os.Getenv(colorEnv); got != "1" {
+		t.Errorf("%s = %q, want %q", colorEnv, got, "1")
+	}
+}
+
+func TestFormatPrompt(t *testing.T) {
+	if got := FormatPrompt(`[\u@\w] `, "alice", "/home"); got != "[alice@/home] " {
+		t.Errorf("FormatPrompt() = %q, want %q", got, "[alice@/home] ")
+	}
+}
+
+func TestSession_SnapshotRestore(t *testing.T) {
+	t.Setenv("SETTINGS_SNAP", "base")
+
+	s := New()
+	s.Export("SETTINGS_SNAP", "saved")
+	s.SetAlias("ll", "ls -l")
+	snap := s.Snapshot()
+
+	s.Reset()
+	s.Export("SETTINGS_SNAP", "other")
+	s.Export("SETTINGS_SNAP_NEW", "x")
+	s.Restore(snap)
+
+	if got := os.Getenv("SETTINGS_SNAP"); got != "saved" {
+		t.Errorf("SETTINGS_SNAP = %q, want %q", got, "saved")
+	}
+	if _, set := os.LookupEnv("SETTINGS_SNAP_NEW"); set {
+		t.Error("SETTINGS_SNAP_NEW should be unset after Restore()")
+	}
+	if _, ok := s.Alias("ll"); !ok {
+		t.Error("alias ll should be restored")
+	}
+	if got := s.Exported(); len(got) != 1 || got[0].Name != "SETTINGS_SNAP" {
+		t.Errorf("Exported() = %+v, want SETTINGS_SNAP", got)
+	}
+
+	s.Reset()
+	if got := os.Getenv("SETTINGS_SNAP"); got != "base" {
+		t.Errorf("SETTINGS_SNAP after Reset() = %q, want %q", got, "base")
+	}
+}
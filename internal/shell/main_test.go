@@ -0,0 +1,30 @@
+package shell
+
+import (
+	db "asa/shell/internal/database"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// TestMain runs the tests against a temporary SQLite database unless
+// SHELL_DB_DRIVER picks one, and keeps the history file, the rc file and
+// the homes of users out of the real home.
+func TestMain(m *testing.M) {
+	dir, err := os.MkdirTemp("", "shell-test")
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	if _, ok := os.LookupEnv("SHELL_DB_DRIVER"); !ok {
+		os.Setenv("SHELL_DB_DRIVER", db.SQLite)
+		os.Setenv("SHELL_DB_PATH", filepath.Join(dir, "test.db"))
+	}
+	os.Setenv("SHELL_HOME_BASE", filepath.Join(dir, "home"))
+	os.Setenv("HISTFILE", filepath.Join(dir, ".shell_history"))
+	os.Setenv("SHELLRC", filepath.Join(dir, ".shellrc"))
+	code := m.Run()
+	os.RemoveAll(dir)
+	os.Exit(code)
+}
@@ -3,46 +3,111 @@ package shell
 import (
 	"asa/shell/internal/command"
 	"asa/shell/internal/command/adduser"
+	"asa/shell/internal/command/alias"
 	"asa/shell/internal/command/cat"
 	"asa/shell/internal/command/cd"
 	"asa/shell/internal/command/color"
+	"asa/shell/internal/command/complete"
+	"asa/shell/internal/command/deluser"
 	"asa/shell/internal/command/echo"
 	"asa/shell/internal/command/exit"
+	"asa/shell/internal/command/export"
 	"asa/shell/internal/command/help"
 	"asa/shell/internal/command/history"
+	"asa/shell/internal/command/lastlog"
 	"asa/shell/internal/command/login"
 	"asa/shell/internal/command/logout"
 	"asa/shell/internal/command/ls"
+	"asa/shell/internal/command/mfa"
+	"asa/shell/internal/command/passwd"
+	"asa/shell/internal/command/perm"
 	"asa/shell/internal/command/pwd"
+	settingscmd "asa/shell/internal/command/settings"
+	"asa/shell/internal/command/su"
 	typecmd "asa/shell/internal/command/type"
+	"asa/shell/internal/command/unalias"
+	"asa/shell/internal/command/users"
+	"asa/shell/internal/command/whoami"
+	"asa/shell/internal/completion"
 	db "asa/shell/internal/database"
+	"asa/shell/internal/highlight"
+	"asa/shell/internal/histexpand"
+	"asa/shell/internal/histfile"
+	"asa/shell/internal/histio"
+	"asa/shell/internal/histpolicy"
+	"asa/shell/internal/histrank"
+	"asa/shell/internal/rcfile"
+	"asa/shell/internal/readline"
 	"asa/shell/internal/redirection"
 	user "asa/shell/internal/service"
+	"asa/shell/internal/session"
+	"asa/shell/internal/settings"
 	"asa/shell/utils"
 	"bufio"
 	"bytes"
+	"crypto/rand"
+	"encoding/hex"
 	"errors"
 	"fmt"
 	"io"
 	"os"
 	"os/exec"
+	"regexp"
+	"strconv"
 	"strings"
-
-	"gorm.io/gorm"
+	"time"
+	"unicode"
 )
 
+// suggestLimit bounds the lines the store ranks for an autosuggestion.
+const suggestLimit = 50
+
 var (
 	ErrCommandNotSupported = errors.New("command not found")
 	ErrNotValidDirectory   = errors.New("current directory is not valid")
+	ErrAccountsUnavailable = errors.New("accounts unavailable")
 )
 
+// numberedEvent matches the history references that count entries, !n and
+// !-n. They are resolved against the whole listing history prints rather
+// than the last HISTSIZE entries.
+var numberedEvent = regexp.MustCompile(`!-?[0-9]`)
+
+// accountCommands need the accounts store; without one they fail with
+// ErrAccountsUnavailable while the rest of the shell keeps working.
+var accountCommands = map[string]bool{
+	"login":   true,
+	"su":      true,
+	"adduser": true,
+	"logout":  true,
+	"passwd":  true,
+	"deluser": true,
+	"users":   true,
+	"perm":    true,
+	"lastlog": true,
+	"mfa":     true,
+}
+
 type Shell struct {
-	reader   *bufio.Reader
-	user     user.User
-	database *gorm.DB
-	commands map[string]command.Command
-	history  map[string]int
-	rootDir  string
+	reader      *bufio.Reader
+	editor      *readline.Editor
+	user        user.User
+	store       user.UserStore
+	commands    map[string]command.Command
+	completions *completion.Registry
+	history     map[string]int
+	entries     []user.HistoryEntry
+	recent      []user.HistoryEntry
+	recentUser  int64
+	recentID    int64
+	lastLine    string
+	histPath    string
+	sessionID   string
+	rootDir     string
+	startDir    string
+	cd          *cd.CDCommand
+	settings    *settings.Session
+	sessions    *session.Stack
 }
 
 type std struct {
@@ -55,26 +120,46 @@ type redirect struct {
 	redirType redirection.RedirectionType
 }
 
-func New() *Shell {
+// New opens the accounts store and sets up a shell with the builtins
+// registered and the rc file sourced. When the store is unavailable the
+// shell still starts, without accounts.
+func New() (*Shell, error) {
 	rootDir, err := utils.CurrentPwd()
 	if err != nil {
-		return &Shell{}
+		return nil, err
 	}
-
-	err = db.GetDB().AutoMigrate(&user.User{})
+	store, err := openStore()
 	if err != nil {
-		fmt.Println("Error migrating database:", err)
+		fmt.Fprintf(os.Stderr, "%v: %v\n", ErrAccountsUnavailable, err)
+	} else {
+		setupStore(store)
 	}
 
 	sh := &Shell{
-		user:     user.User{Username: ""},
-		database: db.GetDB(),
-		reader:   bufio.NewReader(os.Stdin),
-		commands: make(map[string]command.Command),
-		history:  make(map[string]int),
-		rootDir:  rootDir,
-	}
-	exitCmd := exit.NewExitCommand(sh.database, &sh.user)
+		user:        user.User{Username: ""},
+		store:       store,
+		reader:      bufio.NewReader(os.Stdin),
+		commands:    make(map[string]command.Command),
+		completions: completion.NewRegistry(),
+		history:     make(map[string]int),
+		sessionID:   newSessionID(),
+		rootDir:     rootDir,
+		settings:    settings.New(),
+	}
+	sh.sessions = session.NewStack(sh.store, &sh.user, sh.settings)
+	if fd := int(os.Stdin.Fd()); readline.IsTerminal(fd) {
+		completer := completion.NewCompleter(sh.completions, sh.builtinNames)
+		sh.editor = readline.NewEditor(os.Stdin, os.Stdout, fd, completer)
+		sh.editor.SetHistory(readline.HistoryFunc(sh.searchHistory))
+		sh.editor.SetSuggester(readline.SuggesterFunc(sh.suggestHistory))
+		sh.editor.SetHighlighter(highlight.NewHighlighter(sh.isCommand))
+	}
+	rcPath, err := rcfile.Path()
+	if err != nil {
+		rcPath = ""
+	}
+	exitCmd := exit.NewExitCommand(sh.store, &sh.user)
+	exitCmd.SetSessions(sh.sessions)
 	sh.registerCommand(exitCmd)
 
 	echoCmd := echo.NewEchoCommand()
@@ -88,6 +173,7 @@ func New() *Shell {
 
 	cdCmd := cd.NewCDCommand(sh.rootDir)
 	sh.registerCommand(cdCmd)
+	sh.cd = cdCmd
 
 	lsCmd := ls.NewLSCommand()
 	sh.commands[lsCmd.Name()] = lsCmd
@@ -95,21 +181,69 @@ func New() *Shell {
 	colorCmd := color.NewColorCommand()
 	sh.commands[colorCmd.Name()] = colorCmd
 
-	loginCmd := login.NewLoginCommand(sh.database, &sh.user)
+	loginCmd := login.NewLoginCommand(sh.store, &sh.user)
+	loginCmd.SetPasswordReader(sh.readPassword)
+	loginCmd.SetSessionID(sh.sessionID)
 	sh.commands[loginCmd.Name()] = loginCmd
 
-	adduserCmd := adduser.NewAddUserCommand(sh.database, &sh.user)
+	suCmd := su.NewSuCommand(loginCmd, sh.sessions)
+	sh.registerCommand(suCmd)
+
+	adduserCmd := adduser.NewAddUserCommand(sh.store, &sh.user)
+	adduserCmd.SetPasswordReader(sh.readPassword)
 	sh.commands[adduserCmd.Name()] = adduserCmd
 
-	logoutCmd := logout.NewLogoutCommand(sh.database, &sh.user)
+	logoutCmd := logout.NewLogoutCommand(sh.store, &sh.user)
+	logoutCmd.SetSessions(sh.sessions)
 	sh.commands[logoutCmd.Name()] = logoutCmd
 
-	historyCmd := history.NewHistoryCommand(&sh.history, &sh.user, sh.database)
+	passwdCmd := passwd.NewPasswdCommand(sh.store, &sh.user)
+	passwdCmd.SetPasswordReader(sh.readPassword)
+	passwdCmd.SetSessionID(sh.sessionID)
+	sh.registerCommand(passwdCmd)
+
+	deluserCmd := deluser.NewDelUserCommand(sh.store, &sh.user)
+	deluserCmd.SetPasswordReader(sh.readPassword)
+	deluserCmd.SetSessionID(sh.sessionID)
+	sh.registerCommand(deluserCmd)
+
+	usersCmd := users.NewUsersCommand(sh.store)
+	sh.registerCommand(usersCmd)
+
+	whoamiCmd := whoami.NewWhoamiCommand(&sh.user)
+	sh.registerCommand(whoamiCmd)
+
+	permCmd := perm.NewPermCommand(sh.store, &sh.user)
+	sh.registerCommand(permCmd)
+
+	lastlogCmd := lastlog.NewLastlogCommand(sh.store, &sh.user)
+	sh.registerCommand(lastlogCmd)
+
+	mfaCmd := mfa.NewMFACommand(sh.store, &sh.user)
+	mfaCmd.SetPasswordReader(sh.readPassword)
+	sh.registerCommand(mfaCmd)
+
+	exportCmd := export.NewExportCommand(sh.settings)
+	sh.registerCommand(exportCmd)
+
+	aliasCmd := alias.NewAliasCommand(sh.settings)
+	sh.registerCommand(aliasCmd)
+
+	unaliasCmd := unalias.NewUnaliasCommand(sh.settings)
+	sh.registerCommand(unaliasCmd)
+
+	settingsCmd := settingscmd.NewSettingsCommand(sh.store, &sh.user, sh.settings)
+	sh.registerCommand(settingsCmd)
+
+	historyCmd := history.NewHistoryCommand(&sh.history, &sh.entries, &sh.user, sh.store)
 	sh.commands[historyCmd.Name()] = historyCmd
 
 	helpCmd := help.NewHelpCommand()
 	sh.commands[helpCmd.Name()] = helpCmd
 
+	completeCmd := complete.NewCompleteCommand(sh.completions, rcPath)
+	sh.registerCommand(completeCmd)
+
 	shellBuiltins := []string{}
 	for cmd := range sh.commands {
 		shellBuiltins = append(shellBuiltins, cmd)
@@ -120,34 +254,299 @@ func New() *Shell {
 	stdout := &bytes.Buffer{}
 	sh.commands["pwd"].Execute([]string{}, stdout)
 	sh.rootDir = stdout.String()
+	sh.startDir = strings.TrimSpace(sh.rootDir)
 
-	// if err := utils.ClearAndFillHistoryWithMockData(sh.database); err != nil {
+	if rcPath != "" {
+		sh.source(rcPath)
+	}
+	// What the rc file sets up is kept when users log out.
+	sh.settings.Commit()
+	sh.loadHistFile()
+	historyCmd.SetHistFile(sh.histPath)
+
+	// if err := utils.ClearAndFillHistoryWithMockData(sh.store); err != nil {
 	// 	log.Fatalf("Error clearing and filling history: %v", err)
 	// }
-	return sh
+	return sh, nil
+}
+
+// openStore opens the accounts store the database config points to: a JSON
+// file for the file driver, a database otherwise.
+func openStore() (user.UserStore, error) {
+	config, err := db.LoadConfig()
+	if err != nil {
+		return nil, err
+	}
+	if config.Driver == db.File {
+		return user.NewFileStore(config.Path), nil
+	}
+	database, err := db.Connect()
+	if err != nil {
+		return nil, err
+	}
+	store := user.NewGormStore(database)
+	if err := store.Migrate(); err != nil {
+		fmt.Println("Error migrating database:", err)
+	}
+	return store, nil
+}
+
+func setupStore(store user.UserStore) {
+	if password, err := user.EnsureAdmin(store); err != nil {
+		fmt.Println("Error creating admin account:", err)
+	} else if password != "" {
+		fmt.Printf("Created account %q with password %q; it will not be shown again.\n", user.AdminName, password)
+	}
 }
 
 func (s *Shell) registerCommand(cmd command.Command) {
 	s.commands[cmd.Name()] = cmd
 }
 
+func (s *Shell) builtinNames() []string {
+	names := make([]string, 0, len(s.commands))
+	for name := range s.commands {
+		names = append(names, name)
+	}
+	return names
+}
+
+// isCommand reports whether name runs something: a builtin or an
+// executable found through PATH.
+func (s *Shell) isCommand(name string) bool {
+	if _, ok := s.commands[name]; ok {
+		return true
+	}
+	if s.settings != nil {
+		if _, ok := s.settings.Alias(name); ok {
+			return true
+		}
+	}
+	path, err := utils.FindCommand(name)
+	if err != nil {
+		return false
+	}
+	info, err := os.Stat(path)
+	return err == nil && !info.IsDir() && info.Mode()&0111 != 0
+}
+
+// source runs every command line of the rc file at path without recording
+// it in the history.
+func (s *Shell) source(path string) {
+	lines, err := rcfile.Read(path)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %v\n", path, err)
+		return
+	}
+	for i, line := range lines {
+		if !rcfile.IsCommand(line) {
+			continue
+		}
+		cmd, args, redirects, err := s.parseCommand(strings.TrimSpace(line))
+		if err == nil {
+			_, err = s.dispatch(cmd, args, redirects)
+		}
+		if err != nil {
+			fmt.Fprintf(redirects.stderr.std, "%s:%d: %v\n", path, i+1, err)
+		}
+		if redirects.stderr.isRedirected {
+			redirects.stderr.std.Close()
+		}
+	}
+}
+
+// loadHistFile restores the history of anonymous sessions from HISTFILE.
+func (s *Shell) loadHistFile() {
+	path, err := histfile.Path()
+	if err != nil || path == "" {
+		return
+	}
+	s.histPath = path
+	saved, err := histfile.Load(path)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %v\n", path, err)
+		return
+	}
+	for line, count := range saved.Counts {
+		s.history[line] += count
+	}
+	s.entries = append(saved.Entries, s.entries...)
+	s.trimEntries()
+	if len(s.entries) > 0 {
+		s.lastLine = s.entries[len(s.entries)-1].Command
+	}
+}
+
+// trimEntries keeps the last HISTSIZE entries of the anonymous session.
+func (s *Shell) trimEntries() {
+	size, _ := histfile.Limits()
+	if size >= 0 && len(s.entries) > size {
+		s.entries = s.entries[len(s.entries)-size:]
+	}
+}
+
+// historyCounts returns the frequency table of whoever is using the shell:
+// the logged-in user's or the anonymous session's.
+func (s *Shell) historyCounts() map[string]int {
+	if s.user.Username != "" {
+		return s.user.HistoryMap
+	}
+	return s.history
+}
+
+// loadHistory refreshes the entries the line editor ranks: the last
+// HISTSIZE of the logged-in user's from every session, or this anonymous
+// session's. Only the entries recorded since the previous call are read.
+// With HISTSHARE the counts other sessions recorded meanwhile are picked up
+// as well.
+func (s *Shell) loadHistory() {
+	if s.user.Username == "" {
+		s.resetRecent()
+		s.recent = s.entries
+		return
+	}
+	if shareHistory() {
+		if err := user.SyncHistory(s.store, &s.user, true); err != nil {
+			fmt.Fprintln(os.Stderr, "history:", err)
+		}
+	}
+	if s.recentUser != s.user.ID {
+		s.resetRecent()
+		s.recentUser = s.user.ID
+	}
+	size, _ := histfile.Limits()
+	entries, err := user.RecentHistoryEntries(s.store, s.user.ID, s.recentID, size)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "history:", err)
+		return
+	}
+	for _, entry := range entries {
+		if entry.ID > s.recentID {
+			s.recentID = entry.ID
+		}
+	}
+	s.recent = append(s.recent, entries...)
+	if size >= 0 && len(s.recent) > size {
+		s.recent = s.recent[len(s.recent)-size:]
+	}
+}
+
+// resetRecent drops the loaded entries so that the next loadHistory reads
+// them again.
+func (s *Shell) resetRecent() {
+	s.recent, s.recentUser, s.recentID = nil, 0, 0
+}
+
+// prefixCounts returns the counts of the lines starting with prefix. Those
+// of a logged-in user are the suggestLimit most run ones the store holds,
+// plus the recent lines this session has not saved yet.
+func (s *Shell) prefixCounts(prefix string) map[string]int {
+	if s.user.Username == "" || s.store == nil {
+		return s.historyCounts()
+	}
+	top, err := user.HistoryWithPrefix(s.store, s.user.ID, prefix, suggestLimit)
+	if err != nil {
+		return s.historyCounts()
+	}
+	counts := make(map[string]int, len(top))
+	for _, count := range top {
+		counts[count.Line] = count.Count
+	}
+	for _, entry := range s.recent {
+		if _, ok := counts[entry.Command]; !ok && strings.HasPrefix(entry.Command, prefix) {
+			counts[entry.Command] = s.user.HistoryMap[entry.Command]
+		}
+	}
+	return counts
+}
+
+func (s *Shell) historyUses() []histrank.Use {
+	uses := make([]histrank.Use, len(s.recent))
+	for i, entry := range s.recent {
+		uses[i] = histrank.Use{Line: entry.Command, At: entry.StartedAt}
+	}
+	return uses
+}
+
+// searchHistory backs the line editor's reverse search: every line of the
+// current history containing query, ranked by recency and frequency. Lines
+// missing from the counts were cleaned or belong to another user. A line
+// may contain query anywhere, so the counts in memory are scanned rather
+// than queried by prefix.
+func (s *Shell) searchHistory(query string) []string {
+	counts := s.historyCounts()
+	return histrank.Rank(counts, s.historyUses(), time.Now(), func(line string) bool {
+		return counts[line] > 0 && strings.Contains(line, query)
+	})
+}
+
+// suggestHistory backs the line editor's autosuggestions: the most likely
+// continuation of line according to the same ranking.
+func (s *Shell) suggestHistory(line string) string {
+	counts := s.prefixCounts(line)
+	ranked := histrank.Rank(counts, s.historyUses(), time.Now(), func(candidate string) bool {
+		return counts[candidate] > 0 && len(candidate) > len(line) && strings.HasPrefix(candidate, line)
+	})
+	if len(ranked) == 0 {
+		return ""
+	}
+	return ranked[0]
+}
+
+// expandHistory applies history expansion to input against the numbered
+// history listing and echoes the expanded line, like csh and bash do.
+func (s *Shell) expandHistory(input string) (string, error) {
+	if !strings.ContainsAny(input, "!^") {
+		return input, nil
+	}
+	line := strings.TrimLeft(input, " \t")
+	indent := input[:len(input)-len(line)]
+	s.loadHistory()
+	entries := s.recent
+	if s.user.Username != "" && numberedEvent.MatchString(line) {
+		all, err := user.GetHistoryEntries(s.store, s.user.ID)
+		if err != nil {
+			return "", err
+		}
+		entries = all
+	}
+	lines := make([]string, len(entries))
+	for i, entry := range entries {
+		lines[i] = entry.Command
+	}
+	expanded, changed, err := histexpand.Expand(line, lines)
+	if err != nil {
+		return "", err
+	}
+	if changed {
+		fmt.Println(expanded)
+	}
+	return indent + expanded, nil
+}
+
 func (s *Shell) Start() error {
 	for {
-		if err := s.printPrompt(); err != nil {
-			return err
-		}
-		input, err := s.readInput()
+		input, err := s.readLine()
 		if err != nil {
 			return err
 		}
-		if input == "" {
+		if strings.TrimSpace(input) == "" {
+			continue
+		}
+		input, err = s.expandHistory(input)
+		if err != nil {
+			cmdError := fmt.Sprintf("%s\n", err)
+			if utils.IsColor() {
+				cmdError = utils.ColorText(cmdError, utils.TextRed)
+			}
+			fmt.Fprint(os.Stderr, cmdError)
 			continue
 		}
 		if stderr, err := s.executeCommand(input); err != nil {
 			if stderr.isRedirected {
 				defer stderr.std.Close()
 			}
-			cmdError := fmt.Sprintf("%s: %v\n", input, err)
+			cmdError := fmt.Sprintf("%s: %v\n", strings.TrimSpace(input), err)
 			if utils.IsColor() {
 				cmdError = utils.ColorText(cmdError, utils.TextRed)
 			}
@@ -163,10 +562,10 @@ func (s *Shell) Start() error {
 	}
 }
 
-func (s *Shell) printPrompt() error {
+func (s *Shell) prompt() (string, error) {
 	currentDir, err := utils.CurrentPwd()
 	if err != nil {
-		return err
+		return "", err
 	}
 	addr := utils.HandleAdress(s.rootDir, currentDir)
 	user := s.user.Username
@@ -174,55 +573,305 @@ func (s *Shell) printPrompt() error {
 		addr = utils.ColorText(addr, utils.TextBlue)
 		user = utils.ColorText(s.user.Username, utils.TextGreen)
 	}
+	// Sessions nested with su show how deep they are.
+	nesting := ""
+	if depth := s.depth(); depth > 0 {
+		nesting = fmt.Sprintf("[%d] ", depth)
+	}
+	if s.settings != nil {
+		if format, ok := s.settings.Option(settings.OptionPrompt); ok {
+			return nesting + settings.FormatPrompt(format, user, addr), nil
+		}
+	}
 	if s.user.Username != "" {
-		_, err = fmt.Fprintf(os.Stdout, "%s:%s$ ", user, addr)
-	} else {
-		_, err = fmt.Fprintf(os.Stdout, "%s$ ", addr)
+		return fmt.Sprintf("%s%s:%s$ ", nesting, user, addr), nil
 	}
+	return fmt.Sprintf("%s%s$ ", nesting, addr), nil
+}
+
+func (s *Shell) printPrompt() error {
+	prompt, err := s.prompt()
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprint(os.Stdout, prompt)
 	return err
 }
 
+// readLine reads the next command through the line editor when stdin is a
+// terminal and falls back to plain buffered reads otherwise.
+func (s *Shell) readLine() (string, error) {
+	if s.editor == nil {
+		if err := s.printPrompt(); err != nil {
+			return "", err
+		}
+		return s.readInput()
+	}
+	prompt, err := s.prompt()
+	if err != nil {
+		return "", err
+	}
+	s.loadHistory()
+	input, err := s.editor.ReadLine(prompt)
+	if errors.Is(err, readline.ErrInterrupted) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRightFunc(input, unicode.IsSpace), nil
+}
+
+// readPassword prompts on stderr and reads a password from stdin, with
+// echo disabled when stdin is a terminal.
+func (s *Shell) readPassword(prompt string) (string, error) {
+	if s.editor != nil {
+		return readline.ReadPassword(int(os.Stdin.Fd()), os.Stderr, prompt)
+	}
+	fmt.Fprint(os.Stderr, prompt)
+	password, err := s.reader.ReadString('\n')
+	if err != nil && password == "" {
+		return "", err
+	}
+	return strings.TrimRight(password, "\r\n"), nil
+}
+
+// readInput reads a line keeping its leading blanks, which HISTCONTROL's
+// ignorespace looks at.
 func (s *Shell) readInput() (string, error) {
 	input, err := s.reader.ReadString('\n')
 	if err != nil {
 		return "", err
 	}
-	return strings.TrimSpace(input), nil
+	return strings.TrimRightFunc(input, unicode.IsSpace), nil
 }
 
-func (s *Shell) executeCommand(input string) (*std, error) {
+func (s *Shell) executeCommand(line string) (*std, error) {
+	input := strings.TrimSpace(line)
+	startedAt := time.Now()
+	cwd, _ := utils.CurrentPwd()
+	loggedIn := s.user.Username != ""
+
 	cmd, args, redirects, err := s.parseCommand(input)
-	if cmd != "history" {
+	expanded := input
+	if s.settings != nil {
+		expanded = s.settings.ExpandAlias(input)
+	}
+	recorded, record := s.historyLine(line, expanded)
+	record = record && cmd != "history"
+	entry := user.HistoryEntry{
+		UserID:    s.user.ID,
+		Command:   recorded,
+		StartedAt: startedAt,
+		Cwd:       cwd,
+		SessionID: s.sessionID,
+	}
+	if record {
+		s.lastLine = recorded
 		if s.user.Username != "" {
-			s.user.HistoryMap[input]++
-			// err := user.Update(s.database, &s.user)
+			s.user.HistoryMap[recorded]++
 		} else {
-			s.history[input]++
+			s.history[recorded]++
+		}
+	}
+
+	status := 1
+	if err == nil {
+		userID, depth := s.user.ID, s.depth()
+		status, err = s.dispatch(cmd, args, redirects)
+		// history can clean the stored entries.
+		if cmd == "history" {
+			s.resetRecent()
+		}
+		// Returning to a suspended session restores its settings.
+		if s.user.ID != userID && s.depth() >= depth {
+			s.switchSettings()
 		}
+		s.updateRoot()
 	}
 
+	if record {
+		entry.Duration = time.Since(startedAt)
+		entry.ExitStatus = status
+		s.recordEntry(entry, loggedIn)
+	}
+	return redirects.stderr, err
+}
+
+// depth returns the number of sessions su suspended.
+func (s *Shell) depth() int {
+	if s.sessions == nil {
+		return 0
+	}
+	return s.sessions.Depth()
+}
+
+// switchSettings reverts the settings of the previous user and applies
+// those stored for the one now logged in, if any.
+func (s *Shell) switchSettings() {
+	if s.settings == nil {
+		return
+	}
+	s.settings.Reset()
+	if s.user.Username == "" || s.store == nil {
+		return
+	}
+	stored, err := user.LoadSettings(s.store, &s.user)
+	if err == nil {
+		err = s.settings.Apply(stored)
+	}
 	if err != nil {
-		return redirects.stderr, err
+		fmt.Fprintln(os.Stderr, "settings:", err)
 	}
+}
 
+// updateRoot makes the home of the logged-in user, or the directory the
+// shell started in for anonymous sessions, the target of a bare cd and
+// what the prompt abbreviates to ~. The user's home is also what ~ stands
+// for in cd, which is $HOME in anonymous sessions.
+func (s *Shell) updateRoot() {
+	root, home := s.startDir, ""
+	if s.user.Username != "" && s.user.HomeDir != "" {
+		root, home = s.user.HomeDir, s.user.HomeDir
+	}
+	if s.cd != nil {
+		s.cd.SetHome(home)
+	}
+	if root == "" || root == strings.TrimSpace(s.rootDir) {
+		return
+	}
+	s.rootDir = root
+	if s.cd != nil {
+		s.cd.SetRoot(root)
+	}
+}
+
+// historyLine applies the HISTCONTROL, HISTIGNORE and HISTREDACT settings
+// to line, whose aliases expand to expanded, and returns the form to record
+// in the history, if any.
+func (s *Shell) historyLine(line, expanded string) (string, bool) {
+	policy, err := histpolicy.FromEnv()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "history:", err)
+	}
+	return policy.FilterExpanded(line, expanded, s.lastLine)
+}
+
+// recordEntry stores an execution in the history of whoever ran it: the
+// accounts store for a logged-in user, memory for an anonymous session.
+func (s *Shell) recordEntry(entry user.HistoryEntry, loggedIn bool) {
+	if !loggedIn {
+		entry.UserID = 0
+		s.entries = append(s.entries, entry)
+		s.trimEntries()
+		if s.histPath == "" {
+			return
+		}
+		_, fileSize := histfile.Limits()
+		saved := histio.History{Entries: []user.HistoryEntry{entry}}
+		if err := histfile.Append(s.histPath, saved, fileSize); err != nil {
+			fmt.Fprintln(os.Stderr, "history:", err)
+		}
+		return
+	}
+	if err := user.AddHistoryEntry(s.store, &entry); err != nil {
+		fmt.Fprintln(os.Stderr, "history:", err)
+	}
+	// login and logout save the counts of the user they replace themselves.
+	if s.user.ID != entry.UserID {
+		return
+	}
+	if err := user.SyncHistory(s.store, &s.user, shareHistory()); err != nil {
+		fmt.Fprintln(os.Stderr, "history:", err)
+	}
+}
+
+// shareHistory reports whether HISTSHARE asks sessions of the same user to
+// pick up each other's commands as they run.
+func shareHistory() bool {
+	share := os.Getenv("HISTSHARE")
+	return share != "" && share != "0"
+}
+
+// dispatch runs a parsed command and returns its exit status.
+func (s *Shell) dispatch(cmd string, args []string, redirects *redirect) (int, error) {
 	if redirects.stdout.isRedirected {
 
 		defer redirects.stdout.std.Close()
 	}
 
-	if command, exists := s.commands[cmd]; exists {
-		err := command.Execute(args, redirects.stdout.std)
-		if err != nil {
-			return redirects.stderr, err
+	command, exists := s.commands[cmd]
+	if exists && s.store == nil && needsAccounts(cmd, args) {
+		return 1, ErrAccountsUnavailable
+	}
+	if err := s.authorize(cmd, exists); err != nil {
+		return 126, err
+	}
+	if exists {
+		if err := command.Execute(args, redirects.stdout.std); err != nil {
+			return 1, err
 		}
-		return redirects.stderr, nil
+		return 0, nil
 	}
 
 	if err := s.executeSystemCommand(cmd, args, redirects.stdout.std, redirects.stderr.std); err != nil {
-		return redirects.stderr, ErrCommandNotSupported
+		return exitStatus(err), ErrCommandNotSupported
+	}
+
+	return 0, nil
+}
+
+// needsAccounts reports whether the builtin cmd run with args needs the
+// accounts store.
+func needsAccounts(cmd string, args []string) bool {
+	if cmd == "settings" {
+		return len(args) > 0 && (args[0] == "save" || args[0] == "clear")
+	}
+	return accountCommands[cmd]
+}
+
+// authorize checks that the role of the session may run cmd, a builtin or
+// a program, which is checked under the path it resolves to.
+func (s *Shell) authorize(cmd string, builtin bool) error {
+	if s.store == nil {
+		return nil
 	}
+	name := cmd
+	if !builtin {
+		if path, err := utils.FindCommand(cmd); err == nil {
+			name = path
+		}
+	}
+	allowed, err := user.Allowed(s.store, user.RoleOf(&s.user), name, builtin)
+	if err != nil {
+		return err
+	}
+	if !allowed {
+		return fmt.Errorf("%s: %w", cmd, user.ErrPermissionDenied)
+	}
+	return nil
+}
+
+// exitStatus maps a failed external command to the status a POSIX shell
+// would report for it.
+func exitStatus(err error) int {
+	var exitErr *exec.ExitError
+	switch {
+	case errors.As(err, &exitErr):
+		return exitErr.ExitCode()
+	case errors.Is(err, utils.ErrCommandNotFound), errors.Is(err, utils.ErrEnvironmentVarNotSet):
+		return 127
+	default:
+		return 126
+	}
+}
 
-	return redirects.stderr, nil
+func newSessionID() string {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return strconv.FormatInt(time.Now().UnixNano(), 16)
+	}
+	return hex.EncodeToString(b)
 }
 
 func (s *Shell) executeSystemCommand(name string, args []string, stdout io.Writer, stderr io.Writer) error {
@@ -241,7 +890,7 @@ func (s *Shell) executeSystemCommand(name string, args []string, stdout io.Write
 
 	err = cmd.Run()
 	if err != nil {
-		return fmt.Errorf("failed to execute %s: %v", name, err)
+		return fmt.Errorf("failed to execute %s: %w", name, err)
 	}
 
 	return nil
@@ -249,6 +898,9 @@ func (s *Shell) executeSystemCommand(name string, args []string, stdout io.Write
 
 func (s *Shell) parseCommand(input string) (string, []string, *redirect, error) {
 	redirects := &redirect{stdout: &std{os.Stdout, false}, stderr: &std{os.Stderr, false}}
+	if s.settings != nil {
+		input = s.settings.ExpandAlias(input)
+	}
 	parsedArg, err1 := utils.ParseArgs(input)
 	if err1 != nil {
 		return "", nil, redirects, nil
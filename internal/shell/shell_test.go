@@ -15,16 +15,19 @@ import (
 	"asa/shell/internal/command/ls"
 	"asa/shell/internal/command/pwd"
 	typecmd "asa/shell/internal/command/type"
-	db "asa/shell/internal/database"
+	"asa/shell/internal/histexpand"
 	"asa/shell/internal/redirection"
 	user "asa/shell/internal/service"
+	"asa/shell/internal/settings"
 	"asa/shell/utils"
 	"bufio"
 	"bytes"
+	"errors"
 	"fmt"
 	"io"
 	"os"
 	"path/filepath"
+	"reflect"
 	"strings"
 	"testing"
 )
@@ -43,13 +46,13 @@ func TestShellReadInput(t *testing.T) {
 		{
 			name:     "input with spaces",
 			input:    "  hello world  \n",
-			expected: "hello world",
+			expected: "  hello world",
 		},
 	}
 
 	for _, tc := range testCases {
 		t.Run(tc.name, func(t *testing.T) {
-			shell, _ := mockShell(tc.input)
+			shell, _ := mockShell(t, tc.input)
 
 			got, err := shell.readInput()
 			if err != nil {
@@ -98,7 +101,10 @@ func TestParseCommand(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			s := New()
+			s, err := New()
+			if err != nil {
+				t.Fatalf("New() unexpected error: %v", err)
+			}
 			cmd, args, _, _ := s.parseCommand(tt.input)
 
 			if cmd != tt.wantCmd {
@@ -167,8 +173,11 @@ func TestSystemCommandExecution(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			sh := New()
-			_, err := sh.executeCommand(tt.input)
+			sh, err := New()
+			if err != nil {
+				t.Fatalf("New() unexpected error: %v", err)
+			}
+			_, err = sh.executeCommand(tt.input)
 			if (err != nil) != tt.wantErr {
 				t.Errorf("Shell.executeCommand() error = %v, wantErr %v", err, tt.wantErr)
 			}
@@ -230,7 +239,7 @@ func TestShell_SystemCommandExecution(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			sh := createTestShell()
+			sh := createTestShell(t)
 
 			if strings.Contains(tt.input, "env-script.sh") {
 				os.Setenv("TEST_VAR", "test_value")
@@ -293,7 +302,7 @@ func TestShell_CommandWithPipes(t *testing.T) {
 			stdout := &bytes.Buffer{}
 			stderr := &bytes.Buffer{}
 			stdin := strings.NewReader(tt.stdin)
-			sh := createTestShellWithStdin(stdin)
+			sh := createTestShellWithStdin(t, stdin)
 
 			err := sh.executeSystemCommand(tt.input, []string{}, stdout, stderr)
 			if (err != nil) != tt.wantErr {
@@ -317,13 +326,36 @@ func TestShell_ExecutablePermissions(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	sh := createTestShell()
+	sh := createTestShell(t)
 	_, err = sh.executeCommand(nonExecPath)
 	if err == nil {
 		t.Error("Shell.executeCommand() should fail for non-executable file")
 	}
 }
 
+func TestShell_AuthorizeProgramPath(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "tool"), []byte("#!/bin/sh\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", dir)
+	originalDir, _ := os.Getwd()
+	t.Cleanup(func() { os.Chdir(originalDir) })
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+
+	sh := setupTestShell(t)
+	if err := user.SetPermission(sh.store, user.RoleGuest, "tool", false); err != nil {
+		t.Fatalf("SetPermission() unexpected error: %v", err)
+	}
+	for _, cmd := range []string{"tool", filepath.Join(dir, "tool"), "./tool"} {
+		if err := sh.authorize(cmd, false); !errors.Is(err, user.ErrPermissionDenied) {
+			t.Errorf("authorize(%q) error = %v, want %v", cmd, err, user.ErrPermissionDenied)
+		}
+	}
+}
+
 func TestHelperProcess(t *testing.T) {
 	if os.Getenv("GO_WANT_HELPER_PROCESS") != "1" {
 		return
@@ -375,7 +407,7 @@ func TestShell_PwdCommand(t *testing.T) {
 	oldStdout := os.Stdout
 	r, w, _ := os.Pipe() 
 	os.Stdout = w
-	sh := createTestShellWithStdin(nil)
+	sh := createTestShellWithStdin(t, nil)
 	sh.registerCommand(pwd.NewPwdCommand())
 	_, err = sh.executeCommand("pwd")
 	if err != nil {
@@ -398,6 +430,12 @@ func TestShell_PwdCommand(t *testing.T) {
 
 func TestShell_parseCommand(t *testing.T) {
 	shell := setupTestShell(t)
+	// Redirections create their files in the working directory.
+	originalDir, _ := os.Getwd()
+	t.Cleanup(func() { os.Chdir(originalDir) })
+	if err := os.Chdir(t.TempDir()); err != nil {
+		t.Fatalf("Failed to change directory: %v", err)
+	}
 
 	tests := []struct {
 		name              string
@@ -551,7 +589,7 @@ func TestShell_parseCommand(t *testing.T) {
 
 func setupTestShell(t *testing.T) *Shell {
 	t.Helper() 
-	db := db.GetDB()
+	store := user.NewMemoryStore()
 
 	rootDir, err := utils.CurrentPwd()
 	if err != nil {
@@ -560,14 +598,14 @@ func setupTestShell(t *testing.T) *Shell {
 
 	testShell := &Shell{
 		user:     user.User{Username: ""}, 
-		database: db,
+		store:    store,
 		reader:   bufio.NewReader(&bytes.Buffer{}),
 		commands: make(map[string]command.Command),
 		history:  make(map[string]int),
 		rootDir:  rootDir,
 	}
 
-	exitCmd := exit.NewExitCommand(testShell.database, &testShell.user)
+	exitCmd := exit.NewExitCommand(testShell.store, &testShell.user)
 	testShell.registerCommand(exitCmd)
 	echoCmd := echo.NewEchoCommand()
 	testShell.registerCommand(echoCmd)
@@ -577,17 +615,18 @@ func setupTestShell(t *testing.T) *Shell {
 	testShell.registerCommand(pwdCmd)
 	cdCmd := cd.NewCDCommand(testShell.rootDir)
 	testShell.registerCommand(cdCmd)
+	testShell.cd = cdCmd
 	lsCmd := ls.NewLSCommand()
 	testShell.commands[lsCmd.Name()] = lsCmd
 	colorCmd := color.NewColorCommand()
 	testShell.commands[colorCmd.Name()] = colorCmd
-	loginCmd := login.NewLoginCommand(testShell.database, &testShell.user)
+	loginCmd := login.NewLoginCommand(testShell.store, &testShell.user)
 	testShell.commands[loginCmd.Name()] = loginCmd
-	adduserCmd := adduser.NewAddUserCommand(testShell.database, &testShell.user)
+	adduserCmd := adduser.NewAddUserCommand(testShell.store, &testShell.user)
 	testShell.commands[adduserCmd.Name()] = adduserCmd
-	logoutCmd := logout.NewLogoutCommand(testShell.database, &testShell.user)
+	logoutCmd := logout.NewLogoutCommand(testShell.store, &testShell.user)
 	testShell.commands[logoutCmd.Name()] = logoutCmd
-	historyCmd := history.NewHistoryCommand(&testShell.history, &testShell.user, testShell.database)
+	historyCmd := history.NewHistoryCommand(&testShell.history, &testShell.entries, &testShell.user, testShell.store)
 	testShell.commands[historyCmd.Name()] = historyCmd
 	helpCmd := help.NewHelpCommand()
 	testShell.commands[helpCmd.Name()] = helpCmd
@@ -617,11 +656,15 @@ func equalStringSlices(a, b []string) bool {
 	return true
 }
 
-func mockShell(input string) (*Shell, *bytes.Buffer) {
+func mockShell(t *testing.T, input string) (*Shell, *bytes.Buffer) {
+	t.Helper()
 	inputReader := strings.NewReader(input)
 	outputBuffer := new(bytes.Buffer)
 
-	shell := New()
+	shell, err := New()
+	if err != nil {
+		t.Fatalf("New() unexpected error: %v", err)
+	}
 	shell.reader = bufio.NewReader(inputReader)
 
 	return shell, outputBuffer
@@ -653,14 +696,302 @@ echo "TEST_VAR=$TEST_VAR"
 	}
 }
 
-func createTestShell() *Shell {
-	sh := New()
-	return sh
+func createTestShell(t *testing.T) *Shell {
+	return createTestShellWithStdin(t, nil)
 }
-func createTestShellWithStdin(stdin io.Reader) *Shell {
-	sh := New()
+func createTestShellWithStdin(t *testing.T, stdin io.Reader) *Shell {
+	t.Helper()
+	sh, err := New()
+	if err != nil {
+		t.Fatalf("New() unexpected error: %v", err)
+	}
 	if stdin != nil {
 		sh.reader = bufio.NewReader(stdin) 
 	}
 	return sh
 }
+
+func TestShell_RecordsFilteredHistory(t *testing.T) {
+	originalControl, controlSet := os.LookupEnv("HISTCONTROL")
+	defer func() {
+		if controlSet {
+			os.Setenv("HISTCONTROL", originalControl)
+		} else {
+			os.Unsetenv("HISTCONTROL")
+		}
+	}()
+	os.Setenv("HISTCONTROL", "ignoreboth")
+
+	sh := setupTestShell(t)
+	for _, line := range []string{"echo a", "echo a", " echo secret", "login nobody s3cret"} {
+		sh.executeCommand(line)
+	}
+
+	want := map[string]int{"echo a": 1, "login nobody ***": 1}
+	if !reflect.DeepEqual(sh.history, want) {
+		t.Errorf("history = %v, want %v", sh.history, want)
+	}
+	if len(sh.entries) != 2 || sh.entries[1].Command != "login nobody ***" {
+		t.Errorf("entries = %+v, want the two recorded lines", sh.entries)
+	}
+}
+
+func TestShell_HistFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history")
+	if err := os.WriteFile(path, []byte(": 1700000000:0;echo old\n: 1700000001:0;echo older\n"), 0600); err != nil {
+		t.Fatalf("Failed to write history file: %v", err)
+	}
+	for name, value := range map[string]string{"HISTFILE": path, "HISTSIZE": "2", "HISTFILESIZE": "2"} {
+		original, set := os.LookupEnv(name)
+		defer func(name string) {
+			if set {
+				os.Setenv(name, original)
+			} else {
+				os.Unsetenv(name)
+			}
+		}(name)
+		os.Setenv(name, value)
+	}
+
+	sh := setupTestShell(t)
+	sh.loadHistFile()
+	if sh.history["echo old"] != 1 || len(sh.entries) != 2 {
+		t.Fatalf("loadHistFile() history = %v, entries = %+v", sh.history, sh.entries)
+	}
+
+	sh.executeCommand("echo new")
+	if len(sh.entries) != 2 || sh.entries[1].Command != "echo new" {
+		t.Errorf("entries = %+v, want the last two", sh.entries)
+	}
+	content, _ := os.ReadFile(path)
+	if strings.Count(string(content), "\n") != 2 || !strings.HasSuffix(string(content), ";echo new\n") {
+		t.Errorf("history file = %q, want the last two records", content)
+	}
+}
+
+func TestShell_LoadHistory(t *testing.T) {
+	t.Setenv("HISTSIZE", "2")
+	sh := setupTestShell(t)
+	if err := user.RegisterUser(sh.store, &user.User{Username: "recent_user"}); err != nil {
+		t.Fatalf("RegisterUser() unexpected error: %v", err)
+	}
+	sh.user, _ = user.GetUser(sh.store, "recent_user", "")
+	record := func(commands ...string) {
+		for _, command := range commands {
+			if err := user.AddHistoryEntry(sh.store, &user.HistoryEntry{UserID: sh.user.ID, Command: command}); err != nil {
+				t.Fatalf("AddHistoryEntry() unexpected error: %v", err)
+			}
+		}
+	}
+	commands := func() []string {
+		lines := []string{}
+		for _, entry := range sh.recent {
+			lines = append(lines, entry.Command)
+		}
+		return lines
+	}
+
+	record("ls", "pwd", "make")
+	sh.loadHistory()
+	if want := []string{"pwd", "make"}; !reflect.DeepEqual(commands(), want) {
+		t.Fatalf("loadHistory() entries = %v, want %v", commands(), want)
+	}
+	record("git status")
+	sh.loadHistory()
+	sh.loadHistory()
+	if want := []string{"make", "git status"}; !reflect.DeepEqual(commands(), want) {
+		t.Errorf("loadHistory() after a new entry = %v, want %v", commands(), want)
+	}
+
+	if err := user.ClearHistoryEntries(sh.store, sh.user.ID); err != nil {
+		t.Fatalf("ClearHistoryEntries() unexpected error: %v", err)
+	}
+	sh.resetRecent()
+	sh.loadHistory()
+	if len(sh.recent) != 0 {
+		t.Errorf("loadHistory() after a reset = %v, want none", commands())
+	}
+}
+
+func TestShell_ExpandHistoryNumbers(t *testing.T) {
+	t.Setenv("HISTSIZE", "2")
+	sh := setupTestShell(t)
+	if err := user.RegisterUser(sh.store, &user.User{Username: "expand_user"}); err != nil {
+		t.Fatalf("RegisterUser() unexpected error: %v", err)
+	}
+	sh.user, _ = user.GetUser(sh.store, "expand_user", "")
+	for _, command := range []string{"echo one", "echo two", "echo three", "echo four"} {
+		if err := user.AddHistoryEntry(sh.store, &user.HistoryEntry{UserID: sh.user.ID, Command: command}); err != nil {
+			t.Fatalf("AddHistoryEntry() unexpected error: %v", err)
+		}
+	}
+
+	// history numbers all four entries although only HISTSIZE are loaded.
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{input: "!1", want: "echo one"},
+		{input: "!3 x", want: "echo three x"},
+		{input: "!-4", want: "echo one"},
+		{input: "!!", want: "echo four"},
+		{input: "!ech:1", want: "four"},
+	}
+	for _, tt := range tests {
+		got, err := sh.expandHistory(tt.input)
+		if err != nil {
+			t.Fatalf("expandHistory(%q) unexpected error: %v", tt.input, err)
+		}
+		if got != tt.want {
+			t.Errorf("expandHistory(%q) = %q, want %q", tt.input, got, tt.want)
+		}
+	}
+	if _, err := sh.expandHistory("!5"); !errors.Is(err, histexpand.ErrEventNotFound) {
+		t.Errorf("expandHistory(!5) error = %v, want %v", err, histexpand.ErrEventNotFound)
+	}
+}
+
+func TestShell_HistoryRedactsAliases(t *testing.T) {
+	for _, name := range []string{"HISTCONTROL", "HISTIGNORE", "HISTREDACT"} {
+		t.Setenv(name, "")
+	}
+	sh := setupTestShell(t)
+	sh.settings = settings.New()
+	if err := sh.settings.SetAlias("l", "login root"); err != nil {
+		t.Fatalf("SetAlias() unexpected error: %v", err)
+	}
+
+	// The login fails, but the line is recorded all the same.
+	sh.executeCommand("l s3cret")
+	for line := range sh.history {
+		if strings.Contains(line, "s3cret") {
+			t.Errorf("history recorded %q, want the password masked", line)
+		}
+	}
+	if sh.history["login root ***"] != 1 {
+		t.Errorf("history = %v, want the redacted expansion", sh.history)
+	}
+}
+
+func TestShell_SuggestHistory(t *testing.T) {
+	sh := setupTestShell(t)
+	if err := user.RegisterUser(sh.store, &user.User{Username: "suggest_user"}); err != nil {
+		t.Fatalf("RegisterUser() unexpected error: %v", err)
+	}
+	sh.user, _ = user.GetUser(sh.store, "suggest_user", "")
+
+	// Another session ran git status, this one git stash and has not saved
+	// its counts yet.
+	if err := sh.store.AddHistory(sh.user.ID, map[string]int{"git status": 5}); err != nil {
+		t.Fatalf("AddHistory() unexpected error: %v", err)
+	}
+	if err := user.AddHistoryEntry(sh.store, &user.HistoryEntry{UserID: sh.user.ID, Command: "git stash"}); err != nil {
+		t.Fatalf("AddHistoryEntry() unexpected error: %v", err)
+	}
+	sh.user.HistoryMap = map[string]int{"git stash": 1}
+	sh.loadHistory()
+
+	tests := []struct {
+		line string
+		want string
+	}{
+		{line: "git st", want: "git status"},
+		{line: "git sta", want: "git status"},
+		{line: "git stas", want: "git stash"},
+		{line: "make", want: ""},
+	}
+	for _, tt := range tests {
+		if got := sh.suggestHistory(tt.line); got != tt.want {
+			t.Errorf("suggestHistory(%q) = %q, want %q", tt.line, got, tt.want)
+		}
+	}
+}
+
+func TestShell_CdHome(t *testing.T) {
+	originalDir, _ := os.Getwd()
+	t.Cleanup(func() { os.Chdir(originalDir) })
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("SHELL_HOME_BASE", t.TempDir())
+
+	sh := setupTestShell(t)
+	sh.startDir = sh.rootDir
+	if err := user.RegisterUser(sh.store, &user.User{Username: "homed"}); err != nil {
+		t.Fatalf("RegisterUser() unexpected error: %v", err)
+	}
+	wd := func() string {
+		dir, _ := os.Getwd()
+		return dir
+	}
+	resolved := func(dir string) string {
+		dir, _ = filepath.EvalSymlinks(dir)
+		return dir
+	}
+
+	// Anonymous sessions keep ~ as $HOME.
+	if _, err := sh.executeCommand("cd ~"); err != nil || wd() != resolved(home) {
+		t.Fatalf("cd ~ went to %s, %v, want %s", wd(), err, home)
+	}
+	if _, err := sh.executeCommand("cd"); err != nil || wd() != sh.startDir {
+		t.Fatalf("cd went to %s, %v, want %s", wd(), err, sh.startDir)
+	}
+
+	if _, err := sh.executeCommand("login homed"); err != nil {
+		t.Fatalf("login unexpected error: %v", err)
+	}
+	sh.executeCommand("cd /")
+	if _, err := sh.executeCommand("cd ~"); err != nil || wd() != resolved(sh.user.HomeDir) {
+		t.Errorf("cd ~ when logged in went to %s, %v, want %s", wd(), err, sh.user.HomeDir)
+	}
+
+	if _, err := sh.executeCommand("logout"); err != nil {
+		t.Fatalf("logout unexpected error: %v", err)
+	}
+	if _, err := sh.executeCommand("cd ~"); err != nil || wd() != resolved(home) {
+		t.Errorf("cd ~ after logout went to %s, %v, want %s", wd(), err, home)
+	}
+}
+
+func TestShell_WithoutDatabase(t *testing.T) {
+	sh := setupTestShell(t)
+	sh.store = nil
+
+	tests := []struct {
+		input   string
+		wantErr error
+	}{
+		{input: "login alice s3cret", wantErr: ErrAccountsUnavailable},
+		{input: "adduser bob", wantErr: ErrAccountsUnavailable},
+		{input: "echo still works"},
+		{input: "history"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			_, err := sh.executeCommand(tt.input)
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("executeCommand(%q) error = %v, want %v", tt.input, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestOpenStore_File(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "accounts.json")
+	t.Setenv("SHELL_DB_DRIVER", "file")
+	t.Setenv("SHELL_DB_PATH", path)
+
+	store, err := openStore()
+	if err != nil {
+		t.Fatalf("openStore() unexpected error: %v", err)
+	}
+	if _, ok := store.(*user.FileStore); !ok {
+		t.Fatalf("openStore() = %T, want a file store", store)
+	}
+	if err := user.RegisterUser(store, &user.User{Username: "alice"}); err != nil {
+		t.Fatalf("RegisterUser() unexpected error: %v", err)
+	}
+	if _, err := os.Stat(path); err != nil {
+		t.Errorf("Expected the accounts file to be written: %v", err)
+	}
+}
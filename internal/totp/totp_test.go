@@ -0,0 +1,87 @@
+package totp
+
+import (
+	"encoding/base32"
+	"testing"
+	"time"
+)
+
+// rfcSecret is the SHA-1 key of the RFC 6238 test vectors.
+var rfcSecret = base32.StdEncoding.EncodeToString([]byte("12345678901234567890"))
+
+func TestHOTP_RFC6238(t *testing.T) {
+	tests := []struct {
+		unix int64
+		want string
+	}{
+		{unix: 59, want: "94287082"},
+		{unix: 1111111109, want: "07081804"},
+		{unix: 1111111111, want: "14050471"},
+		{unix: 1234567890, want: "89005924"},
+		{unix: 2000000000, want: "69279037"},
+		{unix: 20000000000, want: "65353130"},
+	}
+	for _, tt := range tests {
+		got, err := hotp(rfcSecret, Step(time.Unix(tt.unix, 0)), 8)
+		if err != nil {
+			t.Fatalf("hotp() unexpected error: %v", err)
+		}
+		if got != tt.want {
+			t.Errorf("hotp(T=%d) = %s, want %s", tt.unix, got, tt.want)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	at := time.Unix(1111111111, 0)
+	code, err := Code(rfcSecret, at)
+	if err != nil || code != "050471" {
+		t.Fatalf("Code() = %q, %v, want %q", code, err, "050471")
+	}
+
+	tests := []struct {
+		name string
+		code string
+		at   time.Time
+		want bool
+	}{
+		{name: "current code", code: code, at: at, want: true},
+		{name: "one period late", code: code, at: at.Add(Period), want: true},
+		{name: "one period early", code: code, at: at.Add(-Period), want: true},
+		{name: "expired code", code: code, at: at.Add(2 * Period), want: false},
+		{name: "wrong code", code: "123456", at: at, want: false},
+		{name: "wrong length", code: "50471", at: at, want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			step, ok := Validate(rfcSecret, tt.code, tt.at)
+			if ok != tt.want {
+				t.Errorf("Validate() = %v, want %v", ok, tt.want)
+			}
+			if ok && step != Step(at) {
+				t.Errorf("Validate() step = %d, want %d", step, Step(at))
+			}
+		})
+	}
+}
+
+func TestGenerateSecret(t *testing.T) {
+	secret, err := GenerateSecret()
+	if err != nil {
+		t.Fatalf("GenerateSecret() unexpected error: %v", err)
+	}
+	if len(secret) != 32 {
+		t.Errorf("GenerateSecret() = %q, want 32 base32 characters", secret)
+	}
+	if _, err := Code(secret, time.Now()); err != nil {
+		t.Errorf("Code() of a generated secret unexpected error: %v", err)
+	}
+}
+
+func TestURI(t *testing.T) {
+	got := URI("shell", "alice smith", "ABC")
+	want := "otpauth://totp/shell:alice%20smith?digits=6&issuer=shell&period=30&secret=ABC"
+	if got != want {
+		t.Errorf("URI() = %q, want %q", got, want)
+	}
+}
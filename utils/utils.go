@@ -10,8 +10,6 @@ import (
 	"path/filepath"
 	"sort"
 	"strings"
-
-	"gorm.io/gorm"
 )
 
 var (
@@ -31,6 +29,8 @@ var (
 	ErrColorUnset           = errors.New("color is not set")
 	ErrColorSet             = errors.New("color is already set")
 	ErrMissingCommandName   = errors.New("type: missing command name")
+	ErrPasswordMismatch     = errors.New("passwords do not match")
+	ErrNotLoggedIn          = errors.New("not logged in")
 )
 
 const (
@@ -238,11 +238,13 @@ func HandleAdress(baseAddr string, currentDir string) string {
 	cleanBaseAddr := strings.Trim(baseAddr, "\n")
 	cleanCurrentDir := strings.Trim(currentDir, "\n")
 
-	if HasPrefix(cleanCurrentDir, cleanBaseAddr) {
-		if cleanBaseAddr == cleanCurrentDir {
-			return "~"
-		}
-		return "~" + cleanCurrentDir[len(cleanBaseAddr):]
+	if cleanBaseAddr == cleanCurrentDir {
+		return "~"
+	}
+	// Only whole path elements match, so /home/al is not a prefix of
+	// /home/alice.
+	if base := strings.TrimSuffix(cleanBaseAddr, "/"); HasPrefix(cleanCurrentDir, base+"/") {
+		return "~" + cleanCurrentDir[len(base):]
 	}
 	return cleanCurrentDir
 }
@@ -405,10 +407,9 @@ func MockHistoryData() map[string]int {
 		"cd ..":      2,
 	}
 }
-func ClearAndFillHistoryWithMockData(db *gorm.DB) error {
-	var users []user.User
-
-	if err := db.Find(&users).Error; err != nil {
+func ClearAndFillHistoryWithMockData(store user.UserStore) error {
+	users, err := store.ListUsers()
+	if err != nil {
 		return fmt.Errorf("failed to retrieve users: %w", err)
 	}
 
@@ -423,8 +424,11 @@ func ClearAndFillHistoryWithMockData(db *gorm.DB) error {
 		}
 		fmt.Printf("History JSON to be saved for user %s: %s\n", obj.Username, string(historyJSON)) 
 
-		obj.History = string(historyJSON)
-		if err := user.Update(db, &obj); err != nil {
+		if err := user.ClearHistory(store, &obj); err != nil {
+			return fmt.Errorf("failed to clear history for user %s: %w", obj.Username, err)
+		}
+		obj.HistoryMap = historyMap
+		if err := user.Update(store, &obj); err != nil {
 			return fmt.Errorf("failed to update history for user %s: %w", obj.Username, err)
 		}
 		fmt.Printf("History updated for user: %s\n", obj.Username)
@@ -434,28 +438,28 @@ func ClearAndFillHistoryWithMockData(db *gorm.DB) error {
 	return nil
 }
 
-func PrintSortedMap(historyMap map[string]int, stdout io.Writer) {
-	var sortedPairs []struct {
-		Key   string
-		Value int
+// SortCounts lists the lines of historyMap most run first, ties in
+// alphabetical order like the stores rank them.
+func SortCounts(historyMap map[string]int) []user.HistoryCount {
+	counts := make([]user.HistoryCount, 0, len(historyMap))
+	for line, count := range historyMap {
+		counts = append(counts, user.HistoryCount{Line: line, Count: count})
 	}
-	for key, val := range historyMap {
-		sortedPairs = append(sortedPairs, struct {
-			Key   string
-			Value int
-		}{Key: key, Value: val})
-	}
-
-	sort.Slice(sortedPairs, func(i, j int) bool {
-		return sortedPairs[j].Value < sortedPairs[i].Value
+	sort.Slice(counts, func(i, j int) bool {
+		if counts[i].Count != counts[j].Count {
+			return counts[i].Count > counts[j].Count
+		}
+		return counts[i].Line < counts[j].Line
 	})
+	return counts
+}
 
+func PrintHistoryCounts(counts []user.HistoryCount, stdout io.Writer) {
 	fmt.Fprintln(stdout, "------------------------------")
 	fmt.Fprintln(stdout, "|      Command       | Count |")
 	fmt.Fprintln(stdout, "------------------------------")
-	for _, pair := range sortedPairs {
-		fmt.Fprintf(stdout, "| %-18s | %-5d |\n", pair.Key, pair.Value)
+	for _, count := range counts {
+		fmt.Fprintf(stdout, "| %-18s | %-5d |\n", count.Line, count.Count)
 	}
 	fmt.Fprintln(stdout, "------------------------------")
-
 }
@@ -333,3 +333,25 @@ func TestTrimEdge(t *testing.T) {
 	}
 }
 
+
+func TestHandleAdress(t *testing.T) {
+	testCases := []struct {
+		name       string
+		baseAddr   string
+		currentDir string
+		want       string
+	}{
+		{name: "home itself", baseAddr: "/home/al\n", currentDir: "/home/al", want: "~"},
+		{name: "inside home", baseAddr: "/home/al", currentDir: "/home/al/src", want: "~/src"},
+		{name: "sibling sharing a prefix", baseAddr: "/home/al", currentDir: "/home/alice", want: "/home/alice"},
+		{name: "outside home", baseAddr: "/home/al", currentDir: "/tmp", want: "/tmp"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := HandleAdress(tc.baseAddr, tc.currentDir); got != tc.want {
+				t.Errorf("HandleAdress(%q, %q) = %q, want %q", tc.baseAddr, tc.currentDir, got, tc.want)
+			}
+		})
+	}
+}